@@ -0,0 +1,188 @@
+package orange
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// AttemptEvent describes a single HTTP request sent to a range server while
+// resolving a query.  A single attempt may involve more than one HTTP request
+// when the range server asks for the query to be re-sent using a different
+// method.
+type AttemptEvent struct {
+	// Expression is the range expression being queried.
+	Expression string
+
+	// Server is the address of the range server the request was sent to.
+	Server string
+
+	// Method is the HTTP method used to send the request.
+	Method string
+
+	// Attempt is the zero-based count of times the query has been retried.
+	Attempt int
+
+	// StatusCode is the HTTP status code of the response, or 0 when no
+	// response was received.
+	StatusCode int
+
+	// Err is the error resulting from the request, if any.
+	Err error
+
+	// Cause is the cause of the query context being done, as returned by
+	// context.Cause, when the request failed after the context was canceled
+	// or its deadline passed.  It is nil otherwise.
+	Cause error
+
+	// Header contains the response headers named by Config.ResponseHeaders,
+	// such as a server provided request identifier, so client side logs can
+	// reference server side identifiers.  It is nil when no response was
+	// received.
+	Header http.Header
+
+	// Timing breaks down where the time was spent sending the request and
+	// receiving the response.
+	Timing Timing
+}
+
+// Timing breaks down the duration of a single HTTP request, making it
+// immediately answerable whether a slow query was caused by the network or by
+// the range server.  Durations for phases that did not occur, such as DNS
+// resolution and connecting when an idle connection was reused, are zero.
+type Timing struct {
+	DNS       time.Duration // DNS is the time spent resolving the server address.
+	Connect   time.Duration // Connect is the time spent establishing a connection.
+	TLS       time.Duration // TLS is the time spent performing a TLS handshake.
+	FirstByte time.Duration // FirstByte is the time from sending the request until the first response byte.
+	Total     time.Duration // Total is the time from sending the request until the response was processed.
+
+	// ReusedConnection is true when the request was sent using an idle
+	// connection from a previous request.
+	ReusedConnection bool
+}
+
+// attemptRecorder collects timing information for a single HTTP request and
+// reports it to the configured hooks once the request completes.
+type attemptRecorder struct {
+	client *Client
+	ctx    context.Context
+	event  AttemptEvent
+	start  time.Time
+
+	lock       sync.Mutex
+	dnsStart   time.Time
+	connStart  time.Time
+	tlsStart   time.Time
+	timing     Timing
+	traceHooks *httptrace.ClientTrace
+}
+
+// newAttemptRecorder returns a recorder for an HTTP request, or nil when the
+// client has neither an OnAttempt hook nor debug logging configured.  All of
+// the recorder methods are safe to invoke on a nil recorder.
+func (c *Client) newAttemptRecorder(expression, server, method string, attempt int) *attemptRecorder {
+	if c.onAttempt == nil && c.debugf == nil {
+		return nil
+	}
+	ar := &attemptRecorder{
+		client: c,
+		event: AttemptEvent{
+			Expression: expression,
+			Server:     server,
+			Method:     method,
+			Attempt:    attempt,
+		},
+		start: time.Now(),
+	}
+	ar.traceHooks = &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			ar.lock.Lock()
+			ar.dnsStart = time.Now()
+			ar.lock.Unlock()
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			ar.lock.Lock()
+			ar.timing.DNS = time.Since(ar.dnsStart)
+			ar.lock.Unlock()
+		},
+		ConnectStart: func(string, string) {
+			ar.lock.Lock()
+			ar.connStart = time.Now()
+			ar.lock.Unlock()
+		},
+		ConnectDone: func(string, string, error) {
+			ar.lock.Lock()
+			ar.timing.Connect = time.Since(ar.connStart)
+			ar.lock.Unlock()
+		},
+		TLSHandshakeStart: func() {
+			ar.lock.Lock()
+			ar.tlsStart = time.Now()
+			ar.lock.Unlock()
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			ar.lock.Lock()
+			ar.timing.TLS = time.Since(ar.tlsStart)
+			ar.lock.Unlock()
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			ar.lock.Lock()
+			ar.timing.ReusedConnection = info.Reused
+			ar.lock.Unlock()
+		},
+		GotFirstResponseByte: func() {
+			ar.lock.Lock()
+			ar.timing.FirstByte = time.Since(ar.start)
+			ar.lock.Unlock()
+		},
+	}
+	return ar
+}
+
+// withContext returns a context that traces the HTTP request.
+func (ar *attemptRecorder) withContext(ctx context.Context) context.Context {
+	if ar == nil {
+		return ctx
+	}
+	ar.ctx = ctx
+	return httptrace.WithClientTrace(ctx, ar.traceHooks)
+}
+
+// done reports the completed HTTP request to the configured hooks.  The
+// response is nil when no response was received.
+func (ar *attemptRecorder) done(response *http.Response, err error) {
+	if ar == nil {
+		return
+	}
+
+	var statusCode int
+	if response != nil {
+		statusCode = response.StatusCode
+		ar.event.Header = captureHeaders(response.Header, ar.client.responseHeaders)
+	}
+
+	ar.lock.Lock()
+	ar.event.Timing = ar.timing
+	ar.lock.Unlock()
+
+	ar.event.Timing.Total = time.Since(ar.start)
+	ar.event.StatusCode = statusCode
+	ar.event.Err = err
+	if err != nil && ar.ctx != nil && ar.ctx.Err() != nil {
+		ar.event.Cause = context.Cause(ar.ctx)
+	}
+
+	if ar.client.debugf != nil {
+		t := ar.event.Timing
+		ar.client.debugf("orange: %s %s attempt %d: status %d; dns: %s; connect: %s; tls: %s; first byte: %s; total: %s; reused: %t; error: %v; cause: %v",
+			ar.event.Method, ar.event.Server, ar.event.Attempt, statusCode,
+			t.DNS, t.Connect, t.TLS, t.FirstByte, t.Total, t.ReusedConnection, err, ar.event.Cause)
+	}
+	if ar.client.onAttempt != nil {
+		ar.client.onAttempt(ar.event)
+	}
+}
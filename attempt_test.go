@@ -0,0 +1,144 @@
+package orange
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAttemptEvents(t *testing.T) {
+	h := func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			http.Error(w, r.RequestURI, http.StatusRequestURITooLong)
+		default:
+			w.Write([]byte("result1\nresult2\n"))
+		}
+	}
+	withTestServer(t, h, func(server *httptest.Server) {
+		address := strings.TrimLeft(server.URL, "http://")
+
+		var lock sync.Mutex
+		var events []AttemptEvent
+		var logs []string
+
+		client, err := NewClient(&Config{
+			Debugf: func(format string, args ...interface{}) {
+				lock.Lock()
+				logs = append(logs, fmt.Sprintf(format, args...))
+				lock.Unlock()
+			},
+			HTTPClient: server.Client(),
+			OnAttempt: func(event AttemptEvent) {
+				lock.Lock()
+				events = append(events, event)
+				lock.Unlock()
+			},
+			Servers: []string{address},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err = client.Query("foo"); err != nil {
+			t.Fatal(err)
+		}
+
+		if got, want := len(events), 2; got != want {
+			t.Fatalf("GOT: %v; WANT: %v", got, want)
+		}
+
+		if got, want := events[0].Method, http.MethodGet; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := events[0].StatusCode, http.StatusRequestURITooLong; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+
+		if got, want := events[1].Method, http.MethodPut; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := events[1].StatusCode, http.StatusOK; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := events[1].Server, address; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := events[1].Expression, "foo"; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if events[1].Err != nil {
+			t.Errorf("GOT: %v; WANT: %v", events[1].Err, nil)
+		}
+
+		for _, event := range events {
+			if event.Timing.FirstByte <= 0 {
+				t.Errorf("GOT: %v; WANT: positive duration", event.Timing.FirstByte)
+			}
+			if event.Timing.Total < event.Timing.FirstByte {
+				t.Errorf("GOT: %v; WANT: at least %v", event.Timing.Total, event.Timing.FirstByte)
+			}
+		}
+
+		if got, want := len(logs), 2; got != want {
+			t.Fatalf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := logs[1], "PUT "+address+" attempt 0: status 200"; !strings.Contains(got, want) {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+}
+
+func TestContextCause(t *testing.T) {
+	cause := errors.New("upstream shutdown")
+	release := make(chan struct{})
+	defer close(release)
+
+	h := func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}
+	withTestServer(t, h, func(server *httptest.Server) {
+		events := make(chan AttemptEvent, 1)
+
+		client, err := NewClient(&Config{
+			HTTPClient: server.Client(),
+			OnAttempt:  func(event AttemptEvent) { events <- event },
+			Servers:    []string{strings.TrimLeft(server.URL, "http://")},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ctx, cancel := context.WithCancelCause(context.Background())
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			cancel(cause)
+		}()
+
+		_, err = client.QueryCtx(ctx, "foo")
+		if got, want := errors.Is(err, cause), true; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := errors.Is(err, context.Canceled), true; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+
+		select {
+		case event := <-events:
+			if got, want := event.Cause, cause; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("timeout waiting for attempt event")
+		}
+	})
+}
@@ -0,0 +1,99 @@
+package orange
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ParseAttributes decodes query results that dump attributes, covering lines
+// such as "web1.example.com OWNER=team-a RACK=r12", into a map from each host to
+// its attributes.  Lines that begin with a KEY=VALUE pair rather than a host,
+// such as those listing the keys of a single cluster, are stored under the
+// empty host name.  Values containing spaces may be double quoted using Go
+// string syntax.  Blank lines are ignored, and attributes from multiple lines
+// for the same host are merged, with later values replacing earlier ones.
+//
+//     values, err := client.Query("%cluster:ATTRIBUTES")
+//     if err != nil {
+//         return err
+//     }
+//     attributes, err := orange.ParseAttributes(values)
+//     if err != nil {
+//         return err
+//     }
+//     fmt.Println(attributes["web1.example.com"]["OWNER"])
+func ParseAttributes(lines []string) (map[string]map[string]string, error) {
+	attributes := make(map[string]map[string]string)
+
+	for i, line := range lines {
+		fields, err := splitAttributeFields(line)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse attributes line %d: %s", i+1, err)
+		}
+		if len(fields) == 0 {
+			continue
+		}
+
+		var host string
+		if !strings.Contains(fields[0], "=") {
+			host, fields = fields[0], fields[1:]
+		}
+
+		m, ok := attributes[host]
+		if !ok {
+			m = make(map[string]string, len(fields))
+			attributes[host] = m
+		}
+
+		for _, field := range fields {
+			eq := strings.IndexByte(field, '=')
+			if eq <= 0 {
+				return nil, fmt.Errorf("cannot parse attributes line %d: expected KEY=VALUE: %q", i+1, field)
+			}
+			value := field[eq+1:]
+			if strings.HasPrefix(value, `"`) {
+				if value, err = strconv.Unquote(value); err != nil {
+					return nil, fmt.Errorf("cannot parse attributes line %d: invalid quoted value: %q", i+1, field)
+				}
+			}
+			m[field[:eq]] = value
+		}
+	}
+
+	return attributes, nil
+}
+
+// splitAttributeFields splits line on white space, keeping double quoted
+// strings, which may contain white space and escaped quotes, within a single
+// field.
+func splitAttributeFields(line string) ([]string, error) {
+	var fields []string
+	var field strings.Builder
+	var inQuotes, escaped bool
+
+	for _, r := range line {
+		switch {
+		case escaped:
+			escaped = false
+		case inQuotes && r == '\\':
+			escaped = true
+		case r == '"':
+			inQuotes = !inQuotes
+		case !inQuotes && (r == ' ' || r == '\t' || r == '\r'):
+			if field.Len() > 0 {
+				fields = append(fields, field.String())
+				field.Reset()
+			}
+			continue
+		}
+		field.WriteRune(r)
+	}
+	if inQuotes {
+		return nil, fmt.Errorf("unterminated quoted value: %q", line)
+	}
+	if field.Len() > 0 {
+		fields = append(fields, field.String())
+	}
+	return fields, nil
+}
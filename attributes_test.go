@@ -0,0 +1,63 @@
+package orange
+
+import "testing"
+
+func TestParseAttributes(t *testing.T) {
+	t.Run("hosts", func(t *testing.T) {
+		attributes, err := ParseAttributes([]string{
+			"web1.example.com OWNER=team-a RACK=r12",
+			"",
+			"web2.example.com\tOWNER=team-b  NOTE=\"two words\"",
+			"web1.example.com RACK=r13",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := len(attributes), 2; got != want {
+			t.Fatalf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := attributes["web1.example.com"]["OWNER"], "team-a"; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := attributes["web1.example.com"]["RACK"], "r13"; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := attributes["web2.example.com"]["NOTE"], "two words"; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("key value list", func(t *testing.T) {
+		attributes, err := ParseAttributes([]string{"OWNER=team-a", "EMPTY="})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := attributes[""]["OWNER"], "team-a"; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if value, ok := attributes[""]["EMPTY"]; !ok || value != "" {
+			t.Errorf("GOT: %q, %v; WANT: %q, %v", value, ok, "", true)
+		}
+	})
+
+	t.Run("host without attributes", func(t *testing.T) {
+		attributes, err := ParseAttributes([]string{"web1"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m, ok := attributes["web1"]; !ok || len(m) != 0 {
+			t.Errorf("GOT: %v, %v; WANT: empty map, %v", m, ok, true)
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		_, err := ParseAttributes([]string{"web1 OWNER=a", "web2 bogus"})
+		ensureError(t, err, "line 2", "expected KEY=VALUE", "bogus")
+
+		_, err = ParseAttributes([]string{`web1 NOTE="unterminated`})
+		ensureError(t, err, "line 1", "unterminated")
+
+		_, err = ParseAttributes([]string{"web1 =value"})
+		ensureError(t, err, "expected KEY=VALUE")
+	})
+}
@@ -0,0 +1,55 @@
+package orange
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAuthProvider(t *testing.T) {
+	var authorizations []string
+	withTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		authorizations = append(authorizations, r.Header.Get("Authorization"))
+		if len(authorizations) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte("host1\n"))
+	}, func(server *httptest.Server) {
+		var tokens int
+		client, err := NewClient(&Config{
+			AuthProvider: func(request *http.Request) error {
+				if request.Context().Value(priorityKey{}) == PriorityBackground {
+					return errors.New("token service unavailable")
+				}
+				tokens++
+				request.Header.Set("Authorization", fmt.Sprintf("Bearer token%d", tokens))
+				return nil
+			},
+			HTTPClient:    server.Client(),
+			RetryCallback: func(error) bool { return true },
+			RetryCount:    1,
+			Servers:       []string{strings.TrimPrefix(server.URL, "http://")},
+		})
+		ensureError(t, err)
+
+		t.Run("each attempt", func(t *testing.T) {
+			_, err := client.Query("%someCluster")
+			ensureError(t, err)
+			ensureStringSlicesMatch(t, authorizations, []string{"Bearer token1", "Bearer token2"})
+		})
+
+		t.Run("error", func(t *testing.T) {
+			ctx := WithQueryPriority(context.Background(), PriorityBackground)
+			_, err := client.QueryCtx(ctx, "%someCluster")
+			ensureError(t, err, "cannot authenticate request", "token service unavailable")
+			if got, want := len(authorizations), 2; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+		})
+	})
+}
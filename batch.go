@@ -0,0 +1,176 @@
+package orange
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// BatchError is returned by Queries and QueriesCtx when one or more of the
+// expressions failed, mapping each failed expression to its specific error
+// rather than failing the whole batch with whichever error happened first.
+// Because it implements Unwrap() []error, errors.Is and errors.As examine the
+// error of every failed expression.
+type BatchError struct {
+	// Errors maps each failed expression to the error its query returned.
+	Errors map[string]error
+}
+
+func (err *BatchError) Error() string {
+	expressions := err.expressions()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d queries failed", len(expressions))
+	for i, expression := range expressions {
+		if i == 0 {
+			b.WriteString(": ")
+		} else {
+			b.WriteString("; ")
+		}
+		fmt.Fprintf(&b, "%q: %s", expression, err.Errors[expression])
+	}
+	return b.String()
+}
+
+// Unwrap returns the errors of the failed expressions, ordered by expression.
+func (err *BatchError) Unwrap() []error {
+	expressions := err.expressions()
+	errs := make([]error, len(expressions))
+	for i, expression := range expressions {
+		errs[i] = err.Errors[expression]
+	}
+	return errs
+}
+
+func (err *BatchError) expressions() []string {
+	expressions := make([]string, 0, len(err.Errors))
+	for expression := range err.Errors {
+		expressions = append(expressions, expression)
+	}
+	sort.Strings(expressions)
+	return expressions
+}
+
+// Queries sends each of the query expressions to the range servers
+// concurrently, and returns a slice of results in the same order as the
+// expressions.
+//
+// When one or more queries fail, the results of the successful queries are
+// still returned, along with a *BatchError that maps each failed expression to
+// its error.  The results for failed expressions are nil.
+//
+//     results, err := client.Queries([]string{"%cluster1", "%cluster2"})
+//     if err != nil {
+//         var be *orange.BatchError
+//         if !errors.As(err, &be) {
+//             return err
+//         }
+//         for expression, err := range be.Errors {
+//             log.Printf("cannot query %q: %s", expression, err)
+//         }
+//     }
+func (c *Client) Queries(expressions []string) ([][]string, error) {
+	return c.QueriesCtx(context.Background(), expressions)
+}
+
+// QueriesCtx sends each of the query expressions to the range servers
+// concurrently with the provided query context, and returns a slice of results
+// in the same order as the expressions.  See Queries for how errors are
+// returned.
+//
+// When Config.BatchRequests is set, the expressions are sent to a range
+// server in a single batch request, falling back to querying each expression
+// individually when the batch request fails.  See BatchResult.
+func (c *Client) QueriesCtx(ctx context.Context, expressions []string) ([][]string, error) {
+	return c.QueriesConcurrency(ctx, expressions, 0)
+}
+
+// QueriesConcurrency is QueriesCtx, but runs at most concurrency of the
+// queries at once, so a large slice of expressions does not send a burst of
+// requests to the range servers.  When concurrency is 0 or less, it behaves
+// exactly as QueriesCtx.  See Queries for how errors are returned.
+//
+//     results, err := client.QueriesConcurrency(ctx, expressions, 8)
+//
+// Once ctx is done, the queries not yet started are not sent, and fail with
+// the context error.
+func (c *Client) QueriesConcurrency(ctx context.Context, expressions []string, concurrency int) ([][]string, error) {
+	if c.batchRequests && len(expressions) > 1 && atomic.LoadUint32(&c.batchUnsupported) == 0 {
+		if results, err := c.queriesBatch(ctx, expressions); err != errBatchFailed {
+			return results, err
+		}
+	}
+
+	var run func(func())
+	if c.batchWorkers != nil {
+		run = c.batchWorkers.run
+	}
+	return queriesCtx(ctx, expressions, c.QueryCtx, limitRun(ctx, concurrency, run))
+}
+
+// limitRun returns a function that runs each job using run, or on its own
+// go-routine when run is nil, but blocks until fewer than concurrency of its
+// jobs are running.  Once ctx is done, it runs jobs on the calling go-routine
+// rather than waiting, so they promptly fail with the context error.  When
+// concurrency is 0 or less, it returns run.
+func limitRun(ctx context.Context, concurrency int, run func(func())) func(func()) {
+	if concurrency <= 0 {
+		return run
+	}
+	if run == nil {
+		run = func(job func()) { go job() }
+	}
+	slots := make(chan struct{}, concurrency)
+	return func(job func()) {
+		select {
+		case slots <- struct{}{}:
+			run(func() {
+				defer func() { <-slots }()
+				job()
+			})
+		case <-ctx.Done():
+			job()
+		}
+	}
+}
+
+// queriesCtx invokes query for each of the expressions concurrently, and returns
+// their results in the same order as the expressions, along with a *BatchError
+// when one or more of them failed.  Each query is run by run, or on its own
+// go-routine when run is nil.
+func queriesCtx(ctx context.Context, expressions []string, query func(context.Context, string) ([]string, error), run func(func())) ([][]string, error) {
+	if run == nil {
+		run = func(job func()) { go job() }
+	}
+
+	results := make([][]string, len(expressions))
+	errs := make([]error, len(expressions))
+
+	var wg sync.WaitGroup
+	wg.Add(len(expressions))
+	for i, expression := range expressions {
+		i, expression := i, expression
+		run(func() {
+			defer wg.Done()
+			results[i], errs[i] = query(ctx, expression)
+		})
+	}
+	wg.Wait()
+
+	var be *BatchError
+	for i, err := range errs {
+		if err != nil {
+			if be == nil {
+				be = &BatchError{Errors: make(map[string]error)}
+			}
+			be.Errors[expressions[i]] = err
+		}
+	}
+	if be != nil {
+		return results, be
+	}
+	return results, nil
+}
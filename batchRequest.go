@@ -0,0 +1,195 @@
+package orange
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+)
+
+// BatchResult is one element of the response to a batch request, holding
+// either the results of the corresponding expression of the request, or the
+// RangeException message explaining why it could not be evaluated.
+//
+// A batch request is a POST to the batch endpoint, a sibling of Config.Path
+// such as /range/batch, whose body is a JSON array of
+// expressions, answered with a JSON array of the same length holding one
+// BatchResult per expression, in the same order.
+//
+//     ["%cluster1", "%nosuchcluster"]
+//
+//     [{"results": ["host1", "host2"]}, {"exception": "NOCLUSTER: 'nosuchcluster'"}]
+type BatchResult struct {
+	Results   []string `json:"results,omitempty"`
+	Exception string   `json:"exception,omitempty"`
+}
+
+// batchExpression stands in for the expressions of a batch request in the
+// AttemptEvent and RecentError values describing it.
+const batchExpression = "batch"
+
+// errBatchFailed is returned by queriesBatch when the batch request itself
+// failed, and the expressions ought to be queried individually.
+var errBatchFailed = errors.New("batch request failed")
+
+// queriesBatch sends expressions to a range server in a single batch request,
+// and returns their results along with a *BatchError when one or more of them
+// failed.  It returns errBatchFailed when the batch request itself failed.
+// When the server does not support batch requests, the client stops sending
+// them.
+//
+// Expressions found in the cache are not sent, and the results of the others
+// are stored in it, but batch requests are neither coalesced, retried, nor
+// passed through Interceptors.  Like a query, a batch request waits for
+// MaxConcurrentQueries and RateLimit, and is reported to OnAttempt and
+// RecentErrors.
+func (c *Client) queriesBatch(ctx context.Context, expressions []string) ([][]string, error) {
+	results := make([][]string, len(expressions))
+
+	// Only send the expressions not already in the cache.
+	var pending []int
+	for i, expression := range expressions {
+		if c.cache != nil {
+			if values, ok := c.cache.get(expression); ok {
+				c.stats.addCacheHit()
+				results[i] = values
+				continue
+			}
+			c.stats.addCacheMiss()
+		}
+		pending = append(pending, i)
+	}
+	if len(pending) == 0 {
+		return results, nil
+	}
+	sent := make([]string, len(pending))
+	for i, j := range pending {
+		expression, err := c.rewrite(expressions[j])
+		if err != nil {
+			return nil, errBatchFailed // report the error of each expression
+		}
+		sent[i] = expression
+	}
+
+	c.inFlight.add()
+	defer c.inFlight.done()
+
+	if c.concurrency != nil {
+		priority, _ := queryPriority(ctx)
+		if err := c.concurrency.acquire(ctx, priority); err != nil {
+			return nil, errBatchFailed
+		}
+		defer c.concurrency.release()
+	}
+
+	// The failure is recorded for each expression once they are queried
+	// individually.
+	if err := c.waitRateLimit(ctx); err != nil {
+		return nil, errBatchFailed
+	}
+
+	body, err := json.Marshal(sent)
+	if err != nil {
+		return nil, errBatchFailed
+	}
+	server := c.servers.Next()
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, c.serverURL(server)+c.siblingPath("batch"), bytes.NewReader(body))
+	if err != nil {
+		return nil, errBatchFailed
+	}
+	request.Header.Set("Accept", "application/json")
+	request.Header.Set("Content-Type", "application/json")
+	c.setHeaders(ctx, request.Header)
+
+	recorder := c.newAttemptRecorder(batchExpression, server, http.MethodPost, 0)
+	request = request.WithContext(recorder.withContext(ctx))
+	fail := func(response *http.Response, err error) ([][]string, error) {
+		recorder.done(response, err)
+		c.recentErrors.add(RecentError{
+			Time:       c.clock.Now(),
+			Server:     server,
+			Expression: batchExpression,
+			Err:        err,
+		})
+		return nil, errBatchFailed
+	}
+
+	start := c.clock.Now()
+	if err := c.authenticate(request); err != nil {
+		return fail(nil, err)
+	}
+	response, err := c.httpClient.Do(request)
+	if err != nil {
+		return fail(nil, err)
+	}
+	if response.StatusCode != http.StatusOK {
+		switch response.StatusCode {
+		case http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusNotImplemented:
+			atomic.StoreUint32(&c.batchUnsupported, 1)
+		}
+		_ = discard(response.Body)
+		return fail(response, &ErrStatusNotOK{
+			Header:     response.Header,
+			Status:     response.Status,
+			StatusCode: response.StatusCode,
+		})
+	}
+
+	var answers []BatchResult
+	crc := &countingReadCloser{ReadCloser: response.Body}
+	err = json.NewDecoder(crc).Decode(&answers)
+	_ = discard(crc)
+	if err == nil && len(answers) != len(sent) {
+		err = fmt.Errorf("cannot use batch response with %d results for %d expressions", len(answers), len(sent))
+	}
+	if err != nil {
+		return fail(response, err)
+	}
+	recorder.done(response, nil)
+
+	var be *BatchError
+	for i, answer := range answers {
+		expression := expressions[pending[i]]
+		c.stats.addQuery()
+		if answer.Exception != "" {
+			err := &QueryError{
+				Expression:        sent[i],
+				Server:            server,
+				Method:            http.MethodPost,
+				Attempts:          1,
+				Err:               newErrRangeException(answer.Exception),
+				includeExpression: c.includeExpressionInErrors,
+			}
+			c.failed(expression, err)
+			if be == nil {
+				be = &BatchError{Errors: make(map[string]error)}
+			}
+			be.Errors[expression] = err
+			continue
+		}
+		values := answer.Results
+		c.stats.addResults(len(values))
+		if len(values) == 0 {
+			values = nil
+		}
+		if c.unique {
+			values = Unique(values)
+		}
+		if c.sorted {
+			SortHosts(values)
+		}
+		if c.cache != nil {
+			c.cache.set(expression, values)
+			c.stats.addCacheRefresh(c.clock.Now().Sub(start))
+		}
+		results[pending[i]] = values
+	}
+	c.stats.addReceived(crc.bytes)
+	if be != nil {
+		return results, be
+	}
+	return results, nil
+}
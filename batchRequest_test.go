@@ -0,0 +1,159 @@
+package orange
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func withBatchClient(t *testing.T, h func(w http.ResponseWriter, r *http.Request), callback func(*Client)) {
+	withTestServer(t, h, func(server *httptest.Server) {
+		client, err := NewClient(&Config{
+			BatchRequests: true,
+			HTTPClient:    server.Client(),
+			Servers:       []string{strings.TrimPrefix(server.URL, "http://")},
+			Sorted:        true,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		callback(client)
+	})
+}
+
+func TestBatchRequests(t *testing.T) {
+	t.Run("single round trip", func(t *testing.T) {
+		var requests int
+		withBatchClient(t, func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			if got, want := r.URL.Path, "/range/batch"; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+			var expressions []string
+			if err := json.NewDecoder(r.Body).Decode(&expressions); err != nil {
+				t.Fatal(err)
+			}
+			answers := make([]BatchResult, len(expressions))
+			for i, expression := range expressions {
+				if expression == "%bad" {
+					answers[i].Exception = "NOCLUSTER: 'bad'"
+					continue
+				}
+				answers[i].Results = []string{expression + "2", expression + "1"}
+			}
+			_ = json.NewEncoder(w).Encode(answers)
+		}, func(client *Client) {
+			results, err := client.Queries([]string{"%c1", "%bad", "%c2"})
+			ensureError(t, err, "NOCLUSTER")
+			if got, want := fmt.Sprint(results), "[[%c11 %c12] [] [%c21 %c22]]"; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+			if got, want := requests, 1; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+		})
+	})
+
+	t.Run("fallback", func(t *testing.T) {
+		var lock sync.Mutex
+		var paths []string
+		withBatchClient(t, func(w http.ResponseWriter, r *http.Request) {
+			lock.Lock()
+			paths = append(paths, r.URL.Path)
+			lock.Unlock()
+			if r.URL.Path == "/range/batch" {
+				http.NotFound(w, r)
+				return
+			}
+			_, _ = w.Write([]byte(r.URL.RawQuery + "\n"))
+		}, func(client *Client) {
+			for i := 0; i < 2; i++ {
+				results, err := client.Queries([]string{"c1", "c2"})
+				ensureError(t, err)
+				if got, want := fmt.Sprint(results), "[[c1] [c2]]"; got != want {
+					t.Errorf("GOT: %v; WANT: %v", got, want)
+				}
+			}
+			// Once the server rejected a batch request, no more are sent.
+			if got, want := strings.Join(paths, ","), "/range/batch,/range/list,/range/list,/range/list,/range/list"; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+		})
+	})
+}
+
+func TestBatchRequestHooks(t *testing.T) {
+	h := func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/v1/range/list":
+			_, _ = w.Write([]byte(r.URL.RawQuery + "\n"))
+		case r.URL.Path != "/v1/range/batch":
+			t.Errorf("GOT: %v; WANT: %v", r.URL.Path, "/v1/range/batch")
+		case r.Header.Get("X-Fail") != "":
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		default:
+			_ = json.NewEncoder(w).Encode([]BatchResult{{Results: []string{"host1"}}, {Results: []string{"host2"}}})
+		}
+	}
+	withTestServer(t, h, func(server *httptest.Server) {
+		newClient := func(header http.Header, onAttempt func(AttemptEvent)) *Client {
+			client, err := NewClient(&Config{
+				BatchRequests:        true,
+				Headers:              header,
+				HTTPClient:           server.Client(),
+				MaxConcurrentQueries: 1,
+				OnAttempt:            onAttempt,
+				Path:                 "/v1/range/list",
+				Servers:              []string{strings.TrimPrefix(server.URL, "http://")},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			return client
+		}
+
+		t.Run("attempt", func(t *testing.T) {
+			var events []AttemptEvent
+			client := newClient(nil, func(event AttemptEvent) { events = append(events, event) })
+
+			results, err := client.Queries([]string{"c1", "c2"})
+			ensureError(t, err)
+			if got, want := fmt.Sprint(results), "[[host1] [host2]]"; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+			if got, want := len(events), 1; got != want {
+				t.Fatalf("GOT: %v; WANT: %v", got, want)
+			}
+			if got, want := events[0].Method, http.MethodPost; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+			if got, want := events[0].StatusCode, http.StatusOK; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+		})
+
+		t.Run("recent errors", func(t *testing.T) {
+			client := newClient(http.Header{"X-Fail": []string{"1"}}, nil)
+
+			// The failed batch request falls back to a query per expression.
+			results, err := client.Queries([]string{"c1", "c2"})
+			ensureError(t, err)
+			if got, want := fmt.Sprint(results), "[[c1] [c2]]"; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+
+			recent := client.RecentErrors()
+			if got, want := len(recent), 1; got != want {
+				t.Fatalf("GOT: %v; WANT: %v", got, want)
+			}
+			if got, want := recent[0].Expression, batchExpression; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+			ensureError(t, recent[0].Err, "Service Unavailable")
+		})
+	})
+}
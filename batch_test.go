@@ -0,0 +1,118 @@
+package orange
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestQueries(t *testing.T) {
+	h := func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.RawQuery {
+		case "bad":
+			w.Header().Set("RangeException", "no such cluster")
+		case "unavailable":
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		default:
+			w.Write([]byte(r.URL.RawQuery + "1\n" + r.URL.RawQuery + "2\n"))
+		}
+	}
+
+	t.Run("all succeed", func(t *testing.T) {
+		withClient(t, h, func(client *Client) {
+			results, err := client.Queries([]string{"foo", "bar"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := len(results), 2; got != want {
+				t.Fatalf("GOT: %v; WANT: %v", got, want)
+			}
+			ensureStringSlicesMatch(t, results[0], []string{"foo1", "foo2"})
+			ensureStringSlicesMatch(t, results[1], []string{"bar1", "bar2"})
+		})
+	})
+
+	t.Run("some fail", func(t *testing.T) {
+		withClient(t, h, func(client *Client) {
+			results, err := client.Queries([]string{"foo", "bad", "unavailable"})
+			ensureError(t, err, "2 queries failed", `"bad": `, `"unavailable": `)
+
+			ensureStringSlicesMatch(t, results[0], []string{"foo1", "foo2"})
+			ensureStringSlicesMatch(t, results[1], nil)
+			ensureStringSlicesMatch(t, results[2], nil)
+
+			var be *BatchError
+			if !errors.As(err, &be) {
+				t.Fatalf("GOT: %T; WANT: %T", err, be)
+			}
+			if got, want := len(be.Errors), 2; got != want {
+				t.Fatalf("GOT: %v; WANT: %v", got, want)
+			}
+			if got, want := errors.Is(be.Errors["bad"], &ErrRangeException{}), true; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+
+			// errors.Is and errors.As examine every failed expression.
+			if got, want := errors.Is(err, &ErrRangeException{}), true; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+			if got, want := errors.Is(err, &ErrStatusNotOK{StatusCode: http.StatusServiceUnavailable}), true; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+		})
+	})
+}
+
+func TestQueriesConcurrency(t *testing.T) {
+	var running, peak int32
+	h := func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		w.Write([]byte(r.URL.RawQuery + "\n"))
+	}
+
+	t.Run("bounded", func(t *testing.T) {
+		withClient(t, h, func(client *Client) {
+			expressions := make([]string, 10)
+			for i := range expressions {
+				expressions[i] = fmt.Sprintf("host%d", i)
+			}
+			results, err := client.QueriesConcurrency(context.Background(), expressions, 2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, expression := range expressions {
+				ensureStringSlicesMatch(t, results[i], []string{expression})
+			}
+			if got, want := atomic.LoadInt32(&peak), int32(2); got > want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+		})
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		withClient(t, h, func(client *Client) {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			results, err := client.QueriesConcurrency(ctx, []string{"foo", "bar", "baz"}, 1)
+			ensureError(t, err, "3 queries failed")
+			if got, want := errors.Is(err, context.Canceled), true; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+			if got, want := len(results), 3; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+		})
+	})
+}
@@ -0,0 +1,213 @@
+package orange
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// resultCache stores the results of successful queries until they expire.
+// When bounded by maxEntries or maxBytes, the least recently used entries are
+// evicted to make room for new ones.
+type resultCache struct {
+	ttl        time.Duration
+	now        func() time.Time // allows tests to control the clock
+	maxEntries int              // maximum number of entries, or 0 for no limit
+	maxBytes   int              // maximum total size of entries, or 0 for no limit
+	lock       sync.Mutex
+	entries    map[string]*list.Element
+	lru        *list.List // most recently used entries at the front
+	bytes      int        // total size of entries
+}
+
+type cacheEntry struct {
+	expression string
+	values     []string
+	expires    time.Time
+	size       int
+}
+
+func newResultCache(ttl time.Duration) *resultCache {
+	return &resultCache{
+		ttl:     ttl,
+		now:     time.Now,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+// cacheEntrySize returns the size of an entry counted against maxBytes, which
+// is the length of its expression and of each of its values.
+func cacheEntrySize(expression string, values []string) int {
+	size := len(expression)
+	for _, v := range values {
+		size += len(v)
+	}
+	return size
+}
+
+// get returns a copy of the cached results for expression, and whether they
+// were found and not yet expired.
+func (rc *resultCache) get(expression string) ([]string, bool) {
+	rc.lock.Lock()
+	defer rc.lock.Unlock()
+
+	element, ok := rc.entries[expression]
+	if !ok {
+		return nil, false
+	}
+	entry := element.Value.(*cacheEntry)
+	if !rc.now().Before(entry.expires) {
+		rc.remove(element)
+		return nil, false
+	}
+	rc.lru.MoveToFront(element)
+	return copyStrings(entry.values), true
+}
+
+// set stores a copy of values for expression, evicting the least recently used
+// entries as needed to stay within maxEntries and maxBytes.  Values larger than
+// maxBytes by themselves are not stored.
+func (rc *resultCache) set(expression string, values []string) {
+	size := cacheEntrySize(expression, values)
+
+	rc.lock.Lock()
+	defer rc.lock.Unlock()
+
+	if element, ok := rc.entries[expression]; ok {
+		rc.remove(element)
+	}
+	if rc.maxBytes > 0 && size > rc.maxBytes {
+		return
+	}
+
+	entry := &cacheEntry{
+		expression: expression,
+		values:     copyStrings(values),
+		expires:    rc.now().Add(rc.ttl),
+		size:       size,
+	}
+	rc.entries[expression] = rc.lru.PushFront(entry)
+	rc.bytes += size
+
+	for (rc.maxEntries > 0 && rc.lru.Len() > rc.maxEntries) || (rc.maxBytes > 0 && rc.bytes > rc.maxBytes) {
+		rc.remove(rc.lru.Back())
+	}
+}
+
+// remove deletes element from the cache.  The lock must be held.
+func (rc *resultCache) remove(element *list.Element) {
+	entry := rc.lru.Remove(element).(*cacheEntry)
+	delete(rc.entries, entry.expression)
+	rc.bytes -= entry.size
+}
+
+// flightGroup coalesces concurrent queries for the same expression, so only one
+// query is sent to the range servers and its results are shared with every
+// caller waiting on it.
+type flightGroup struct {
+	lock    sync.Mutex
+	flights map[string]*flight
+}
+
+type flight struct {
+	done    chan struct{}
+	cancel  context.CancelFunc // cancels the query once no caller waits on it
+	values  []string
+	err     error
+	waiters int // number of callers waiting on the results, including the first
+}
+
+func newFlightGroup() *flightGroup {
+	return &flightGroup{flights: make(map[string]*flight)}
+}
+
+// do invokes fn for expression unless a call for the same expression is
+// already in flight, in which case it waits for and returns a copy of that
+// call's results.  The second return value is true when the results were
+// shared from another call.
+//
+// Because no single caller owns the call, fn runs on its own go-routine, with
+// a context carrying the values of the first caller's ctx, but that is only
+// canceled once every caller has abandoned the wait.  Each caller abandons the
+// wait when its own ctx is done, and returns its own context error.
+func (fg *flightGroup) do(ctx context.Context, expression string, fn func(context.Context) ([]string, error)) ([]string, bool, error) {
+	fg.lock.Lock()
+	f, shared := fg.flights[expression]
+	if !shared {
+		flightCtx, cancel := context.WithCancel(detachedContext{ctx})
+		f = &flight{done: make(chan struct{}), cancel: cancel}
+		fg.flights[expression] = f
+		go fg.run(flightCtx, expression, f, fn)
+	}
+	f.waiters++
+	fg.lock.Unlock()
+
+	select {
+	case <-f.done:
+		if shared {
+			return copyStrings(f.values), true, f.err
+		}
+		return f.values, false, f.err
+	case <-ctx.Done():
+		fg.lock.Lock()
+		f.waiters--
+		if f.waiters == 0 {
+			// Callers arriving after this one start a new call rather than
+			// sharing this canceled one.
+			f.cancel()
+			fg.forget(expression, f)
+		}
+		fg.lock.Unlock()
+		return nil, shared, contextError(ctx)
+	}
+}
+
+// run invokes fn for the in-flight call f, then releases its waiters.
+func (fg *flightGroup) run(ctx context.Context, expression string, f *flight, fn func(context.Context) ([]string, error)) {
+	f.values, f.err = fn(ctx)
+	f.cancel()
+
+	fg.lock.Lock()
+	fg.forget(expression, f)
+	fg.lock.Unlock()
+	close(f.done)
+}
+
+// forget removes f as the in-flight call for expression, unless another call
+// has already replaced it.  The lock must be held.
+func (fg *flightGroup) forget(expression string, f *flight) {
+	if fg.flights[expression] == f {
+		delete(fg.flights, expression)
+	}
+}
+
+// waiting returns the number of callers waiting on the in-flight call for
+// expression, other than the first.
+func (fg *flightGroup) waiting(expression string) int {
+	fg.lock.Lock()
+	defer fg.lock.Unlock()
+	if f, ok := fg.flights[expression]; ok {
+		return f.waiters - 1
+	}
+	return 0
+}
+
+// detachedContext carries the values of its parent, but neither its deadline
+// nor its cancellation.
+type detachedContext struct{ parent context.Context }
+
+func (detachedContext) Deadline() (time.Time, bool)          { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}                { return nil }
+func (detachedContext) Err() error                           { return nil }
+func (dc detachedContext) Value(key interface{}) interface{} { return dc.parent.Value(key) }
+
+func copyStrings(values []string) []string {
+	if values == nil {
+		return nil
+	}
+	c := make([]string, len(values))
+	copy(c, values)
+	return c
+}
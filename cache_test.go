@@ -0,0 +1,265 @@
+package orange
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestResultCache(t *testing.T) {
+	now := time.Date(2019, time.June, 1, 12, 0, 0, 0, time.UTC)
+	rc := newResultCache(time.Minute)
+	rc.now = func() time.Time { return now }
+
+	if _, ok := rc.get("foo"); ok {
+		t.Errorf("GOT: %v; WANT: %v", ok, false)
+	}
+
+	rc.set("foo", []string{"result1"})
+	values, ok := rc.get("foo")
+	if !ok {
+		t.Fatalf("GOT: %v; WANT: %v", ok, true)
+	}
+	ensureStringSlicesMatch(t, values, []string{"result1"})
+
+	// Mutating returned values does not modify the cache.
+	values[0] = "mutated"
+	values, _ = rc.get("foo")
+	ensureStringSlicesMatch(t, values, []string{"result1"})
+
+	now = now.Add(time.Minute)
+	if _, ok := rc.get("foo"); ok {
+		t.Errorf("GOT: %v; WANT: %v", ok, false)
+	}
+}
+
+func TestResultCacheLRU(t *testing.T) {
+	t.Run("max entries", func(t *testing.T) {
+		rc := newResultCache(time.Minute)
+		rc.maxEntries = 2
+
+		rc.set("foo", []string{"result1"})
+		rc.set("bar", []string{"result2"})
+		rc.get("foo") // bar is now least recently used
+		rc.set("baz", []string{"result3"})
+
+		if _, ok := rc.get("bar"); ok {
+			t.Errorf("GOT: %v; WANT: %v", ok, false)
+		}
+		for _, expression := range []string{"foo", "baz"} {
+			if _, ok := rc.get(expression); !ok {
+				t.Errorf("%s: GOT: %v; WANT: %v", expression, ok, true)
+			}
+		}
+	})
+
+	t.Run("max bytes", func(t *testing.T) {
+		rc := newResultCache(time.Minute)
+		rc.maxBytes = 20
+
+		rc.set("foo", []string{"result1"}) // 10 bytes
+		rc.set("bar", []string{"result2"}) // 10 bytes
+		rc.set("foo", []string{"r1"})      // replaced with 5 bytes
+		rc.set("baz", []string{"result3"}) // evicts bar
+
+		if got, want := rc.bytes, 15; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if _, ok := rc.get("bar"); ok {
+			t.Errorf("GOT: %v; WANT: %v", ok, false)
+		}
+
+		// Results larger than the bound are not cached, and evict nothing.
+		rc.set("huge", []string{strings.Repeat("x", 21)})
+		if _, ok := rc.get("huge"); ok {
+			t.Errorf("GOT: %v; WANT: %v", ok, false)
+		}
+		for _, expression := range []string{"foo", "baz"} {
+			if _, ok := rc.get(expression); !ok {
+				t.Errorf("%s: GOT: %v; WANT: %v", expression, ok, true)
+			}
+		}
+	})
+}
+
+func TestClientCache(t *testing.T) {
+	var count int32
+	h := func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&count, 1)
+		w.Write([]byte("result1\nresult2\n"))
+	}
+	withTestServer(t, h, func(server *httptest.Server) {
+		client, err := NewClient(&Config{
+			CacheTTL:   time.Hour,
+			HTTPClient: server.Client(),
+			Servers:    []string{strings.TrimLeft(server.URL, "http://")},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for i := 0; i < 3; i++ {
+			values, err := client.Query("foo")
+			if err != nil {
+				t.Fatal(err)
+			}
+			ensureStringSlicesMatch(t, values, []string{"result1", "result2"})
+		}
+
+		if got, want := atomic.LoadInt32(&count), int32(1); got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+
+		stats := client.Stats()
+		if got, want := stats.CacheHits, uint64(2); got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := stats.CacheMisses, uint64(1); got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := stats.CacheHitRatio, 2.0/3; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := stats.CacheRefreshes, uint64(1); got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if stats.AverageCacheRefresh <= 0 {
+			t.Errorf("GOT: %v; WANT: positive duration", stats.AverageCacheRefresh)
+		}
+	})
+}
+
+func TestClientCoalesce(t *testing.T) {
+	const callers = 5
+
+	var count int32
+	release := make(chan struct{})
+	h := func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&count, 1)
+		<-release
+		w.Write([]byte("result1\n"))
+	}
+	withTestServer(t, h, func(server *httptest.Server) {
+		client, err := NewClient(&Config{
+			Coalesce:   true,
+			HTTPClient: server.Client(),
+			Servers:    []string{strings.TrimLeft(server.URL, "http://")},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var wg sync.WaitGroup
+		wg.Add(callers)
+		for i := 0; i < callers; i++ {
+			go func() {
+				defer wg.Done()
+				values, err := client.Query("foo")
+				if err != nil {
+					t.Error(err)
+				}
+				ensureStringSlicesMatch(t, values, []string{"result1"})
+			}()
+		}
+
+		// Wait until every caller but the one sending the query is waiting on
+		// its results.
+		for client.flights.waiting("foo") < callers-1 {
+			time.Sleep(time.Millisecond)
+		}
+		close(release)
+		wg.Wait()
+
+		if got, want := atomic.LoadInt32(&count), int32(1); got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := client.Stats().CoalescedQueries, uint64(callers-1); got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+}
+
+func TestClientCoalesceCanceledCaller(t *testing.T) {
+	// withBlockingClient invokes callback with a coalescing client whose range
+	// server signals started when it receives a query, then answers it once
+	// release is closed, or signals canceled when the query is canceled.
+	withBlockingClient := func(t *testing.T, callback func(client *Client, started, release, canceled chan struct{})) {
+		started := make(chan struct{})
+		release := make(chan struct{})
+		canceled := make(chan struct{})
+		h := func(w http.ResponseWriter, r *http.Request) {
+			close(started)
+			select {
+			case <-release:
+				w.Write([]byte("result1\n"))
+			case <-r.Context().Done():
+				close(canceled)
+			}
+		}
+		withTestServer(t, h, func(server *httptest.Server) {
+			client, err := NewClient(&Config{
+				Coalesce:   true,
+				HTTPClient: server.Client(),
+				Servers:    []string{strings.TrimLeft(server.URL, "http://")},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			callback(client, started, release, canceled)
+		})
+	}
+
+	t.Run("first caller cancels", func(t *testing.T) {
+		withBlockingClient(t, func(client *Client, started, release, _ chan struct{}) {
+			ctx, cancel := context.WithCancel(context.Background())
+			first := make(chan error)
+			go func() {
+				_, err := client.QueryCtx(ctx, "foo")
+				first <- err
+			}()
+			<-started
+
+			second := make(chan error)
+			go func() {
+				values, err := client.QueryCtx(context.Background(), "foo")
+				ensureStringSlicesMatch(t, values, []string{"result1"})
+				second <- err
+			}()
+			for client.flights.waiting("foo") < 1 {
+				time.Sleep(time.Millisecond)
+			}
+
+			cancel()
+			if got, want := <-first, context.Canceled; !errors.Is(got, want) {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+			if got, want := client.flights.waiting("foo"), 0; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+
+			close(release)
+			ensureError(t, <-second)
+		})
+	})
+
+	t.Run("every caller cancels", func(t *testing.T) {
+		withBlockingClient(t, func(client *Client, started, _, canceled chan struct{}) {
+			ctx, cancel := context.WithCancel(context.Background())
+			go func() {
+				<-started
+				cancel()
+			}()
+			_, err := client.QueryCtx(ctx, "foo")
+			if got, want := err, context.Canceled; !errors.Is(got, want) {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+			<-canceled // the query is canceled once no caller waits on it
+		})
+	})
+}
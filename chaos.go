@@ -0,0 +1,123 @@
+package orange
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"syscall"
+)
+
+// Chaos is a Doer that wraps another Doer, either a real HTTP client or a
+// MockConfig, and randomly injects failures at configurable rates, for soak
+// tests that validate how an application copes with an unstable range
+// service.
+//
+//     client, err := orange.NewClient(&orange.Config{
+//         HTTPClient: &orange.Chaos{
+//             Doer:            http.DefaultClient,
+//             TimeoutRate:     0.05,
+//             ServerErrorRate: 0.05,
+//             TruncateRate:    0.01,
+//             ResetRate:       0.01,
+//             Seed:            1,
+//         },
+//         RetryCount: 2,
+//         Servers:    servers,
+//     })
+//
+// Each request suffers at most one kind of failure.  Rates are probabilities
+// from 0 to 1, and their sum should not exceed 1.
+type Chaos struct {
+	// Doer sends the requests that are not failed before being sent.
+	Doer Doer
+
+	// TimeoutRate is the probability that a request fails with a timeout
+	// error without being sent.
+	TimeoutRate float64
+
+	// ServerErrorRate is the probability that a request is answered with a
+	// 5xx status code without being sent.
+	ServerErrorRate float64
+
+	// TruncateRate is the probability that the body of a response is cut
+	// short at a random point, with reading it ending in
+	// io.ErrUnexpectedEOF.
+	TruncateRate float64
+
+	// ResetRate is the probability that a request fails with a connection
+	// reset error without being sent.
+	ResetRate float64
+
+	// Seed seeds the random choices, so a failing soak test is reproducible.
+	Seed int64
+
+	lock sync.Mutex
+	rng  *rand.Rand
+}
+
+// chaosServerErrors are the status codes of injected server errors.
+var chaosServerErrors = []int{
+	http.StatusInternalServerError,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// Do sends request using the wrapped Doer, unless it is chosen to fail.
+func (c *Chaos) Do(request *http.Request) (*http.Response, error) {
+	c.lock.Lock()
+	if c.rng == nil {
+		c.rng = rand.New(rand.NewSource(c.Seed))
+	}
+	roll := c.rng.Float64()
+	pick := c.rng.Int63()
+	c.lock.Unlock()
+
+	if roll -= c.TimeoutRate; roll < 0 {
+		return nil, &net.OpError{Op: "read", Net: "tcp", Err: os.ErrDeadlineExceeded}
+	}
+	if roll -= c.ResetRate; roll < 0 {
+		return nil, &net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)}
+	}
+	if roll -= c.ServerErrorRate; roll < 0 {
+		status := chaosServerErrors[pick%int64(len(chaosServerErrors))]
+		return mockResponse(request, status, make(http.Header), http.StatusText(status)+"\n"), nil
+	}
+
+	response, err := c.Doer.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	if roll -= c.TruncateRate; roll < 0 {
+		buf, err := io.ReadAll(response.Body)
+		_ = response.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		var cut int
+		if len(buf) > 0 {
+			cut = int(pick % int64(len(buf)))
+		}
+		response.Body = io.NopCloser(&truncatedReader{Reader: bytes.NewReader(buf[:cut])})
+		response.ContentLength = -1
+	}
+	return response, nil
+}
+
+// truncatedReader fails once its prefix of a response body has been read, as
+// when a connection is closed mid-response.
+type truncatedReader struct {
+	*bytes.Reader
+}
+
+func (t *truncatedReader) Read(p []byte) (int, error) {
+	n, err := t.Reader.Read(p)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
@@ -0,0 +1,84 @@
+package orange
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"syscall"
+	"testing"
+)
+
+func TestChaos(t *testing.T) {
+	newChaosClient := func(chaos *Chaos) *Client {
+		chaos.Doer = &MockConfig{Results: []string{"host1", "host2", "host3"}}
+		client, err := NewClient(&Config{HTTPClient: chaos, Servers: []string{"mock"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return client
+	}
+
+	t.Run("timeout", func(t *testing.T) {
+		_, err := newChaosClient(&Chaos{TimeoutRate: 1}).Query("foo")
+		if got, want := IsRetryable(err), true; got != want {
+			t.Errorf("GOT: %v; WANT: %v", err, want)
+		}
+	})
+
+	t.Run("reset", func(t *testing.T) {
+		_, err := newChaosClient(&Chaos{ResetRate: 1}).Query("foo")
+		if got, want := errors.Is(err, syscall.ECONNRESET), true; got != want {
+			t.Errorf("GOT: %v; WANT: %v", err, want)
+		}
+	})
+
+	t.Run("server error", func(t *testing.T) {
+		_, err := newChaosClient(&Chaos{ServerErrorRate: 1}).Query("foo")
+		var statusNotOK *ErrStatusNotOK
+		if !errors.As(err, &statusNotOK) {
+			t.Fatalf("GOT: %v; WANT: %T", err, statusNotOK)
+		}
+		if statusNotOK.StatusCode < 500 {
+			t.Errorf("GOT: %v; WANT: 5xx", statusNotOK.StatusCode)
+		}
+	})
+
+	t.Run("truncate", func(t *testing.T) {
+		_, err := newChaosClient(&Chaos{TruncateRate: 1}).Query("foo")
+		if got, want := errors.Is(err, io.ErrUnexpectedEOF), true; got != want {
+			t.Errorf("GOT: %v; WANT: %v", err, want)
+		}
+	})
+
+	t.Run("none", func(t *testing.T) {
+		values, err := newChaosClient(&Chaos{}).Query("foo")
+		if err != nil {
+			t.Fatal(err)
+		}
+		ensureStringSlicesMatch(t, values, []string{"host1", "host2", "host3"})
+	})
+
+	t.Run("seeded", func(t *testing.T) {
+		run := func() []bool {
+			chaos := &Chaos{ServerErrorRate: 0.3, TimeoutRate: 0.2, Seed: 7, Doer: DoerFunc(func(request *http.Request) (*http.Response, error) {
+				return mockResponse(request, http.StatusOK, make(http.Header), "host1\n"), nil
+			})}
+			var failures []bool
+			for i := 0; i < 50; i++ {
+				request, err := http.NewRequest(http.MethodGet, "http://mock/range/list?foo", nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				response, err := chaos.Do(request)
+				failures = append(failures, err != nil || response.StatusCode != http.StatusOK)
+			}
+			return failures
+		}
+		first, second := run(), run()
+		for i := range first {
+			if first[i] != second[i] {
+				t.Fatalf("GOT: %v; WANT: %v", second, first)
+			}
+		}
+	})
+}
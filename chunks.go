@@ -0,0 +1,119 @@
+package orange
+
+import (
+	"context"
+	"fmt"
+	"io"
+)
+
+// QueryChunks sends the query expression to the range servers with the
+// provided query context, and invokes callback with successive chunks of at
+// most chunkSize results, as they are read from the response.  Like
+// QueryForEach, results are stripped of carriage returns and blank results are
+// skipped, but they are neither cached, coalesced, de-duplicated, nor sorted.
+// When callback returns an error, no further results are processed and that
+// error is returned.
+//
+//     err := client.QueryChunks(ctx, "%hugeCluster", 1000, func(hosts []string) error {
+//         return deploy(hosts)
+//     })
+//
+// The results of each chunk share a single allocation, and the chunk slice is
+// reused for the next chunk, so callback must copy the slice, but not the
+// results, to retain them.  When callback retains nothing, memory use is
+// bounded by the chunk size rather than by the size of the response, however
+// many results it holds.
+//
+// Once the first chunk has been delivered, a failure reading the remainder of
+// the response is not retried, so results are never delivered twice.
+func (c *Client) QueryChunks(ctx context.Context, expression string, chunkSize int, callback func([]string) error) error {
+	if chunkSize <= 0 {
+		return fmt.Errorf("cannot query with non-positive chunk size: %d", chunkSize)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var meta Response
+	var delivered bool
+	var chunkErr error
+
+	chunk := make([]string, 0, chunkSize)
+	var arena []byte // bytes of the results of the current chunk
+	var ends []int   // end offset in arena of each result of the current chunk
+
+	flush := func() error {
+		if len(ends) == 0 {
+			return nil
+		}
+		s := string(arena)
+		var start int
+		for _, end := range ends {
+			chunk = append(chunk, s[start:end])
+			start = end
+		}
+		err := callback(chunk)
+		for i := range chunk {
+			chunk[i] = "" // release results callback did not retain
+		}
+		chunk, arena, ends = chunk[:0], arena[:0], ends[:0]
+		delivered = true
+		return err
+	}
+
+	err := c.queryCallback(ctx, expression, func(ior io.Reader) error {
+		chunk, arena, ends = chunk[:0], arena[:0], ends[:0] // discard a failed attempt
+
+		var results int
+		buf := c.scanBuffers.Get().(*[]byte)
+		err := scanLineBytes(ior, (*buf)[:0], c.maxLineLength, meta.Delimiter, func(line []byte) error {
+			results++
+			arena = append(arena, line...)
+			ends = append(ends, len(arena))
+			if len(ends) == chunkSize {
+				return flush()
+			}
+			return nil
+		})
+		c.scanBuffers.Put(buf)
+		c.stats.addResults(results)
+		if err == nil {
+			err = flush()
+		}
+		if err != nil && delivered {
+			// Stop before another attempt could deliver the same results
+			// again.
+			chunkErr = err
+			cancel()
+		}
+		return err
+	}, &meta)
+
+	if chunkErr != nil {
+		return chunkErr
+	}
+	return err
+}
+
+// forEachChunk invokes callback with successive chunks of at most chunkSize of
+// the results that forEach yields, for queriers that already hold their
+// results in memory.
+func forEachChunk(chunkSize int, forEach func(func(string) error) error, callback func([]string) error) error {
+	if chunkSize <= 0 {
+		return fmt.Errorf("cannot query with non-positive chunk size: %d", chunkSize)
+	}
+	chunk := make([]string, 0, chunkSize)
+	err := forEach(func(result string) error {
+		chunk = append(chunk, result)
+		if len(chunk) < chunkSize {
+			return nil
+		}
+		err := callback(chunk)
+		chunk = chunk[:0]
+		return err
+	})
+	if err != nil || len(chunk) == 0 {
+		return err
+	}
+	return callback(chunk)
+}
@@ -0,0 +1,99 @@
+package orange
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestQueryChunks(t *testing.T) {
+	var want []string
+	for i := 0; i < 10; i++ {
+		want = append(want, fmt.Sprintf("host%d", i))
+	}
+	body := strings.Join(want, "\r\n") + "\n\n"
+
+	t.Run("chunk sizes", func(t *testing.T) {
+		withClient(t, func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(body))
+		}, func(client *Client) {
+			var sizes []int
+			var got []string
+			err := client.QueryChunks(context.Background(), "%someCluster", 3, func(chunk []string) error {
+				sizes = append(sizes, len(chunk))
+				got = append(got, chunk...)
+				return nil
+			})
+			ensureError(t, err)
+			ensureStringSlicesMatch(t, got, want)
+			if got, want := fmt.Sprint(sizes), "[3 3 3 1]"; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+		})
+	})
+
+	t.Run("non-positive chunk size", func(t *testing.T) {
+		withClient(t, func(w http.ResponseWriter, r *http.Request) {
+			t.Error("query should not be sent")
+		}, func(client *Client) {
+			err := client.QueryChunks(context.Background(), "%someCluster", 0, func([]string) error { return nil })
+			ensureError(t, err, "non-positive chunk size")
+		})
+	})
+
+	t.Run("callback error", func(t *testing.T) {
+		withClient(t, func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(body))
+		}, func(client *Client) {
+			var calls int
+			err := client.QueryChunks(context.Background(), "%someCluster", 4, func([]string) error {
+				calls++
+				return errors.New("callback failed")
+			})
+			ensureError(t, err, "callback failed")
+			if got, want := calls, 1; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+		})
+	})
+
+	t.Run("truncated after delivery is not retried", func(t *testing.T) {
+		var requests int32
+		withClient(t, func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&requests, 1)
+			w.Header().Set("Content-Length", "1000")
+			_, _ = w.Write([]byte(body))
+		}, func(client *Client) {
+			var got []string
+			err := client.QueryChunks(context.Background(), "%someCluster", 3, func(chunk []string) error {
+				got = append(got, chunk...)
+				return nil
+			})
+			ensureError(t, err, "unexpected EOF")
+			ensureStringSlicesMatch(t, got, want[:9])
+			if got, want := atomic.LoadInt32(&requests), int32(1); got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+		})
+	})
+}
+
+func TestMockQuerierQueryChunks(t *testing.T) {
+	querier := NewMockQuerier(&MockConfig{Queries: map[string]MockResult{
+		"%cluster1": {Results: []string{"host1", "host2", "host3"}},
+	}})
+
+	var chunks [][]string
+	err := querier.QueryChunks(context.Background(), "%cluster1", 2, func(chunk []string) error {
+		chunks = append(chunks, append([]string(nil), chunk...))
+		return nil
+	})
+	ensureError(t, err)
+	if got, want := fmt.Sprint(chunks), "[[host1 host2] [host3]]"; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+}
@@ -1,15 +1,19 @@
 package orange
 
 import (
-	"bufio"
 	"context"
+	"errors"
 	"fmt"
+	"hash/fnv"
 	"io"
 	"io/ioutil"
 	"net"
 	"net/http"
 	"net/url"
+	"runtime/pprof"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -18,17 +22,61 @@ import (
 // sent out via a PUT query.
 const defaultQueryURILengthThreshold = 4096
 
+// queryFormPrefix precedes the escaped expression in the body of a PUT query.
+const queryFormPrefix = "query="
+
+// The pprof label keys attached to go-routines executing queries.
+const (
+	pprofLabelExpression = "orange_expression"
+	pprofLabelServer     = "orange_server"
+)
+
 // Client provides a Query method that resolves range queries.
 type Client struct {
 	// The only thing that prevents us from exposing a structure with all public
 	// fields is the fact that we need to create the round robin list of
 	// servers, and validate other config parameters.
-	httpClient    Doer
-	userAgent     string
-	servers       *roundRobinStrings
-	retryCallback func(error) bool
-	retryCount    int
-	retryPause    time.Duration
+	config                    Config // effective configuration, for ConfigSnapshot
+	httpClient                Doer
+	userAgent                 string
+	headers                   http.Header
+	servers                   *roundRobinStrings
+	scheme                    string
+	path                      string
+	addPath                   string
+	removePath                string
+	reverseOperator           string
+	authProvider              func(*http.Request) error
+	sorted                    bool
+	unique                    bool
+	zeroCopy                  bool
+	allowWrites               bool
+	scanBuffers               sync.Pool
+	batchWorkers              *workerPool
+	batchRequests             bool
+	batchUnsupported          uint32 // set atomically when a server rejects batch requests
+	retryCallback             func(error) bool
+	retryCount                int
+	retryPause                time.Duration
+	retryWrites               bool
+	clock                     Clock
+	stats                     *stats
+	inFlight                  *inFlight
+	rateLimiter               *rateLimiter
+	concurrency               *concurrencyLimiter
+	recentErrors              *recentErrors
+	maxErrorBodyBytes         int
+	maxLineLength             int
+	delimiter                 byte
+	responseHeaders           []string
+	includeExpressionInErrors bool
+	cache                     *resultCache
+	flights                   *flightGroup
+	queryFunc                 QueryFunc
+	rewriteExpression         func(string) (string, error)
+	onAttempt                 func(AttemptEvent)
+	onErrorReport             func(QueryError)
+	debugf                    func(string, ...interface{})
 }
 
 // NewClient returns a new instance that sends queries to one or more range
@@ -71,17 +119,105 @@ func NewClient(config *Config) (*Client, error) {
 	if config.RetryPause < 0 {
 		return nil, fmt.Errorf("cannot create Client with negative RetryPause: %s", config.RetryPause)
 	}
-	rrs, err := newRoundRobinStrings(config.Servers)
+	if config.CacheTTL < 0 {
+		return nil, fmt.Errorf("cannot create Client with negative CacheTTL: %s", config.CacheTTL)
+	}
+	if config.CacheMaxEntries < 0 {
+		return nil, fmt.Errorf("cannot create Client with negative CacheMaxEntries: %d", config.CacheMaxEntries)
+	}
+	if config.CacheMaxBytes < 0 {
+		return nil, fmt.Errorf("cannot create Client with negative CacheMaxBytes: %d", config.CacheMaxBytes)
+	}
+	if config.MaxLineLength < 0 {
+		return nil, fmt.Errorf("cannot create Client with negative MaxLineLength: %d", config.MaxLineLength)
+	}
+	if config.BatchWorkers < 0 {
+		return nil, fmt.Errorf("cannot create Client with negative BatchWorkers: %d", config.BatchWorkers)
+	}
+	if config.ScanBufferSize < 0 {
+		return nil, fmt.Errorf("cannot create Client with negative ScanBufferSize: %d", config.ScanBufferSize)
+	}
+	if config.MaxConcurrentQueries < 0 {
+		return nil, fmt.Errorf("cannot create Client with negative MaxConcurrentQueries: %d", config.MaxConcurrentQueries)
+	}
+	if config.MaxErrorBodyBytes < 0 {
+		return nil, fmt.Errorf("cannot create Client with negative MaxErrorBodyBytes: %d", config.MaxErrorBodyBytes)
+	}
+	if config.RateLimit.QPS < 0 {
+		return nil, fmt.Errorf("cannot create Client with negative RateLimit.QPS: %g", config.RateLimit.QPS)
+	}
+	if config.RateLimit.Burst < 0 {
+		return nil, fmt.Errorf("cannot create Client with negative RateLimit.Burst: %d", config.RateLimit.Burst)
+	}
+	if config.RecentErrors < 0 {
+		return nil, fmt.Errorf("cannot create Client with negative RecentErrors: %d", config.RecentErrors)
+	}
+	servers := config.Servers
+	if config.Snapshot != nil && len(servers) == 0 {
+		servers = []string{"snapshot"}
+	}
+	rrs, err := newRoundRobinStrings(servers)
 	if err != nil {
 		return nil, fmt.Errorf("cannot create Client without at least one range server address")
 	}
 
+	scheme := config.Scheme
+	switch scheme {
+	case "":
+		scheme = "http"
+	case "http", "https":
+	default:
+		return nil, fmt.Errorf("cannot create Client with unsupported Scheme: %q", config.Scheme)
+	}
+	path := config.Path
+	if path == "" {
+		path = DefaultPath
+	} else if !strings.HasPrefix(path, "/") {
+		return nil, fmt.Errorf("cannot create Client with Path not starting with a slash: %q", config.Path)
+	}
+	addPath := config.AddPath
+	if addPath == "" {
+		addPath = siblingPath(path, "add")
+	} else if !strings.HasPrefix(addPath, "/") {
+		return nil, fmt.Errorf("cannot create Client with AddPath not starting with a slash: %q", config.AddPath)
+	}
+	removePath := config.RemovePath
+	if removePath == "" {
+		removePath = siblingPath(path, "remove")
+	} else if !strings.HasPrefix(removePath, "/") {
+		return nil, fmt.Errorf("cannot create Client with RemovePath not starting with a slash: %q", config.RemovePath)
+	}
+
+	for _, server := range servers {
+		if err := validateServerURL(server); err != nil {
+			return nil, fmt.Errorf("cannot create Client with invalid server URL: %w", err)
+		}
+	}
+
+	maxLineLength := config.MaxLineLength
+	if maxLineLength == 0 {
+		maxLineLength = DefaultMaxLineLength
+	}
+
+	maxErrorBodyBytes := config.MaxErrorBodyBytes
+	if maxErrorBodyBytes == 0 {
+		maxErrorBodyBytes = DefaultMaxErrorBodyBytes
+	}
+
+	recentErrorsSize := config.RecentErrors
+	if recentErrorsSize == 0 {
+		recentErrorsSize = DefaultRecentErrors
+	}
+
 	retryCallback := config.RetryCallback
 	if retryCallback == nil {
-		retryCallback = makeRetryCallback(len(config.Servers))
+		retryCallback = makeRetryCallback(len(servers))
 	}
 
 	httpClient := config.HTTPClient
+	if config.Snapshot != nil {
+		httpClient = config.Snapshot
+	}
 	if httpClient == nil {
 		httpClient = &http.Client{
 			// WARNING: Using http.Client instance without a Timeout will cause
@@ -101,17 +237,123 @@ func NewClient(config *Config) (*Client, error) {
 	}
 
 	client := &Client{
-		httpClient:    httpClient,
-		retryCallback: retryCallback,
-		retryCount:    config.RetryCount,
-		retryPause:    config.RetryPause,
-		servers:       rrs,
+		debugf:                    config.Debugf,
+		httpClient:                httpClient,
+		includeExpressionInErrors: config.IncludeExpressionInErrors,
+		maxErrorBodyBytes:         maxErrorBodyBytes,
+		maxLineLength:             maxLineLength,
+		delimiter:                 config.Delimiter,
+		onAttempt:                 config.OnAttempt,
+		onErrorReport:             config.OnErrorReport,
+		recentErrors:              newRecentErrors(recentErrorsSize),
+		retryCallback:             retryCallback,
+		retryCount:                config.RetryCount,
+		retryPause:                config.RetryPause,
+		retryWrites:               config.RetryWrites,
+		rewriteExpression:         config.RewriteExpression,
+		servers:                   rrs,
+		scheme:                    scheme,
+		path:                      path,
+		addPath:                   addPath,
+		removePath:                removePath,
+		reverseOperator:           config.ReverseOperator,
+		sorted:                    config.Sorted,
+		unique:                    config.Unique,
+		zeroCopy:                  config.ZeroCopy,
+		allowWrites:               config.AllowWrites,
+		authProvider:              config.AuthProvider,
+		batchRequests:             config.BatchRequests,
+		stats:                     newStats(),
+		inFlight:                  newInFlight(),
+	}
+
+	scanBufferSize := config.ScanBufferSize
+	if scanBufferSize == 0 {
+		scanBufferSize = DefaultScanBufferSize
+	}
+	if scanBufferSize > maxLineLength {
+		scanBufferSize = maxLineLength
+	}
+	client.scanBuffers.New = func() interface{} {
+		buf := make([]byte, scanBufferSize)
+		return &buf
+	}
+
+	client.clock = config.Clock
+	if client.clock == nil {
+		client.clock = realClock{}
 	}
+	client.stats.now = client.clock.Now
 
 	if config.UserAgent != "" {
 		client.userAgent = config.UserAgent
 	}
 
+	// Canonicalize the header names once, rather than for every request.
+	if len(config.Headers) > 0 {
+		client.headers = make(http.Header, len(config.Headers))
+		for key, values := range config.Headers {
+			client.headers[http.CanonicalHeaderKey(key)] = append([]string(nil), values...)
+		}
+	}
+
+	if client.reverseOperator == "" {
+		client.reverseOperator = DefaultReverseOperator
+	}
+
+	client.responseHeaders = config.ResponseHeaders
+	if client.responseHeaders == nil {
+		client.responseHeaders = DefaultResponseHeaders
+	}
+
+	if config.CacheTTL > 0 {
+		client.cache = newResultCache(config.CacheTTL)
+		client.cache.now = client.clock.Now
+		client.cache.maxEntries = config.CacheMaxEntries
+		client.cache.maxBytes = config.CacheMaxBytes
+	}
+
+	if config.MaxConcurrentQueries > 0 {
+		client.concurrency = newConcurrencyLimiter(config.MaxConcurrentQueries)
+	}
+
+	if config.RateLimit.QPS > 0 {
+		client.rateLimiter = newRateLimiter(config.RateLimit, client.clock.Now)
+	}
+
+	if config.Coalesce {
+		client.flights = newFlightGroup()
+	}
+
+	if config.BatchWorkers > 0 {
+		client.batchWorkers = newWorkerPool(config.BatchWorkers)
+	}
+
+	if config.PrewarmTimeout > 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), config.PrewarmTimeout)
+		_ = client.Prewarm(ctx) // prewarming is an optimization, so failures are ignored
+		cancel()
+	}
+
+	client.queryFunc = chainInterceptors(config.Interceptors, client.queryCtx)
+
+	// Record the configuration with its defaults applied.
+	client.config = *config
+	client.config.AddPath = addPath
+	client.config.Clock = client.clock
+	client.config.HTTPClient = httpClient
+	client.config.MaxErrorBodyBytes = maxErrorBodyBytes
+	client.config.MaxLineLength = maxLineLength
+	client.config.Path = path
+	client.config.RemovePath = removePath
+	client.config.ReverseOperator = client.reverseOperator
+	client.config.RecentErrors = recentErrorsSize
+	client.config.ResponseHeaders = client.responseHeaders
+	client.config.RetryCallback = retryCallback
+	client.config.ScanBufferSize = scanBufferSize
+	client.config.Scheme = scheme
+	client.config.Servers = rrs.values
+
 	return client, nil
 }
 
@@ -122,9 +364,16 @@ func NewClient(config *Config) (*Client, error) {
 // particular query results in an error, the query is retried according to the
 // client's RetryCount setting.
 //
-// If a response includes a RangeException header, it returns ErrRangeException.
-// If a query's response HTTP status code is not okay, it returns
-// ErrStatusNotOK.
+// Errors from range servers are returned wrapped in a *QueryError that
+// identifies the server, HTTP method, and number of attempts.  If a response
+// includes a RangeException header, the wrapped error is *ErrRangeException.
+// If a query's response HTTP status code is not okay, the wrapped error is
+// *ErrStatusNotOK, or when the status is 429 Too Many Requests,
+// *ErrTooManyRequests.  Use errors.As to inspect any of these errors.  When
+// none of the configured range servers could be reached, the returned error
+// also wraps ErrNoServersAvailable.
+//
+// Results share memory with one another as described by QueryCtx.
 //
 //     func main() {
 //         // Create a range client.  Programs can list more than one server and
@@ -166,6 +415,20 @@ func (c *Client) Query(expression string) ([]string, error) {
 // HTTPClient argument to the Config so the two timeouts do not cause unexpected
 // results.
 //
+// When the client is configured with Unique, duplicate results are removed.
+// When the client is configured with Sorted, results are sorted in the natural
+// order defined by CompareHosts.  When the client is configured with a
+// CacheTTL, successful results are cached and returned to subsequent callers
+// until they expire.  When the client is configured to Coalesce queries,
+// concurrent callers querying the same expression share the results of a
+// single query.
+//
+// To reduce allocations, results are substrings of strings holding several
+// kilobytes of results each, so a caller that retains one result retains the
+// others sharing its string.  Callers keeping a few results of large queries
+// for a long time should copy them, such as by strings.Clone.  See
+// Config.ZeroCopy, which shares a single string among all results.
+//
 //     func main() {
 //         optTimeout := flag.Duration("timeout", 0, "timeout duration for the query")
 //         flag.Parse()
@@ -188,7 +451,7 @@ func (c *Client) Query(expression string) ([]string, error) {
 //         }
 //
 //         if flag.NArg() == 0 {
-//             fmt.Fprintf(os.Stderr, "USAGE: %s [-timeout DURATION] q1 q2\n")
+//             fmt.Fprintf(os.Stderr, "USAGE: %s [-timeout DURATION] q1 q2\n", filepath.Base(os.Args[0]))
 //             os.Exit(1)
 //         }
 //
@@ -200,66 +463,335 @@ func (c *Client) Query(expression string) ([]string, error) {
 //
 //         fmt.Println(values)
 //     }
-func (c *Client) QueryCtx(ctx context.Context, expression string) (lines []string, err error) {
-	err = c.QueryCallback(ctx, expression, func(ior io.Reader) error {
-		s := bufio.NewScanner(ior)
-		for s.Scan() {
-			lines = append(lines, s.Text())
+//
+// When the client is configured with Interceptors, they wrap all of the above.
+func (c *Client) QueryCtx(ctx context.Context, expression string) ([]string, error) {
+	return c.queryFunc(ctx, expression)
+}
+
+// queryCtx is QueryCtx without the configured interceptors.
+func (c *Client) queryCtx(ctx context.Context, expression string) ([]string, error) {
+	if c.cache != nil {
+		if values, ok := c.cache.get(expression); ok {
+			c.stats.addCacheHit()
+			return values, nil
 		}
-		return s.Err()
+		c.stats.addCacheMiss()
+	}
+
+	if c.flights == nil {
+		return c.queryLines(ctx, expression)
+	}
+
+	values, shared, err := c.flights.do(ctx, expression, func(ctx context.Context) ([]string, error) {
+		return c.queryLines(ctx, expression)
 	})
+	if shared {
+		c.stats.addCoalesced()
+	}
+	return values, err
+}
+
+// queryLines sends the query expression to the range client and returns the
+// response lines, removing duplicates and sorting them when configured to, and
+// storing them in the cache when caching is enabled.
+func (c *Client) queryLines(ctx context.Context, expression string) ([]string, error) {
+	var start time.Time
+	if c.cache != nil {
+		start = c.clock.Now()
+	}
+
+	lines, err := c.requestLines(ctx, &Request{Expression: expression})
+
+	if err == nil && c.cache != nil {
+		c.cache.set(expression, lines)
+		c.stats.addCacheRefresh(c.clock.Now().Sub(start))
+	}
+	return lines, err
+}
+
+// requestLines sends the request to the range servers and returns the response
+// lines, removing duplicates and sorting them when configured to.
+func (c *Client) requestLines(ctx context.Context, rr *Request) (lines []string, err error) {
+	var meta Response
+	rr.Callback = func(ior io.Reader) error {
+		lines = make([]string, 0, estimateLines(meta.contentLength))
+		if c.zeroCopy {
+			body, err := readStringPooled(ior)
+			if err != nil {
+				return err
+			}
+			err = splitLines(body, c.maxLineLength, meta.Delimiter, func(line string) error {
+				lines = append(lines, line)
+				return nil
+			})
+			c.stats.addResults(len(lines))
+			return err
+		}
+		var err error
+		lines, err = c.appendLines(lines, ior, meta.Delimiter)
+		return err
+	}
+	err = c.do(ctx, rr, &meta)
+
+	if len(lines) == 0 {
+		lines = nil // as when no lines were appended
+	}
+
+	if err == nil && c.unique {
+		lines = Unique(lines)
+	}
+
+	if err == nil && c.sorted {
+		SortHosts(lines)
+	}
 	return
 }
 
+// QueryForEach sends the query expression to the range client with the provided
+// query context, and invokes callback for each line of the response, without
+// first collecting the lines into a slice.  Like Query and QueryCtx, lines are
+// stripped of carriage returns and blank lines are skipped.  When callback
+// returns an error, no further lines are processed and that error is returned.
+//
+//     err := client.QueryForEach(ctx, "%someCluster", func(host string) error {
+//         fmt.Println(host)
+//         return nil
+//     })
+func (c *Client) QueryForEach(ctx context.Context, expression string, callback func(string) error) error {
+	var meta Response
+	return c.queryCallback(ctx, expression, func(ior io.Reader) error {
+		return c.scanLines(ior, meta.Delimiter, callback)
+	}, &meta)
+}
+
 // QueryCallback sends the query expression to the range client with the
 // provided query context.  Upon successful response, invokes specified callback
 // function with an io.Reader configured to read the response body from the
 // range server.
+//
+// When the context is done before the query completes, the returned error
+// wraps ctx.Err(), and when the context was canceled with a cause, such as by
+// context.WithCancelCause, it also wraps that cause.
+//
+// Queries are executed on the calling go-routine, which is labeled while they
+// run with pprof labels for a hash of the expression and the server being
+// queried, so CPU and go-routine profiles of the application show which range
+// queries dominate.  Requests carry ctx, so a custom HTTPClient should honor
+// the request context for queries to return promptly when it is done.
 func (c *Client) QueryCallback(ctx context.Context, expression string, callback func(io.Reader) error) error {
-	done := ctx.Done()
-	ch := make(chan struct{})
-	var err error
-
-	// Spawn a go-routine to send queries to one or more range servers, as
-	// allowed by the client's Servers and Retry settings.
-	go func() {
-		var attempts int
-
-		for {
-			// If not first attempt, and there is a retry pause, then wait.
-			// This logic will neither sleep on the first attempt nor after the
-			// final attempt.
-			if attempts > 0 && c.retryPause > 0 {
-				time.Sleep(c.retryPause)
-
-				// After wake-up, ensure context has not closed, and return
-				// early if it has without sending another query whose results
-				// will be simply thrown away.
-				select {
-				case <-done:
-					return
-				default:
-				}
+	return c.queryCallback(ctx, expression, callback, nil)
+}
+
+// queryCallback sends the query expression to the range servers, and when meta
+// is not nil, records in it metadata describing how the query was resolved.
+func (c *Client) queryCallback(ctx context.Context, expression string, callback func(io.Reader) error, meta *Response) error {
+	return c.do(ctx, &Request{Expression: expression, Callback: callback}, meta)
+}
+
+// do sends the request to the range servers, and when meta is not nil, records
+// in it metadata describing how the request was resolved.
+func (c *Client) do(ctx context.Context, rr *Request, meta *Response) error {
+	expression, err := c.rewrite(rr.Expression)
+	if err != nil {
+		return err
+	}
+
+	c.inFlight.add()
+	defer c.inFlight.done()
+
+	if c.concurrency != nil {
+		priority, _ := queryPriority(ctx)
+		if err := c.concurrency.acquire(ctx, priority); err != nil {
+			return c.canceled(ctx, expression)
+		}
+		defer c.concurrency.release()
+	}
+
+	if err := c.waitRateLimit(ctx); err != nil {
+		if ctx.Err() != nil {
+			return c.canceled(ctx, expression)
+		}
+		c.failed(expression, err)
+		return err
+	}
+	c.stats.addQuery()
+
+	retryCount := c.retryCount
+	if rr.retryCount != nil {
+		retryCount = *rr.retryCount
+	}
+
+	// Queries are sent to one or more range servers, as allowed by the
+	// client's Servers and Retry settings, on the caller's go-routine.  Each
+	// request carries ctx, so it returns promptly once ctx is done.
+	var attempts, retries int
+	var unreachable map[string]struct{}
+	var failover bool
+
+	// The query may be sent to any of the servers, unless it is pinned to one.
+	candidates := c.servers.Distinct()
+	if rr.server != "" {
+		candidates = 1
+	}
+
+	// Measure the escaped expression once for all attempts, so retrying a very
+	// long expression neither escapes nor measures it again each time.
+	escaped := newEscapedExpression(expression)
+
+	for {
+		// If not first attempt, and there is a retry pause, then wait.  This
+		// logic will neither sleep on the first attempt nor after the final
+		// attempt.
+		if attempts > 0 && !failover && c.retryPause > 0 {
+			// Return early when the context closes during the pause, without
+			// sending another query whose results will be simply thrown away.
+			select {
+			case <-c.clock.After(c.retryPause):
+			case <-ctx.Done():
+				return c.canceled(ctx, expression)
 			}
+		}
 
-			err = c.query(ctx, expression, callback, c.servers.Next())
-			if err == nil || attempts == c.retryCount || c.retryCallback(err) == false {
-				close(ch)
-				return
+		server := rr.server
+		if server == "" {
+			server = c.servers.NextExcept(unreachable)
+		}
+
+		// Label the go-routine so CPU and go-routine profiles of the
+		// application show which range queries dominate.
+		var err error
+		pprof.Do(ctx, pprof.Labels(pprofLabelExpression, expressionHash(expression), pprofLabelServer, server), func(ctx context.Context) {
+			err = c.query(ctx, rr, escaped, server, attempts, meta)
+		})
+		if err != nil && ctx.Err() != nil {
+			return c.canceled(ctx, expression)
+		}
+		if err != nil {
+			c.recentErrors.add(RecentError{
+				Time:       c.clock.Now(),
+				Server:     server,
+				Expression: expression,
+				Err:        err,
+			})
+		}
+		// Retry decisions are made using the underlying error rather than the
+		// QueryError wrapping it.
+		retryErr := err
+		if qe, ok := err.(*QueryError); ok {
+			qe.Attempts = attempts + 1
+			retryErr = qe.Err
+		}
+
+		// Track servers that could not be reached, so when every server is
+		// unreachable, callers can distinguish range being down from a bad
+		// query.
+		if retryErr != nil && isUnreachable(retryErr) {
+			if unreachable == nil {
+				unreachable = make(map[string]struct{})
 			}
+			unreachable[server] = struct{}{}
+		}
+
+		// A server that could not be reached never received the query, so
+		// the query fails over to a server not yet tried, regardless of the
+		// Retry settings, until every server has been tried.
+		failover = err != nil && isUnreachable(retryErr) && len(unreachable) < candidates
 
-			attempts++
+		if err == nil || (!failover && (retries == retryCount || c.retryCallback(retryErr) == false)) {
+			if err != nil && len(unreachable) == candidates && isUnreachable(retryErr) {
+				err = fmt.Errorf("%w: %w", ErrNoServersAvailable, err)
+			}
+			if meta != nil {
+				meta.Attempts = attempts + 1
+			}
+			if err != nil {
+				c.failed(expression, err)
+			}
+			return err
 		}
-	}()
 
-	// Block and wait for either a response or the context to be closed by the
-	// caller.
-	select {
-	case <-done:
-		return ctx.Err()
-	case <-ch:
+		attempts++
+		if !failover {
+			retries++
+		}
+		c.stats.addRetry()
+	}
+}
+
+// canceled records and returns the error of a query abandoned because ctx is
+// done.
+func (c *Client) canceled(ctx context.Context, expression string) error {
+	cerr := contextError(ctx)
+	c.failed(expression, cerr)
+	return cerr
+}
+
+// failed accounts for a query that failed after all retries were exhausted,
+// and reports it to the OnErrorReport hook when one is configured.
+func (c *Client) failed(expression string, err error) {
+	c.stats.addError()
+
+	if c.onErrorReport == nil {
+		return
+	}
+	report := QueryError{Expression: expression, Err: err}
+	var qe *QueryError
+	if errors.As(err, &qe) {
+		report = *qe
+	}
+	c.onErrorReport(report)
+}
+
+// authenticate adds credentials to request using Config.AuthProvider.
+func (c *Client) authenticate(request *http.Request) error {
+	if c.authProvider == nil {
+		return nil
+	}
+	if err := c.authProvider(request); err != nil {
+		return fmt.Errorf("cannot authenticate request: %w", err)
+	}
+	return nil
+}
+
+// serverURL returns the URL to which paths are appended to send requests to
+// server, which is either an address or a URL.
+func (c *Client) serverURL(server string) string {
+	if strings.Contains(server, "://") {
+		return strings.TrimSuffix(server, "/")
+	}
+	return c.scheme + "://" + server
+}
+
+// validateServerURL returns an error when server is a URL that cannot be used
+// to send requests.  Addresses are not validated.
+func validateServerURL(server string) error {
+	if !strings.Contains(server, "://") {
+		return nil
+	}
+	u, err := url.Parse(server)
+	if err != nil {
 		return err
 	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("unsupported scheme: %q", server)
+	}
+	if u.Host == "" || u.RawQuery != "" || u.Fragment != "" {
+		return fmt.Errorf("URL must have a host, and neither a query nor a fragment: %q", server)
+	}
+	return nil
+}
+
+// rewrite returns expression as rewritten by Config.RewriteExpression.
+func (c *Client) rewrite(expression string) (string, error) {
+	if c.rewriteExpression == nil {
+		return expression, nil
+	}
+	rewritten, err := c.rewriteExpression(expression)
+	if err != nil {
+		return "", fmt.Errorf("cannot rewrite expression: %w", err)
+	}
+	return rewritten, nil
 }
 
 // query attempts to fetch the results from querying a range server with the
@@ -270,25 +802,52 @@ func (c *Client) QueryCallback(ctx context.Context, expression string, callback
 // the range server returns method not allowed response.  When the resulting URI
 // is or exceeds a configured limit, it prefers using the PUT method, but will
 // re-send the query using the GET method if the range server returns a Method
-// Not Allowed,
-func (c *Client) query(ctx context.Context, expression string, callback func(io.Reader) error, server string) error {
-	var err, prevErr error
+// Not Allowed,  The caller escapes the expression once for all attempts.  The
+// body of a PUT query escapes the expression as it is sent, so the escaped form
+// of a giant expression is never held in memory.
+//
+// When the client has an OnAttempt hook or debug logging configured, each HTTP
+// request is traced and reported once it completes.  When meta is not nil, the
+// server, method, and captured headers of a successful response are recorded in
+// it.
+//
+// Returned errors are wrapped in a *QueryError identifying the server and the
+// HTTP method of the final request.
+func (c *Client) query(ctx context.Context, rr *Request, escaped *escapedExpression, server string, attempt int, meta *Response) (err error) {
+	var prevErr error
 	var request *http.Request
 	var wasGetTried, wasPutTried bool
 
-	endpoint := "http://" + server + "/range/list"
-	escaped := url.QueryEscape(expression)
-	uri := endpoint + "?" + escaped
+	expression := escaped.expression
+	path := rr.Path
+	if path == "" {
+		path = c.path
+	}
+	endpoint := c.serverURL(server) + path
 
 	// Default to using GET method because most servers support it. However, use
 	// PUT method when extremely long query length.
-	var method string
-	if len(uri) > defaultQueryURILengthThreshold {
-		method = http.MethodPut
-	} else {
-		method = http.MethodGet
+	method := rr.Method
+	if method == "" {
+		if len(endpoint)+1+escaped.length > defaultQueryURILengthThreshold {
+			method = http.MethodPut
+		} else {
+			method = http.MethodGet
+		}
 	}
 
+	defer func() {
+		if err != nil {
+			err = &QueryError{
+				Expression:        expression,
+				Server:            server,
+				Method:            method,
+				Err:               err,
+				includeExpression: c.includeExpressionInErrors,
+			}
+		}
+	}()
+
 	for {
 		switch method {
 		case http.MethodGet:
@@ -297,7 +856,7 @@ func (c *Client) query(ctx context.Context, expression string, callback func(io.
 			}
 			wasGetTried = true
 
-			request, err = http.NewRequest(method, uri, nil)
+			request, err = http.NewRequest(method, endpoint+"?"+escaped.String(), nil)
 			if err != nil {
 				method = http.MethodPut // try again using PUT
 				prevErr = err
@@ -309,25 +868,36 @@ func (c *Client) query(ctx context.Context, expression string, callback func(io.
 			}
 			wasPutTried = true
 
-			request, err = http.NewRequest(method, endpoint, strings.NewReader("query="+escaped))
+			request, err = http.NewRequest(method, endpoint, escaped.formReader())
 			if err != nil {
 				method = http.MethodGet // try again using GET
 				prevErr = err
 				continue
 			}
+			request.ContentLength = escaped.formLength()
+			request.GetBody = func() (io.ReadCloser, error) { return escaped.formReader(), nil }
 			request.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 		default:
 			panic(fmt.Errorf("this library should not have specified unsupported HTTP method: %q", method))
 		}
 
-		// Set the user agent so servers have more information about their clients
-		if c.userAgent != "" {
-			request.Header.Set("User-Agent", c.userAgent)
+		// Set the user agent so servers have more information about their
+		// clients, along with the configured headers.
+		c.setHeaders(ctx, request.Header)
+		for key, values := range rr.Header {
+			request.Header[http.CanonicalHeaderKey(key)] = values
 		}
 
-		// Attach the context and dispatch the request.
-		response, err := c.httpClient.Do(request.WithContext(ctx))
+		// Attach the context, authenticate, and dispatch the request.
+		recorder := c.newAttemptRecorder(expression, server, method, attempt)
+		request = request.WithContext(withAttempt(recorder.withContext(ctx), attempt))
+		if err = c.authenticate(request); err != nil {
+			recorder.done(nil, err)
+			return err
+		}
+		response, err := c.httpClient.Do(request)
 		if err != nil {
+			recorder.done(nil, err)
 			return err
 		}
 
@@ -335,40 +905,68 @@ func (c *Client) query(ctx context.Context, expression string, callback func(io.
 		// condition encoded in the response.
 		if response.StatusCode == http.StatusOK {
 			if message := response.Header.Get("RangeException"); message != "" {
-				return ErrRangeException{Message: message}
+				e := newErrRangeException(message)
+				// Read response body and include its text in the error.
+				if buf, err := c.readErrorBody(response.Body); err == nil && len(buf) > 0 {
+					e.Body = buf
+				}
+				recorder.done(response, e)
+				return e
 			}
 			//
 			// NORMAL EXIT PATH: range server provided non-error response
 			//
-			prevErr = callback(response.Body)
-			err = discard(response.Body)
+			if meta != nil {
+				meta.Server = server
+				meta.Method = method
+				meta.Header = captureHeaders(response.Header, c.responseHeaders)
+				meta.Delimiter = c.responseDelimiter(response.Header)
+				meta.Warnings = response.Header.Values("Warning")
+				meta.contentLength = response.ContentLength
+			}
+			body := &countingReadCloser{ReadCloser: response.Body}
+			prevErr = rr.Callback(body)
+			err = discard(body)
+			c.stats.addReceived(body.bytes)
 			if prevErr != nil {
-				return prevErr
+				err = prevErr
 			}
+			recorder.done(response, err)
 			return err
 		}
 
 		switch response.StatusCode {
 		case http.StatusRequestURITooLong:
+			recorder.done(response, nil)
 			if wasPutTried {
 				return prevErr
 			}
 			method = http.MethodPut // try again using PUT
 		case http.StatusMethodNotAllowed:
+			recorder.done(response, nil)
 			if wasGetTried {
 				return prevErr
 			}
 			method = http.MethodGet // try again using GET
 		default:
-			e := ErrStatusNotOK{
+			e := &ErrStatusNotOK{
+				Header:     response.Header,
 				Status:     response.Status,
 				StatusCode: response.StatusCode,
 			}
 			// Read response body and return its text in the error.
-			buf, err := bytesFromReadCloser(response.Body)
-			if l := len(buf); err == nil && l > 0 {
+			if buf, err := c.readErrorBody(response.Body); err == nil && len(buf) > 0 {
 				e.Body = buf
 			}
+			if response.StatusCode == http.StatusTooManyRequests {
+				tmr := &ErrTooManyRequests{
+					ErrStatusNotOK: *e,
+					RetryAfter:     parseRetryAfter(response.Header.Get("Retry-After"), c.clock.Now()),
+				}
+				recorder.done(response, tmr)
+				return tmr
+			}
+			recorder.done(response, e)
 			return e
 		}
 
@@ -379,7 +977,7 @@ func (c *Client) query(ctx context.Context, expression string, callback func(io.
 		// Before loop to make another try, abort when context is already done.
 		select {
 		case <-ctx.Done():
-			return ctx.Err() // terminate when client has canceled the context
+			return contextError(ctx) // terminate when client has canceled the context
 		default:
 			// context still valid: fallthrough and send out a query attempt
 			prevErr = err
@@ -387,6 +985,14 @@ func (c *Client) query(ctx context.Context, expression string, callback func(io.
 	}
 }
 
+// expressionHash returns a short hash of the expression, suitable for labeling
+// profiles without including what might be a very long expression.
+func expressionHash(expression string) string {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(expression))
+	return strconv.FormatUint(h.Sum64(), 16)
+}
+
 func bytesFromReadCloser(iorc io.ReadCloser) ([]byte, error) {
 	buf, err1 := ioutil.ReadAll(iorc)
 	err2 := iorc.Close()
@@ -399,8 +1005,53 @@ func bytesFromReadCloser(iorc io.ReadCloser) ([]byte, error) {
 	return buf, nil
 }
 
+// contextError returns the error for a done context.  When the context was
+// canceled with a cause, such as by context.WithCancelCause, the cause is
+// returned wrapped together with ctx.Err(), so errors.Is continues to match
+// context.Canceled and context.DeadlineExceeded.
+func contextError(ctx context.Context) error {
+	err := ctx.Err()
+	if cause := context.Cause(ctx); cause != nil && cause != err {
+		return fmt.Errorf("%w: %w", err, cause)
+	}
+	return err
+}
+
+// errorBodyTruncated is appended to error bodies that were longer than the
+// configured limit.
+var errorBodyTruncated = []byte("...[truncated]")
+
+// readErrorBody reads and closes a response body for inclusion in an error.  At
+// most the configured number of bytes are returned, followed by a truncation
+// marker when the body was longer.  Truncated bodies are closed without reading
+// the remainder, so a huge error page from a proxy is not read at all.
+func (c *Client) readErrorBody(iorc io.ReadCloser) ([]byte, error) {
+	buf, err := readAllPooled(io.LimitReader(iorc, int64(c.maxErrorBodyBytes)+1))
+	if err != nil {
+		_ = iorc.Close()
+		return nil, err
+	}
+	if len(buf) > c.maxErrorBodyBytes {
+		buf = append(buf[:c.maxErrorBodyBytes], errorBodyTruncated...)
+		return buf, iorc.Close()
+	}
+	return buf, discard(iorc)
+}
+
+// maxDiscardBytes is the most bytes discard reads from the unread remainder of
+// a response body.  Reading a short remainder lets the connection be reused
+// via Keep-Alive, but reading a huge one, such as an enormous error page from
+// a proxy, costs more than opening a new connection.
+const maxDiscardBytes = 64 << 10
+
+// discard reads and closes the remainder of a response body, reading no more
+// than maxDiscardBytes, so the connection can be reused when the remainder is
+// short.
 func discard(iorc io.ReadCloser) error {
-	_, err1 := io.Copy(ioutil.Discard, iorc) // so we can reuse connections via Keep-Alive
+	_, err1 := io.CopyN(ioutil.Discard, iorc, maxDiscardBytes)
+	if err1 == io.EOF {
+		err1 = nil // the entire remainder was read
+	}
 	err2 := iorc.Close()
 	if err1 != nil {
 		return err1
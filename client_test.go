@@ -2,8 +2,12 @@ package orange
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"runtime/pprof"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -118,7 +122,7 @@ func TestClient(t *testing.T) {
 					if err != nil {
 						t.Fatal(err)
 					}
-					ensureStringSlicesMatch(t, values, []string{""})
+					ensureStringSlicesMatch(t, values, nil)
 				})
 			})
 		})
@@ -209,14 +213,14 @@ func TestClient(t *testing.T) {
 			}
 			withClient(t, h, func(client *Client) {
 				response, err := client.Query("foo")
-				switch err.(type) {
-				case ErrRangeException:
+				var e *ErrRangeException
+				if errors.As(err, &e) {
 					ensureError(t, err, "some error")
 					if got, avoid := err.Error(), "body"; strings.Contains(got, avoid) {
 						t.Errorf("GOT: %v; AVOID: %v", got, avoid)
 					}
-				default:
-					t.Errorf("GOT: %T; WANT: %T", err, ErrRangeException{})
+				} else {
+					t.Errorf("GOT: %T; WANT: %T", err, &ErrRangeException{})
 				}
 				ensureStringSlicesMatch(t, response, nil)
 			})
@@ -229,14 +233,14 @@ func TestClient(t *testing.T) {
 			}
 			withClient(t, h, func(client *Client) {
 				response, err := client.Query("foo")
-				switch err.(type) {
-				case ErrRangeException:
+				var e *ErrRangeException
+				if errors.As(err, &e) {
 					ensureError(t, err, "some error")
 					if got, avoid := err.Error(), "body"; strings.Contains(got, avoid) {
 						t.Errorf("GOT: %v; AVOID: %v", got, avoid)
 					}
-				default:
-					t.Errorf("GOT: %T; WANT: %T", err, ErrRangeException{})
+				} else {
+					t.Errorf("GOT: %T; WANT: %T", err, &ErrRangeException{})
 				}
 				ensureStringSlicesMatch(t, response, nil)
 			})
@@ -250,15 +254,15 @@ func TestClient(t *testing.T) {
 			}
 			withClient(t, h, func(client *Client) {
 				response, err := client.Query("foo")
-				switch v := err.(type) {
-				case ErrStatusNotOK:
+				var v *ErrStatusNotOK
+				if errors.As(err, &v) {
 					ensureError(t, err, http.StatusText(e))
 					if got, avoid := err.Error(), "body"; strings.Contains(got, avoid) {
 						t.Errorf("GOT: %v; AVOID: %v", got, avoid)
 					}
 					ensureStringSlicesMatch(t, lines(v.Body), []string{"body1", "body2"})
-				default:
-					t.Errorf("GOT: %T; WANT: %T", err, ErrRangeException{})
+				} else {
+					t.Errorf("GOT: %T; WANT: %T", err, &ErrRangeException{})
 				}
 				ensureStringSlicesMatch(t, response, nil)
 			})
@@ -272,15 +276,15 @@ func TestClient(t *testing.T) {
 			}
 			withClient(t, h, func(client *Client) {
 				response, err := client.Query("foo")
-				switch v := err.(type) {
-				case ErrStatusNotOK:
+				var v *ErrStatusNotOK
+				if errors.As(err, &v) {
 					ensureError(t, err, http.StatusText(e))
 					if got, avoid := err.Error(), "body"; strings.Contains(got, avoid) {
 						t.Errorf("GOT: %v; AVOID: %v", got, avoid)
 					}
 					ensureStringSlicesMatch(t, lines(v.Body), []string{"body1", "body2"})
-				default:
-					t.Errorf("GOT: %T; WANT: %T", err, ErrRangeException{})
+				} else {
+					t.Errorf("GOT: %T; WANT: %T", err, &ErrRangeException{})
 				}
 				ensureStringSlicesMatch(t, response, nil)
 			})
@@ -377,11 +381,11 @@ func TestClient(t *testing.T) {
 			withClient(t, h, func(client *Client) {
 				_, err := client.Query("%some.short.expression")
 				ensureError(t, err, http.StatusText(http.StatusServiceUnavailable))
-				switch e := err.(type) {
-				case ErrStatusNotOK:
+				var e *ErrStatusNotOK
+				if errors.As(err, &e) {
 					ensureStringSlicesMatch(t, lines(e.Body), []string{"body1", "body2"})
-				default:
-					t.Errorf("GOT: %v; WANT: %v", err, ErrStatusNotOK{})
+				} else {
+					t.Errorf("GOT: %v; WANT: %v", err, &ErrStatusNotOK{})
 				}
 			})
 
@@ -415,11 +419,11 @@ func TestClient(t *testing.T) {
 
 				_, err := client.Query(expression.String())
 				ensureError(t, err, http.StatusText(http.StatusServiceUnavailable))
-				switch e := err.(type) {
-				case ErrStatusNotOK:
+				var e *ErrStatusNotOK
+				if errors.As(err, &e) {
 					ensureStringSlicesMatch(t, lines(e.Body), []string{"body1", "body2"})
-				default:
-					t.Errorf("GOT: %v; WANT: %v", err, ErrStatusNotOK{})
+				} else {
+					t.Errorf("GOT: %v; WANT: %v", err, &ErrStatusNotOK{})
 				}
 			})
 
@@ -464,3 +468,118 @@ func BenchmarkLineSplitting(b *testing.B) {
 		})
 	})
 }
+
+func TestClientPprofLabels(t *testing.T) {
+	withTestServer(t, func(http.ResponseWriter, *http.Request) {}, func(server *httptest.Server) {
+		address := strings.TrimLeft(server.URL, "http://")
+
+		var gotExpression, gotServer string
+		var ok bool
+
+		client, err := NewClient(&Config{
+			HTTPClient: DoerFunc(func(request *http.Request) (*http.Response, error) {
+				gotExpression, ok = pprof.Label(request.Context(), pprofLabelExpression)
+				if !ok {
+					t.Errorf("GOT: %v; WANT: %v", ok, true)
+				}
+				gotServer, ok = pprof.Label(request.Context(), pprofLabelServer)
+				if !ok {
+					t.Errorf("GOT: %v; WANT: %v", ok, true)
+				}
+				return server.Client().Do(request)
+			}),
+			Servers: []string{address},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err = client.Query("foo"); err != nil {
+			t.Fatal(err)
+		}
+
+		if got, want := gotExpression, expressionHash("foo"); got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := gotServer, address; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+}
+
+func BenchmarkQueryHosts(b *testing.B) {
+	var body []byte
+	for i := 0; i < 10000; i++ {
+		body = append(body, "web"+strconv.Itoa(100000 + i)[1:]+".example.com\n"...)
+	}
+	h := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		w.Write(body)
+	}
+
+	for _, zeroCopy := range []bool{false, true} {
+		b.Run(fmt.Sprintf("zero copy %t", zeroCopy), func(b *testing.B) {
+			withClient(b, h, func(client *Client) {
+				client.zeroCopy = zeroCopy
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					values, err := client.QueryCtx(context.Background(), "foo")
+					if err != nil {
+						b.Fatal(err)
+					}
+					if got, want := len(values), 10000; got != want {
+						b.Fatalf("GOT: %v; WANT: %v", got, want)
+					}
+				}
+			})
+		})
+	}
+}
+
+func BenchmarkQueryParallel(b *testing.B) {
+	client, err := NewClient(&Config{
+		HTTPClient: DoerFunc(func(request *http.Request) (*http.Response, error) {
+			return mockResponse(request, http.StatusOK, make(http.Header), "host1\nhost2\n"), nil
+		}),
+		Servers: []string{"mock"},
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := client.QueryCtx(context.Background(), "foo"); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+}
+
+func BenchmarkQueryLongExpressionRetries(b *testing.B) {
+	var calls int
+	client, err := NewClient(&Config{
+		HTTPClient: DoerFunc(func(request *http.Request) (*http.Response, error) {
+			if calls++; calls%3 != 0 {
+				return mockResponse(request, http.StatusServiceUnavailable, make(http.Header), ""), nil
+			}
+			return mockResponse(request, http.StatusOK, make(http.Header), "host1\n"), nil
+		}),
+		RetryCallback: func(error) bool { return true },
+		RetryCount:    2,
+		Servers:       []string{"mock"},
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+	expression := strings.Repeat("%cluster,", 1<<14)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := client.QueryCtx(context.Background(), expression); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
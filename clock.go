@@ -0,0 +1,179 @@
+package orange
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Clock tells time and waits for durations to elapse, so code that waits can be
+// tested using virtual rather than real time.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+
+	// After waits for the duration to elapse and then sends the current time
+	// on the returned channel.
+	After(d time.Duration) <-chan time.Time
+}
+
+// realClock is a Clock using the system time.
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// VirtualClock is a Clock whose waits complete instantly, advancing its time by
+// the duration waited, so tests with delays run without sleeping while still
+// observing how much time would have elapsed.
+//
+//     clock := orange.NewVirtualClock(time.Time{})
+//     client, _ := orange.NewMockClient(&orange.MockConfig{Clock: clock, TimeDelay: time.Minute})
+//     client.Query("%cluster1") // returns immediately
+//     fmt.Println(clock.Elapsed()) // 1m0s
+type VirtualClock struct {
+	lock  sync.Mutex
+	start time.Time
+	now   time.Time
+}
+
+// NewVirtualClock returns a VirtualClock whose time starts at start.
+func NewVirtualClock(start time.Time) *VirtualClock {
+	return &VirtualClock{start: start, now: start}
+}
+
+// Now returns the virtual time.
+func (c *VirtualClock) Now() time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.now
+}
+
+// After advances the virtual time by d and returns a channel on which the new
+// time is already available.
+func (c *VirtualClock) After(d time.Duration) <-chan time.Time {
+	c.lock.Lock()
+	if d > 0 {
+		c.now = c.now.Add(d)
+	}
+	now := c.now
+	c.lock.Unlock()
+
+	ch := make(chan time.Time, 1)
+	ch <- now
+	return ch
+}
+
+// Elapsed returns the total virtual time waited.
+func (c *VirtualClock) Elapsed() time.Duration {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.now.Sub(c.start)
+}
+
+// ManualClock is a Clock whose time only moves when advanced, so tests can
+// assert retry pauses, cache expiration, and other timed behavior precisely
+// without real sleeps.  Waits started with After complete once the clock has
+// been advanced past their deadline.
+//
+//     clock := orange.NewManualClock(time.Now())
+//     client, _ := orange.NewClient(&orange.Config{
+//         Clock:      clock,
+//         RetryCount: 1,
+//         RetryPause: time.Minute,
+//         Servers:    servers,
+//     })
+//     go client.Query("%cluster1")
+//     clock.BlockUntil(ctx, 1) // wait for the retry pause to begin
+//     clock.Advance(time.Minute)
+type ManualClock struct {
+	lock    sync.Mutex
+	now     time.Time
+	waiters []manualWaiter
+	changed chan struct{} // closed and replaced when waiters change
+}
+
+type manualWaiter struct {
+	deadline time.Time
+	ch       chan time.Time
+}
+
+// NewManualClock returns a ManualClock whose time starts at start.
+func NewManualClock(start time.Time) *ManualClock {
+	return &ManualClock{now: start, changed: make(chan struct{})}
+}
+
+// Now returns the time of the clock.
+func (c *ManualClock) Now() time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.now
+}
+
+// After returns a channel on which the time is sent once the clock has been
+// advanced by at least d.
+func (c *ManualClock) After(d time.Duration) <-chan time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- c.now
+		return ch
+	}
+	c.waiters = append(c.waiters, manualWaiter{deadline: c.now.Add(d), ch: ch})
+	c.notify()
+	return ch
+}
+
+// Advance moves the time of the clock forward by d, completing the waits whose
+// deadlines have been reached.
+func (c *ManualClock) Advance(d time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.now = c.now.Add(d)
+	pending := c.waiters[:0]
+	for _, w := range c.waiters {
+		if c.now.Before(w.deadline) {
+			pending = append(pending, w)
+			continue
+		}
+		w.ch <- c.now
+	}
+	c.waiters = pending
+	c.notify()
+}
+
+// Waiters returns the number of waits that have not completed.
+func (c *ManualClock) Waiters() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return len(c.waiters)
+}
+
+// BlockUntil blocks until at least n waits have not completed, or ctx is done,
+// in which case it returns the context error.  Tests use it to know that code
+// running on another go-routine has begun waiting before advancing the clock.
+func (c *ManualClock) BlockUntil(ctx context.Context, n int) error {
+	for {
+		c.lock.Lock()
+		count, changed := len(c.waiters), c.changed
+		c.lock.Unlock()
+
+		if count >= n {
+			return nil
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// notify wakes the callers of BlockUntil.  It must be called with the lock held.
+func (c *ManualClock) notify() {
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
@@ -0,0 +1,170 @@
+package orange
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestVirtualClock(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewVirtualClock(start)
+
+	select {
+	case now := <-clock.After(time.Hour):
+		if got, want := now, start.Add(time.Hour); !got.Equal(want) {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	default:
+		t.Fatal("GOT: blocked; WANT: ready")
+	}
+
+	<-clock.After(time.Minute)
+
+	if got, want := clock.Now(), start.Add(time.Hour+time.Minute); !got.Equal(want) {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+	if got, want := clock.Elapsed(), time.Hour+time.Minute; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+}
+
+func TestManualClock(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewManualClock(start)
+
+	ch := clock.After(time.Minute)
+	if got, want := clock.Waiters(), 1; got != want {
+		t.Fatalf("GOT: %v; WANT: %v", got, want)
+	}
+
+	clock.Advance(59 * time.Second)
+	select {
+	case <-ch:
+		t.Fatal("GOT: ready; WANT: blocked")
+	default:
+	}
+
+	clock.Advance(time.Second)
+	select {
+	case now := <-ch:
+		if got, want := now, start.Add(time.Minute); !got.Equal(want) {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	default:
+		t.Fatal("GOT: blocked; WANT: ready")
+	}
+	if got, want := clock.Waiters(), 0; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+
+	select {
+	case <-clock.After(0):
+	default:
+		t.Fatal("GOT: blocked; WANT: ready")
+	}
+}
+
+func TestManualClockRetryPause(t *testing.T) {
+	clock := NewManualClock(time.Now())
+
+	var calls int
+	mock := &MockConfig{
+		Callback: func(expression string) ([]string, error) {
+			calls++
+			if calls == 1 {
+				return nil, ErrMockFault
+			}
+			return []string{"host1"}, nil
+		},
+	}
+	client, err := NewClient(&Config{
+		Clock:      clock,
+		HTTPClient: mock,
+		RetryCount: 1,
+		RetryPause: time.Hour,
+		Servers:    []string{"mock"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type result struct {
+		values []string
+		err    error
+	}
+	ch := make(chan result, 1)
+	go func() {
+		values, err := client.Query("foo")
+		ch <- result{values, err}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := clock.BlockUntil(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	mock.AssertCount(t, "foo", 1)
+
+	clock.Advance(time.Hour)
+
+	r := <-ch
+	if r.err != nil {
+		t.Fatal(r.err)
+	}
+	ensureStringSlicesMatch(t, r.values, []string{"host1"})
+	mock.AssertCount(t, "foo", 2)
+}
+
+func TestManualClockCacheExpiration(t *testing.T) {
+	clock := NewManualClock(time.Now())
+	mock := &MockConfig{Results: []string{"host1"}}
+	client, err := NewClient(&Config{
+		CacheTTL:   time.Minute,
+		Clock:      clock,
+		HTTPClient: mock,
+		Servers:    []string{"mock"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err = client.Query("foo"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mock.AssertCount(t, "foo", 1)
+
+	clock.Advance(time.Minute)
+	if _, err = client.Query("foo"); err != nil {
+		t.Fatal(err)
+	}
+	mock.AssertCount(t, "foo", 2)
+}
+
+func TestManualClockCacheRefresh(t *testing.T) {
+	clock := NewManualClock(time.Now())
+	mock := &MockConfig{
+		Callback: func(expression string) ([]string, error) {
+			clock.Advance(time.Second)
+			return []string{"host1"}, nil
+		},
+	}
+	client, err := NewClient(&Config{
+		CacheTTL:   time.Minute,
+		Clock:      clock,
+		HTTPClient: mock,
+		Servers:    []string{"mock"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = client.Query("foo"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := client.Stats().AverageCacheRefresh, time.Second; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+}
@@ -0,0 +1,93 @@
+// Command orange-proxy is a caching range proxy, which fronts upstream range
+// servers and serves the same protocol locally, giving every host a low
+// latency local range endpoint.
+//
+//     orange-proxy -listen localhost:8081 -servers range1.example.com,range2.example.com -ttl 5m
+//
+// Successful query results are cached for the TTL.  The cache holds at most
+// -cache-entries results, totaling at most -cache-bytes, evicting the least
+// recently used results first, so the memory of a long running proxy stays
+// bounded however many distinct expressions it serves.
+//
+// RangeExceptions from the upstream servers are passed through, and queries
+// that no upstream server answers are answered with 502 Bad Gateway.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/karrick/orange"
+	"github.com/karrick/orange/server"
+)
+
+func main() {
+	optCacheBytes := flag.Int("cache-bytes", 64<<20, "maximum total size of cached query results, in bytes, or 0 for no limit")
+	optCacheEntries := flag.Int("cache-entries", 10000, "maximum number of cached query results, or 0 for no limit")
+	optListen := flag.String("listen", "localhost:8081", "`address` on which to serve range queries")
+	optRetries := flag.Int("retries", 2, "number of times to retry a failed upstream query")
+	optServers := flag.String("servers", os.Getenv("RANGE_SERVERS"), "comma separated list of upstream range `servers`")
+	optTimeout := flag.Duration("timeout", 30*time.Second, "timeout duration for upstream queries")
+	optTTL := flag.Duration("ttl", time.Minute, "duration for which query results are cached")
+	flag.Parse()
+
+	if *optServers == "" {
+		fmt.Fprintf(os.Stderr, "USAGE: %s -servers SERVERS [-listen ADDRESS] [-ttl DURATION]\n", filepath.Base(os.Args[0]))
+		os.Exit(2)
+	}
+
+	client, err := orange.NewClient(&orange.Config{
+		CacheMaxBytes:   *optCacheBytes,
+		CacheMaxEntries: *optCacheEntries,
+		CacheTTL:        *optTTL,
+		Coalesce:        true,
+		RetryCount:      *optRetries,
+		Servers:         strings.Split(*optServers, ","),
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %s\n", err)
+		os.Exit(1)
+	}
+
+	httpServer := &http.Server{
+		Addr:              *optListen,
+		Handler:           server.New(upstream(client, *optTimeout)),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// ListenAndServe returns as soon as Shutdown is called, so main waits for
+	// done, which is closed once in-flight requests, and the upstream queries
+	// they issued, have finished.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := httpServer.Shutdown(shutdown); err != nil {
+			log.Printf("abandoning proxied queries: %s", err)
+		}
+		if err := client.Wait(shutdown); err != nil {
+			log.Printf("abandoning upstream queries: %s", err)
+		}
+	}()
+
+	log.Printf("serving range queries on %s", *optListen)
+	if err = httpServer.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		log.Fatal(err)
+	}
+	<-done
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/karrick/orange"
+	"github.com/karrick/orange/server"
+)
+
+// upstream returns a Backend that answers queries using client, each limited
+// to timeout when it is greater than 0.  RangeExceptions from the upstream
+// servers are passed through with their original message, and all other
+// failures are answered with 502 Bad Gateway.
+func upstream(client *orange.Client, timeout time.Duration) server.Backend {
+	return server.BackendFunc(func(ctx context.Context, expression string) ([]string, error) {
+		if timeout > 0 {
+			var cancel func()
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+		values, err := client.QueryCtx(ctx, expression)
+		if err != nil {
+			var rangeException *orange.ErrRangeException
+			if errors.As(err, &rangeException) {
+				return nil, errors.New(rangeException.Message)
+			}
+			return nil, &server.StatusError{StatusCode: http.StatusBadGateway, Err: err}
+		}
+		return values, nil
+	})
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/karrick/orange"
+	"github.com/karrick/orange/rangetest"
+	"github.com/karrick/orange/server"
+)
+
+func newTestClient(tb testing.TB, handler http.Handler, ttl time.Duration) *orange.Client {
+	tb.Helper()
+	s := httptest.NewServer(handler)
+	tb.Cleanup(s.Close)
+
+	client, err := orange.NewClient(&orange.Config{
+		CacheTTL:   ttl,
+		HTTPClient: s.Client(),
+		Servers:    []string{strings.TrimPrefix(s.URL, "http://")},
+	})
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return client
+}
+
+func TestProxy(t *testing.T) {
+	clusters := rangetest.Clusters{"web": {"CLUSTER": {"web1", "web2"}}}
+	upstreamClient := newTestClient(t, rangetest.NewHandler(clusters), time.Hour)
+	client := newTestClient(t, server.New(upstream(upstreamClient, time.Second)), 0)
+
+	values, err := client.Query("%web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.Join(values, ","), "web1,web2"; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+
+	// Cached results are served after the upstream data changes.
+	clusters["web"]["CLUSTER"] = []string{"web3"}
+	values, err = client.Query("%web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.Join(values, ","), "web1,web2"; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+
+	// RangeExceptions are passed through.
+	_, err = client.Query("%missing")
+	var rangeException *orange.ErrRangeException
+	if !errors.As(err, &rangeException) {
+		t.Fatalf("GOT: %v; WANT: %T", err, rangeException)
+	}
+	if got, want := rangeException.Message, "no such cluster"; !strings.Contains(got, want) {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+}
+
+func TestProxyUpstreamFailure(t *testing.T) {
+	upstreamClient := newTestClient(t, &rangetest.Fake{StatusCode: http.StatusServiceUnavailable}, 0)
+	client := newTestClient(t, server.New(upstream(upstreamClient, time.Second)), 0)
+
+	_, err := client.Query("%web")
+	if got, want := err, (&orange.ErrStatusNotOK{StatusCode: http.StatusBadGateway}); !errors.Is(got, want) {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+)
+
+// formats maps the names of the output formats to the functions that write
+// values in them.
+var formats = map[string]func(io.Writer, []string) error{
+	"comma": func(w io.Writer, values []string) error {
+		return writeString(w, strings.Join(values, ",")+"\n")
+	},
+	"json": func(w io.Writer, values []string) error {
+		if values == nil {
+			values = []string{} // an empty array rather than null
+		}
+		return json.NewEncoder(w).Encode(values)
+	},
+	"newline": func(w io.Writer, values []string) error {
+		return writeTerminated(w, values, "\n")
+	},
+	"null": func(w io.Writer, values []string) error {
+		return writeTerminated(w, values, "\x00")
+	},
+}
+
+// writeValues writes values to w in the named output format.
+func writeValues(w io.Writer, format string, values []string) error {
+	return formats[format](w, values)
+}
+
+// writeTerminated writes each of the values to w followed by terminator.
+func writeTerminated(w io.Writer, values []string, terminator string) error {
+	var b strings.Builder
+	for _, value := range values {
+		b.WriteString(value)
+		b.WriteString(terminator)
+	}
+	return writeString(w, b.String())
+}
+
+func writeString(w io.Writer, s string) error {
+	_, err := io.WriteString(w, s)
+	return err
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWriteValues(t *testing.T) {
+	cases := []struct {
+		format string
+		values []string
+		want   string
+	}{
+		{"newline", []string{"web1", "web2"}, "web1\nweb2\n"},
+		{"newline", nil, ""},
+		{"comma", []string{"web1", "web2"}, "web1,web2\n"},
+		{"json", []string{"web1", "web2"}, "[\"web1\",\"web2\"]\n"},
+		{"json", nil, "[]\n"},
+		{"null", []string{"web1", "web2"}, "web1\x00web2\x00"},
+	}
+
+	for _, c := range cases {
+		var buf bytes.Buffer
+		if err := writeValues(&buf, c.format, c.values); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := buf.String(), c.want; got != want {
+			t.Errorf("%s: GOT: %q; WANT: %q", c.format, got, want)
+		}
+	}
+}
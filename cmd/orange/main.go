@@ -0,0 +1,100 @@
+// Command orange queries range servers from the command line, printing the
+// results of its expressions in a choice of output formats.
+//
+//     orange -servers range.example.com %web
+//     orange -format null %web | xargs -0 -n1 ping -c1
+//     orange -intersect %production %web
+//     orange -diff %web-canary %web
+//     orange materialize -dir /etc/range manifest.txt
+//
+// Multiple expressions are joined into a union.  The -intersect and -diff
+// flags query a second expression, and print only the results also in it, or
+// only those not in it, respectively.  The materialize subcommand writes the
+// results of the expressions in a manifest to files, as described by
+// orange.ReadManifest and Client.Materialize, and prints the names of the
+// files that changed.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/karrick/orange"
+)
+
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "materialize" {
+		os.Exit(materialize(os.Args[2:]))
+	}
+
+	optDiff := flag.String("diff", "", "print only results not also in the results of `expression`")
+	optFormat := flag.String("format", "newline", "output `format`: newline, comma, json, or null")
+	optIntersect := flag.String("intersect", "", "print only results also in the results of `expression`")
+	optServers := flag.String("servers", serversDefault(), "comma separated list of range `servers`")
+	optTimeout := flag.Duration("timeout", 0, "timeout duration for the queries")
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		fmt.Fprintf(os.Stderr, "USAGE: %s [-format FORMAT] [-intersect EXPRESSION] [-diff EXPRESSION] q1 q2\n", filepath.Base(os.Args[0]))
+		os.Exit(2)
+	}
+	if *optIntersect != "" && *optDiff != "" {
+		fmt.Fprintf(os.Stderr, "ERROR: cannot use both -intersect and -diff\n")
+		os.Exit(2)
+	}
+	if _, ok := formats[*optFormat]; !ok {
+		fmt.Fprintf(os.Stderr, "ERROR: unknown output format: %q\n", *optFormat)
+		os.Exit(2)
+	}
+
+	client, err := orange.NewClient(&orange.Config{
+		Servers: strings.Split(*optServers, ","),
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %s\n", err)
+		os.Exit(1)
+	}
+
+	ctx := context.Background()
+	if *optTimeout > 0 {
+		var done func()
+		ctx, done = context.WithTimeout(ctx, *optTimeout)
+		defer done()
+	}
+
+	expressions := []string{strings.Join(flag.Args(), ",")}
+	if other := *optIntersect + *optDiff; other != "" {
+		expressions = append(expressions, other)
+	}
+	results, err := client.QueriesCtx(ctx, expressions)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %s\n", err)
+		os.Exit(1)
+	}
+
+	values := orange.Results(results[0])
+	switch {
+	case *optIntersect != "":
+		values = values.Intersect(results[1])
+	case *optDiff != "":
+		values = values.Difference(results[1])
+	}
+
+	if err = writeValues(os.Stdout, *optFormat, values); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+// serversDefault returns the servers named by the RANGE_SERVERS environment
+// variable, or the local range server when it is not set.
+func serversDefault() string {
+	if servers := os.Getenv("RANGE_SERVERS"); servers != "" {
+		return servers
+	}
+	return "localhost:8081"
+}
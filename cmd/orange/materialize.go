@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/karrick/orange"
+)
+
+// materialize runs the materialize subcommand, which writes the results of
+// each expression in a manifest to its file, and prints the names of the files
+// that changed.  It returns the exit status.
+//
+//     orange materialize -dir /etc/range manifest.txt && systemctl reload haproxy
+func materialize(args []string) int {
+	flags := flag.NewFlagSet("materialize", flag.ContinueOnError)
+	optDir := flags.String("dir", ".", "`directory` in which to write the files")
+	optServers := flags.String("servers", serversDefault(), "comma separated list of range `servers`")
+	optTimeout := flags.Duration("timeout", 0, "timeout duration for the queries")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "USAGE: orange materialize [-dir DIRECTORY] MANIFEST\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	fh, err := os.Open(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %s\n", err)
+		return 1
+	}
+	manifest, err := orange.ReadManifest(fh)
+	_ = fh.Close()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %s\n", err)
+		return 1
+	}
+
+	client, err := orange.NewClient(&orange.Config{
+		Servers: strings.Split(*optServers, ","),
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %s\n", err)
+		return 1
+	}
+
+	ctx := context.Background()
+	if *optTimeout > 0 {
+		var done func()
+		ctx, done = context.WithTimeout(ctx, *optTimeout)
+		defer done()
+	}
+
+	changed, err := client.Materialize(ctx, *optDir, manifest)
+	for _, name := range changed {
+		fmt.Println(name)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %s\n", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,107 @@
+package orange
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Compress folds a list of hosts back into compact range notation, the inverse
+// of expansion, for tooling that writes expressions or displays results.
+// Hosts that differ only by the first number in their names are folded into a
+// numeric range when their numbers are consecutive.  Groups are separated by
+// commas, and appear in the order their first host appears in hosts.
+// Duplicate hosts are ignored.
+//
+//     fmt.Println(orange.Compress([]string{
+//         "web1.example.com", "web2.example.com", "web3.example.com",
+//         "web7.example.com", "db01.example.com", "db02.example.com",
+//     }))
+//     // Output: web1..3.example.com,web7.example.com,db01..02.example.com
+//
+// Zero padded numbers are only folded together with numbers of the same width,
+// so the output expands back to exactly the same hosts.
+func Compress(hosts []string) string {
+	type group struct {
+		prefix, suffix string
+		width          int // non-zero for zero padded numbers
+		numbers        []int
+	}
+
+	var order []interface{} // either a string host without a number, or a *group
+	groups := make(map[string]*group)
+	seen := make(map[string]struct{}, len(hosts))
+
+	for _, host := range hosts {
+		if _, ok := seen[host]; ok {
+			continue
+		}
+		seen[host] = struct{}{}
+
+		prefix, digits, suffix := splitFirstNumber(host)
+		n, err := strconv.Atoi(digits)
+		if digits == "" || err != nil {
+			order = append(order, host)
+			continue
+		}
+
+		var width int
+		if len(digits) > 1 && digits[0] == '0' {
+			width = len(digits)
+		}
+
+		key := prefix + "\x00" + strconv.Itoa(width) + "\x00" + suffix
+		g, ok := groups[key]
+		if !ok {
+			g = &group{prefix: prefix, suffix: suffix, width: width}
+			groups[key] = g
+			order = append(order, g)
+		}
+		g.numbers = append(g.numbers, n)
+	}
+
+	var parts []string
+	for _, item := range order {
+		g, ok := item.(*group)
+		if !ok {
+			parts = append(parts, item.(string))
+			continue
+		}
+		sort.Ints(g.numbers)
+		format := func(n int) string {
+			s := strconv.Itoa(n)
+			if pad := g.width - len(s); pad > 0 {
+				s = strings.Repeat("0", pad) + s
+			}
+			return s
+		}
+		for i := 0; i < len(g.numbers); {
+			j := i
+			for j+1 < len(g.numbers) && g.numbers[j+1] == g.numbers[j]+1 {
+				j++
+			}
+			if i == j {
+				parts = append(parts, g.prefix+format(g.numbers[i])+g.suffix)
+			} else {
+				parts = append(parts, g.prefix+format(g.numbers[i])+".."+format(g.numbers[j])+g.suffix)
+			}
+			i = j + 1
+		}
+	}
+
+	return strings.Join(parts, ",")
+}
+
+// splitFirstNumber splits s around its first run of decimal digits.  When s has
+// no digits, prefix is s and both digits and suffix are empty.
+func splitFirstNumber(s string) (prefix, digits, suffix string) {
+	start := strings.IndexAny(s, "0123456789")
+	if start == -1 {
+		return s, "", ""
+	}
+	end := start + 1
+	for end < len(s) && s[end] >= '0' && s[end] <= '9' {
+		end++
+	}
+	return s[:start], s[start:end], s[end:]
+}
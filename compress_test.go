@@ -0,0 +1,48 @@
+package orange
+
+import "testing"
+
+func TestCompress(t *testing.T) {
+	cases := []struct {
+		name  string
+		hosts []string
+		want  string
+	}{
+		{"empty", nil, ""},
+		{"single", []string{"web1.example.com"}, "web1.example.com"},
+		{"no numbers", []string{"alpha", "beta"}, "alpha,beta"},
+		{
+			"consecutive",
+			[]string{"web1.example.com", "web2.example.com", "web3.example.com"},
+			"web1..3.example.com",
+		},
+		{
+			"unordered with gaps and duplicates",
+			[]string{"web10.example.com", "web9.example.com", "web2.example.com", "web1.example.com", "web9.example.com"},
+			"web1..2.example.com,web9..10.example.com",
+		},
+		{
+			"multiple groups in order of appearance",
+			[]string{"web1.example.com", "db01.example.com", "web2.example.com", "db02.example.com", "lb"},
+			"web1..2.example.com,db01..02.example.com,lb",
+		},
+		{
+			"zero padded widths kept apart",
+			[]string{"db09", "db10", "db1"},
+			"db09,db1,db10",
+		},
+		{
+			"first number only",
+			[]string{"web1.dc1", "web2.dc1", "web1.dc2"},
+			"web1..2.dc1,web1.dc2",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got, want := Compress(c.hosts), c.want; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+		})
+	}
+}
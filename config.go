@@ -1,6 +1,7 @@
 package orange
 
 import (
+	"bufio"
 	"net/http"
 	"time"
 )
@@ -17,13 +18,130 @@ const DefaultDialTimeout = 5 * time.Second
 // keep-alive duration for an active connection.
 const DefaultDialKeepAlive = 30 * time.Second
 
+// DefaultMaxErrorBodyBytes is used when MaxErrorBodyBytes is zero to limit how
+// many bytes of a response body are captured in a returned error.
+const DefaultMaxErrorBodyBytes = 4096
+
+// DefaultMaxLineLength is used when MaxLineLength is zero to limit the length of
+// a single line of a query response.
+const DefaultMaxLineLength = bufio.MaxScanTokenSize
+
+// DefaultScanBufferSize is used when ScanBufferSize is zero for the initial
+// size of the buffers into which response lines are read.
+const DefaultScanBufferSize = 4096
+
 // DefaultMaxIdleConnsPerHost is used when no HTTPClient is provided to control
 // how many idle connections to keep alive per host.
 const DefaultMaxIdleConnsPerHost = 1
 
+// DefaultPath is used when Path is empty as the path of the range server
+// endpoint that lists the values of an expression.
+const DefaultPath = "/range/list"
+
+// DefaultReverseOperator is used when ReverseOperator is empty as the range
+// operator that returns the clusters containing a host.
+const DefaultReverseOperator = "*"
+
+// DefaultResponseHeaders is used when no ResponseHeaders are provided to list
+// the response headers captured for correlating client side logs with server
+// side identifiers.
+var DefaultResponseHeaders = []string{"X-Request-Id", "Server"}
+
 // Config provides a way to list the range server addresses, and a way to
 // override defaults when creating new http.Client instances.
 type Config struct {
+	// AddPath is the path of the range server endpoint to which AddToCluster
+	// sends writes.  When empty, the sibling of Path named add is used, such
+	// as /range/add, or /v1/range/add when Path is /v1/range/list.
+	AddPath string
+
+	// AllowWrites enables the methods that modify range data on range servers
+	// that accept writes, such as AddToCluster and RemoveFromCluster.  When
+	// false, they return ErrWritesDisabled without sending a request.
+	AllowWrites bool
+
+	// AuthProvider, when not nil, is invoked with each request immediately
+	// before it is sent, including each retry, to add credentials, such as
+	// Basic authentication, a bearer token, or signed headers.  Because it is
+	// invoked for every request, it can supply credentials that rotate while
+	// the process runs.  When it returns an error, the request is not sent,
+	// and the attempt fails with that error.
+	//
+	//     AuthProvider: func(request *http.Request) error {
+	//         token, err := tokens.Current()
+	//         if err != nil {
+	//             return err
+	//         }
+	//         request.Header.Set("Authorization", "Bearer "+token)
+	//         return nil
+	//     },
+	AuthProvider func(*http.Request) error
+
+	// BatchRequests causes Queries and QueriesCtx to send their expressions
+	// to a range server in a single batch request, for range servers that
+	// support them, rather than sending a request for each expression.  When
+	// a range server does not support batch requests, the client falls back
+	// to sending a request for each expression.  See BatchResult.
+	BatchRequests bool
+
+	// BatchWorkers, when greater than 0, is the maximum number of go-routines
+	// the client uses to run the queries of Queries and QueriesCtx, so
+	// services issuing many batches keep a stable number of go-routines.  The
+	// go-routines are shared by all batches, started as needed, and exit after
+	// sitting idle for a minute.  Leave 0 to run each query of a batch on its
+	// own go-routine.
+	BatchWorkers int
+
+	// CacheMaxBytes, when greater than 0, bounds the total size of the results
+	// cached when CacheTTL is set, counted as the length of each expression and
+	// of each of its results, so long running services querying many unique
+	// expressions do not grow without bound.  The least recently used results
+	// are evicted first, and results larger than CacheMaxBytes are not cached.
+	CacheMaxBytes int
+
+	// CacheMaxEntries, when greater than 0, bounds the number of expressions
+	// whose results are cached when CacheTTL is set.  The least recently used
+	// results are evicted first.
+	CacheMaxEntries int
+
+	// CacheTTL is the amount of time the results of a successful query made by
+	// Query or QueryCtx are cached and returned to subsequent callers.  Leave 0
+	// to disable caching.
+	CacheTTL time.Duration
+
+	// Clock, when not nil, is used to pause between retries, to expire cached
+	// results, to compute query rates, and to interpret Retry-After dates, so
+	// tests can control time with a ManualClock rather than sleeping.
+	Clock Clock
+
+	// Coalesce causes concurrent Query and QueryCtx calls for the same
+	// expression to share the results of a single query rather than each
+	// sending their own query to the range servers.
+	Coalesce bool
+
+	// Debugf is an optional function that receives debug log messages, such as
+	// the timing breakdown of each HTTP request sent to a range server.  The
+	// log.Printf function may be used.
+	Debugf func(format string, args ...interface{})
+
+	// Delimiter is the byte separating results in query responses, for range
+	// servers and endpoints that return comma separated rather than newline
+	// separated results.  Newlines always separate results, and when Delimiter
+	// is not a newline, spaces surrounding each result are trimmed.  When zero,
+	// the delimiter is detected from the Content-Type of each response: a comma
+	// for text/csv, and a newline otherwise.
+	Delimiter byte
+
+	// Headers are set on every request sent to range servers, such as headers
+	// that a gateway in front of them requires.  User-Agent is set by
+	// UserAgent instead, when it is not empty.
+	//
+	//     Headers: http.Header{
+	//         "X-Team": []string{"billing"},
+	//         "X-Env":  []string{"prod"},
+	//     },
+	Headers http.Header
+
 	// HTTPClient allows the caller to specify a specially configured
 	// http.Client instance to use for all queries.  When none is provided, a
 	// client will be created using the default timeouts.  If you intend to only
@@ -32,26 +150,169 @@ type Config struct {
 	// cause unexpected results.
 	HTTPClient Doer
 
+	// IncludeExpressionInErrors causes error messages returned by queries to
+	// include a quoted copy of the query expression, truncated to 80
+	// characters, so services issuing many different queries can tell from an
+	// error which one failed.  Leave false when expressions may contain
+	// sensitive information.  Regardless of this setting, the expression is
+	// available from the Expression field of the returned *QueryError.
+	IncludeExpressionInErrors bool
+
+	// Interceptors wrap the queries made by Query and QueryCtx, and the
+	// methods built on them, in order, so the first is outermost.  See
+	// Interceptor.
+	Interceptors []Interceptor
+
+	// MaxConcurrentQueries, when greater than 0, is the maximum number of
+	// queries the client sends to range servers at once.  Additional queries
+	// wait, and are sent in order of their priority, given by
+	// WithQueryPriority, then in the order they were made.
+	MaxConcurrentQueries int
+
+	// MaxErrorBodyBytes is the maximum number of response body bytes captured
+	// in the Body field of ErrStatusNotOK and ErrRangeException errors.  Longer
+	// bodies are truncated and end with a truncation marker.  When zero,
+	// DefaultMaxErrorBodyBytes is used.
+	MaxErrorBodyBytes int
+
+	// MaxLineLength is the maximum length, in bytes, of a single line of a
+	// query response processed by Query, QueryCtx, QueryForEach, and Queries.
+	// Responses with longer lines, such as giant TXT style values, cause those
+	// methods to return *ErrLineTooLong.  When zero, DefaultMaxLineLength is
+	// used.
+	MaxLineLength int
+
+	// OnAttempt is an optional function invoked after each HTTP request sent
+	// to a range server, describing the request, its result, and a timing
+	// breakdown of DNS, connect, TLS, and time-to-first-byte.
+	OnAttempt func(AttemptEvent)
+
+	// OnErrorReport is an optional function invoked once for each query that
+	// fails after all retries are exhausted, for integration with error
+	// reporting services.  Unlike RetryCallback, it does not influence whether
+	// a query is retried.  When the query failed because its context was done,
+	// the Server and Method fields of the QueryError are empty.
+	OnErrorReport func(QueryError)
+
+	// Path is the path of the range server endpoint that lists the values of
+	// an expression, for range servers that serve queries from a path such as
+	// "/v1/range/list".  When empty, DefaultPath is used.
+	Path string
+
+	// PrewarmTimeout, when greater than 0, causes NewClient to open a
+	// connection to every server before returning, waiting no longer than
+	// PrewarmTimeout, so latency sensitive first queries do not pay for
+	// dialing.  Servers that cannot be reached in time are ignored.  See
+	// Client.Prewarm.
+	PrewarmTimeout time.Duration
+
+	// RateLimit limits the rate at which queries are sent to range servers,
+	// either waiting until a query may be sent or failing it fast with
+	// ErrRateLimited.  When its QPS is 0, queries are not limited.
+	RateLimit RateLimit
+
+	// RecentErrors is the number of the most recent query errors retained for
+	// inspection using the Client's RecentErrors method.  When zero,
+	// DefaultRecentErrors is used.
+	RecentErrors int
+
+	// ResponseHeaders lists the names of response headers, such as request
+	// identifiers or server version, captured in Response metadata and
+	// AttemptEvent values.  When nil, DefaultResponseHeaders is used.  Use an
+	// empty non-nil slice to capture no headers.
+	ResponseHeaders []string
+
 	// RetryCallback is predicate function that tests whether query should be
 	// retried for a given error.  Leave nil to retry all errors.
 	RetryCallback func(error) bool
 
 	// RetryCount is number of query retries to be issued if query returns
-	// error.  Leave 0 to never retry query errors.
+	// error.  Leave 0 to never retry query errors.  Regardless of RetryCount
+	// and RetryCallback, a query that cannot reach a server is sent to the
+	// next server not yet tried, until every server has been tried.
 	RetryCount int
 
 	// RetryPause is the amount of time to wait before retrying the query.
 	RetryPause time.Duration
 
+	// RetryWrites causes the write methods, such as AddToCluster, to retry
+	// failed writes as queries are retried, using RetryCount, RetryPause, and
+	// RetryCallback, sending each attempt to the next range server.  Leave
+	// false unless the range servers apply writes idempotently, because a
+	// write whose response was lost may be applied more than once.
+	RetryWrites bool
+
+	// RemovePath is the path of the range server endpoint to which
+	// RemoveFromCluster sends writes.  When empty, the sibling of Path named
+	// remove is used, such as /range/remove.
+	RemovePath string
+
+	// ReverseOperator is the range operator prefixed to a host to query the
+	// clusters containing it, used by ClustersOf.  When empty,
+	// DefaultReverseOperator is used.  Some deployments use "?" instead.
+	ReverseOperator string
+
+	// RewriteExpression, when not nil, is applied to each expression before
+	// it is sent to range servers, for site-specific conventions such as
+	// appending a default domain, mapping legacy cluster aliases, or
+	// injecting an environment prefix.  A query whose expression it returns
+	// an error for fails with that error, without being sent.  Results are
+	// cached and coalesced by the original expression.
+	RewriteExpression func(string) (string, error)
+
+	// ScanBufferSize is the initial size, in bytes, of the buffers into which
+	// Query, QueryCtx, QueryForEach, and QueryStream read response lines.
+	// Buffers are pooled and reused across queries, and a buffer grows, up to
+	// MaxLineLength, only for a response with a longer line.  Callers whose
+	// responses are typically large may set it near their typical response
+	// size, so each response is read in fewer, larger reads.  When zero,
+	// DefaultScanBufferSize is used.
+	ScanBufferSize int
+
+	// Scheme is the URL scheme, either "http" or "https", used to send
+	// requests to Servers given as addresses rather than URLs.  When empty,
+	// "http" is used.
+	Scheme string
+
 	// Servers is slice of range server address strings.  Must contain at least
-	// one string.
+	// one string.  Each is either an address, such as "range.example.com:8081",
+	// to which requests are sent using Scheme, or a URL, such as
+	// "https://range.example.com:8443/prefix", whose scheme and path prefix
+	// are used for requests sent to it.
 	Servers []string
 
+	// Snapshot, when not nil, answers every query from a snapshot of query
+	// results, such as one read by ReadSnapshot, without contacting any range
+	// server, in place of HTTPClient.  Servers may be left empty.  See
+	// Client.Snapshot.
+	Snapshot *Snapshot
+
+	// Sorted causes the results returned by Query and QueryCtx to be sorted in
+	// the natural order defined by CompareHosts, so host9 sorts before host10.
+	Sorted bool
+
+	// Unique causes duplicate values to be removed from the results returned by
+	// Query and QueryCtx, as by Unique, for range servers that return the same
+	// host more than once from overlapping unions.
+	Unique bool
+
 	// UserAgent is a string added to the HTTP headers and is intended to
 	// identify clients requesting online content.  When none is provided,
-	// the default Go user agent will be used.
+	// the default Go user agent will be used.  WithQueryUserAgent overrides
+	// it for individual queries.  The library never reads identifying
+	// information, such as the user name, host name, or executable path, from
+	// the environment, so requests identify their client only as configured.
 	// https://go.dev/src/net/http/request.go#L514
 	UserAgent string
+
+	// ZeroCopy causes Query and QueryCtx to read each response body into a
+	// single string, and return results that are substrings of it, rather
+	// than allocating each result separately, which greatly reduces
+	// allocations for large responses.  Because the results share the memory
+	// of the body, retaining any one of them retains the entire body.  Even
+	// when false, results share strings of several kilobytes each, as
+	// described by QueryCtx.
+	ZeroCopy bool
 }
 
 // Doer performs the specfied http.Request and returns the http.Response.
@@ -0,0 +1,83 @@
+package orange
+
+import (
+	"sort"
+	"strings"
+)
+
+// Diff compares two lists of results, returning the values found in after but
+// not in before, and the values found in before but not in after, useful for
+// change detection.  Added values are returned in the order they appear in
+// after, and removed values in the order they appear in before, without
+// duplicates.
+//
+// When both lists are sorted, either lexicographically or in the natural order
+// defined by CompareHosts, Diff merges them in a single pass without
+// allocating a set, which is significantly faster for large host lists.
+// Otherwise it falls back to using a set of the values of each list.
+func Diff(before, after []string) (added, removed []string) {
+	if sort.StringsAreSorted(before) && sort.StringsAreSorted(after) {
+		return diffSorted(before, after, strings.Compare)
+	}
+	if hostsAreSorted(before) && hostsAreSorted(after) {
+		return diffSorted(before, after, CompareHosts)
+	}
+
+	beforeSet, afterSet := toSet(before), toSet(after)
+	added = Results(after).filterSet(beforeSet, false)
+	removed = Results(before).filterSet(afterSet, false)
+	return nilIfEmpty(added), nilIfEmpty(removed)
+}
+
+func hostsAreSorted(hosts []string) bool {
+	for i := 1; i < len(hosts); i++ {
+		if CompareHosts(hosts[i-1], hosts[i]) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// diffSorted merges two lists that are both sorted according to compare.
+func diffSorted(before, after []string, compare func(string, string) int) (added, removed []string) {
+	var i, j int
+	for i < len(before) && j < len(after) {
+		switch c := compare(before[i], after[j]); {
+		case c < 0:
+			removed = append(removed, before[i])
+			i = skipRepeated(before, i)
+		case c > 0:
+			added = append(added, after[j])
+			j = skipRepeated(after, j)
+		default:
+			i = skipRepeated(before, i)
+			j = skipRepeated(after, j)
+		}
+	}
+	for i < len(before) {
+		removed = append(removed, before[i])
+		i = skipRepeated(before, i)
+	}
+	for j < len(after) {
+		added = append(added, after[j])
+		j = skipRepeated(after, j)
+	}
+	return added, removed
+}
+
+// skipRepeated returns the index of the first value after i that differs from
+// the value at i.
+func skipRepeated(values []string, i int) int {
+	j := i + 1
+	for j < len(values) && values[j] == values[i] {
+		j++
+	}
+	return j
+}
+
+func nilIfEmpty(values []string) []string {
+	if len(values) == 0 {
+		return nil
+	}
+	return values
+}
@@ -0,0 +1,78 @@
+package orange
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	cases := []struct {
+		name           string
+		before, after  []string
+		added, removed []string
+	}{
+		{"empty", nil, nil, nil, nil},
+		{"all added", nil, []string{"a", "b"}, []string{"a", "b"}, nil},
+		{"all removed", []string{"a", "b"}, nil, nil, []string{"a", "b"}},
+		{
+			"sorted",
+			[]string{"a", "b", "c", "e"},
+			[]string{"b", "c", "d", "f"},
+			[]string{"d", "f"},
+			[]string{"a", "e"},
+		},
+		{
+			"sorted with duplicates",
+			[]string{"a", "a", "b", "c", "c"},
+			[]string{"a", "d", "d"},
+			[]string{"d"},
+			[]string{"b", "c"},
+		},
+		{
+			"natural order",
+			[]string{"web2", "web9", "web10"},
+			[]string{"web9", "web10", "web11"},
+			[]string{"web11"},
+			[]string{"web2"},
+		},
+		{
+			"unsorted",
+			[]string{"c", "a", "b", "a"},
+			[]string{"d", "b", "c", "d"},
+			[]string{"d"},
+			[]string{"a"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			added, removed := Diff(c.before, c.after)
+			ensureResultsEqual(t, added, c.added)
+			ensureResultsEqual(t, removed, c.removed)
+		})
+	}
+}
+
+func BenchmarkDiff(b *testing.B) {
+	const count = 100000
+
+	before := make([]string, count)
+	after := make([]string, count)
+	for i := 0; i < count; i++ {
+		before[i] = fmt.Sprintf("host%07d", i)
+		after[i] = fmt.Sprintf("host%07d", i+count/10)
+	}
+
+	b.Run("sorted", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			_, _ = Diff(before, after)
+		}
+	})
+
+	b.Run("unsorted", func(b *testing.B) {
+		unsorted := append([]string{after[count-1]}, after[:count-1]...)
+		for i := 0; i < b.N; i++ {
+			_, _ = Diff(before, unsorted)
+		}
+	})
+}
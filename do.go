@@ -0,0 +1,82 @@
+package orange
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Request is a low-level request sent using Client.Do, for endpoints and
+// response formats the query methods do not handle.
+type Request struct {
+	// Expression is the range expression sent in the request.
+	Expression string
+
+	// Path is the path of the range server endpoint, such as
+	// "/range/reverse".  When empty, Config.Path is used.
+	Path string
+
+	// Method is the preferred HTTP method, either GET or PUT.  When empty,
+	// GET is used, unless the expression is too long to send in a URL, in
+	// which case PUT is used.  Either way, the other method is tried when the
+	// range server rejects the preferred one.
+	Method string
+
+	// Header holds additional headers sent with the request, which replace
+	// any of the same name the client would otherwise send.
+	Header http.Header
+
+	// Callback, when not nil, is invoked with the body of a successful
+	// response.  When nil, the body is read into Response.Body.
+	Callback func(io.Reader) error
+
+	// The following fields are set by QueryWithOptions.
+	server     string // server is the only server sent the request, when not empty
+	retryCount *int   // retryCount overrides Config.RetryCount, when not nil
+}
+
+// Do sends request to the range servers with the provided context, using the
+// client's server selection, retries, stats, and hooks, and returns metadata
+// describing how it was resolved.  It gives full control over the endpoint,
+// method, headers, and response handling to callers using range server
+// endpoints the query methods do not support.
+//
+//     response, err := client.Do(ctx, &orange.Request{
+//         Expression: "web42.example.com",
+//         Path:       "/range/reverse",
+//     })
+//     if err != nil {
+//         return err
+//     }
+//     clusters := response.Split()
+//
+// Like the query methods, Do fails with *ErrRangeException when the response
+// has a RangeException header, and with *ErrStatusNotOK when its status is not
+// 200.  Interceptors and the cache are not used.
+func (c *Client) Do(ctx context.Context, request *Request) (*Response, error) {
+	switch request.Method {
+	case "", http.MethodGet, http.MethodPut:
+	default:
+		return nil, fmt.Errorf("cannot send request using unsupported method: %q", request.Method)
+	}
+
+	response := &Response{Expression: request.Expression}
+	start := time.Now()
+
+	rr := *request
+	if rr.Callback == nil {
+		rr.Callback = func(ior io.Reader) error {
+			var err error
+			response.Body, err = readAllPooled(ior)
+			return err
+		}
+	}
+	if err := c.do(ctx, &rr, response); err != nil {
+		return nil, err
+	}
+
+	response.Duration = time.Since(start)
+	return response, nil
+}
@@ -0,0 +1,78 @@
+package orange
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"testing"
+)
+
+func TestDo(t *testing.T) {
+	t.Run("custom endpoint", func(t *testing.T) {
+		withClient(t, func(w http.ResponseWriter, r *http.Request) {
+			if got, want := r.URL.Path, "/range/reverse"; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+			if got, want := r.Method, http.MethodPut; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+			if got, want := r.FormValue("query"), "web42"; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+			if got, want := r.Header.Get("X-Team"), "billing"; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+			_, _ = w.Write([]byte("%webservers\n%frontends\n"))
+		}, func(client *Client) {
+			response, err := client.Do(context.Background(), &Request{
+				Expression: "web42",
+				Path:       "/range/reverse",
+				Method:     http.MethodPut,
+				Header:     http.Header{"X-Team": []string{"billing"}},
+			})
+			ensureError(t, err)
+			ensureStringSlicesMatch(t, response.Split(), []string{"%webservers", "%frontends"})
+			if got, want := response.Method, http.MethodPut; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+			if got, want := response.Attempts, 1; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+		})
+	})
+
+	t.Run("callback", func(t *testing.T) {
+		withClient(t, func(w http.ResponseWriter, r *http.Request) {
+			if got, want := r.URL.Path, DefaultPath; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+			_, _ = w.Write([]byte("host1\n"))
+		}, func(client *Client) {
+			var body []byte
+			response, err := client.Do(context.Background(), &Request{
+				Expression: "%someCluster",
+				Callback: func(ior io.Reader) error {
+					var err error
+					body, err = io.ReadAll(ior)
+					return err
+				},
+			})
+			ensureError(t, err)
+			if got, want := string(body), "host1\n"; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+			if got, want := len(response.Body), 0; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+		})
+	})
+
+	t.Run("unsupported method", func(t *testing.T) {
+		withClient(t, func(w http.ResponseWriter, r *http.Request) {
+			t.Error("request should not be sent")
+		}, func(client *Client) {
+			_, err := client.Do(context.Background(), &Request{Expression: "%someCluster", Method: http.MethodDelete})
+			ensureError(t, err, "unsupported method")
+		})
+	})
+}
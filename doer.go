@@ -0,0 +1,103 @@
+package orange
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// DoerFunc allows a function to be used as a Doer.
+type DoerFunc func(*http.Request) (*http.Response, error)
+
+// Do returns f(request).
+func (f DoerFunc) Do(request *http.Request) (*http.Response, error) { return f(request) }
+
+// attemptKey is the context key of the zero-based attempt number of a request
+// sent by a Client.
+type attemptKey struct{}
+
+// requestAttempt returns the zero-based count of times the query sending
+// request has been retried, or 0 when request was not sent by a Client.
+func requestAttempt(request *http.Request) int {
+	attempt, _ := request.Context().Value(attemptKey{}).(int)
+	return attempt
+}
+
+// withAttempt returns a copy of ctx recording the attempt number of the
+// request sent with it.
+func withAttempt(ctx context.Context, attempt int) context.Context {
+	return context.WithValue(ctx, attemptKey{}, attempt)
+}
+
+// WithHeaders returns a Doer that sends each request using doer, with the
+// values of header set in place of any it already has, such as an
+// authentication token that a custom transport requires.
+//
+//     client, err := orange.NewClient(&orange.Config{
+//         HTTPClient: orange.WithHeaders(http.DefaultClient, http.Header{
+//             "X-Team": []string{"billing"},
+//         }),
+//         Servers: servers,
+//     })
+//
+// The request is cloned before its headers are modified, so the caller's
+// request is left unchanged.
+func WithHeaders(doer Doer, header http.Header) Doer {
+	return DoerFunc(func(request *http.Request) (*http.Response, error) {
+		request = request.Clone(request.Context())
+		for key, values := range header {
+			request.Header[http.CanonicalHeaderKey(key)] = append([]string(nil), values...)
+		}
+		return doer.Do(request)
+	})
+}
+
+// WithLogging returns a Doer that sends each request using doer, and logs its
+// method, URL, status code, duration, and error using logf, whose signature
+// matches log.Printf and Config.Debugf.
+//
+//     client, err := orange.NewClient(&orange.Config{
+//         HTTPClient: orange.WithLogging(http.DefaultClient, log.Printf),
+//         Servers:    servers,
+//     })
+func WithLogging(doer Doer, logf func(format string, args ...interface{})) Doer {
+	return DoerFunc(func(request *http.Request) (*http.Response, error) {
+		start := time.Now()
+		response, err := doer.Do(request)
+		var statusCode int
+		if response != nil {
+			statusCode = response.StatusCode
+		}
+		logf("orange: %s %s: status %d; duration: %s; error: %v",
+			request.Method, request.URL, statusCode, time.Since(start), err)
+		return response, err
+	})
+}
+
+// WithRetryMetrics returns a Doer that sends each request using doer, and
+// invokes observe with the zero-based count of times the query sending the
+// request has been retried, along with the status code of the response, or 0
+// when no response was received, and the error, if any.  Requests not sent by
+// a Client are observed as attempt 0.
+//
+//     var requests, retries, failures atomic.Uint64
+//     doer := orange.WithRetryMetrics(http.DefaultClient, func(attempt, statusCode int, err error) {
+//         requests.Add(1)
+//         if attempt > 0 {
+//             retries.Add(1)
+//         }
+//         if err != nil || statusCode >= 500 {
+//             failures.Add(1)
+//         }
+//     })
+func WithRetryMetrics(doer Doer, observe func(attempt, statusCode int, err error)) Doer {
+	return DoerFunc(func(request *http.Request) (*http.Response, error) {
+		response, err := doer.Do(request)
+		var statusCode int
+		if response != nil {
+			statusCode = response.StatusCode
+		}
+		observe(requestAttempt(request), statusCode, err)
+		return response, err
+	})
+}
@@ -0,0 +1,89 @@
+package orange
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWithHeaders(t *testing.T) {
+	var got http.Header
+	doer := WithHeaders(DoerFunc(func(request *http.Request) (*http.Response, error) {
+		got = request.Header
+		return mockResponse(request, http.StatusOK, make(http.Header), ""), nil
+	}), http.Header{"x-team": []string{"billing"}, "User-Agent": []string{"override"}})
+
+	request, err := http.NewRequest(http.MethodGet, "http://range/range/list?foo", nil)
+	ensureError(t, err)
+	request.Header.Set("User-Agent", "original")
+
+	_, err = doer.Do(request)
+	ensureError(t, err)
+
+	if got, want := got.Get("X-Team"), "billing"; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+	if got, want := got.Get("User-Agent"), "override"; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+	if got, want := request.Header.Get("User-Agent"), "original"; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+}
+
+func TestWithLogging(t *testing.T) {
+	var logs []string
+	doer := WithLogging(DoerFunc(func(request *http.Request) (*http.Response, error) {
+		return mockResponse(request, http.StatusNotFound, make(http.Header), ""), nil
+	}), func(format string, args ...interface{}) {
+		logs = append(logs, fmt.Sprintf(format, args...))
+	})
+
+	request, err := http.NewRequest(http.MethodGet, "http://range/range/list?foo", nil)
+	ensureError(t, err)
+	_, err = doer.Do(request)
+	ensureError(t, err)
+
+	if got, want := len(logs), 1; got != want {
+		t.Fatalf("GOT: %v; WANT: %v", got, want)
+	}
+	if got, want := logs[0], "orange: GET http://range/range/list?foo: status 404;"; !strings.HasPrefix(got, want) {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+}
+
+func TestWithRetryMetrics(t *testing.T) {
+	var requests int
+	withTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte("host1\n"))
+	}, func(server *httptest.Server) {
+		var attempts, statusCodes []int
+		client, err := NewClient(&Config{
+			HTTPClient: WithRetryMetrics(server.Client(), func(attempt, statusCode int, err error) {
+				attempts = append(attempts, attempt)
+				statusCodes = append(statusCodes, statusCode)
+			}),
+			RetryCallback: func(error) bool { return true },
+			RetryCount:    2,
+			Servers:       []string{strings.TrimPrefix(server.URL, "http://")},
+		})
+		ensureError(t, err)
+
+		_, err = client.Query("%someCluster")
+		ensureError(t, err)
+
+		if got, want := fmt.Sprint(attempts), "[0 1 2]"; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := fmt.Sprint(statusCodes), "[503 503 200]"; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+}
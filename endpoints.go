@@ -0,0 +1,113 @@
+package orange
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Expand sends the query expression to the expand endpoint of the range
+// servers with the provided query context, and returns the expansion exactly as
+// the range server provides it, typically the results folded into compact
+// range notation, such as "web{1-3,7}.example.com".  Unlike Query, the client
+// neither splits, de-duplicates, nor sorts the expansion.  When the response
+// spans more than one line, the lines are joined by commas.
+//
+//     expansion, err := client.Expand(ctx, "%webservers")
+//
+// The expand endpoint is a sibling of Config.Path, such as /v1/range/expand
+// when Config.Path is /v1/range/list, or otherwise /range/expand.
+func (c *Client) Expand(ctx context.Context, expression string) (string, error) {
+	var lines []string
+	var meta Response
+	err := c.do(ctx, &Request{
+		Expression: expression,
+		Path:       c.siblingPath("expand"),
+		Callback: func(ior io.Reader) error {
+			return c.scanLines(ior, meta.Delimiter, func(line string) error {
+				lines = append(lines, line)
+				return nil
+			})
+		},
+	}, &meta)
+	if err != nil {
+		return "", err
+	}
+	return strings.Join(lines, ","), nil
+}
+
+// siblingPath returns the path of the range server endpoint named name, which
+// shares its prefix with Config.Path.
+func (c *Client) siblingPath(name string) string {
+	return siblingPath(c.path, name)
+}
+
+// siblingPath returns the path of the range server endpoint named name, which
+// shares its prefix with path when path ends with /list, or otherwise the path
+// of the endpoint in /range.
+func siblingPath(path, name string) string {
+	if prefix := strings.TrimSuffix(path, "/list"); prefix != path {
+		return prefix + "/" + name
+	}
+	return "/range/" + name
+}
+
+// ClustersOf returns the clusters containing host, using the reverse lookup
+// operator of Config.ReverseOperator, such as the query "*web42.example.com".
+// Like QueryCtx, its results may be cached, coalesced, and passed through
+// Interceptors.
+//
+//     clusters, err := client.ClustersOf(ctx, "web42.example.com")
+func (c *Client) ClustersOf(ctx context.Context, host string) ([]string, error) {
+	expression, err := clustersOfExpression(c.reverseOperator, host)
+	if err != nil {
+		return nil, err
+	}
+	return c.QueryCtx(ctx, expression)
+}
+
+// clustersOfExpression returns the expression querying the clusters containing
+// host using the reverse lookup operator, or an error when host is empty.
+func clustersOfExpression(operator, host string) (string, error) {
+	if host == "" {
+		return "", errors.New("cannot query clusters of empty host")
+	}
+	return operator + host, nil
+}
+
+// KeysOf returns the keys defined on cluster, using the %cluster:KEYS query.
+// The leading percent sign of cluster is optional.  Like QueryCtx, its results
+// may be cached, coalesced, and passed through Interceptors.
+//
+//     keys, err := client.KeysOf(ctx, "webservers")
+//
+// Range has no way to escape operators within a cluster name, so rather than
+// sending a query that means something else, KeysOf returns an error when
+// cluster has characters other than letters, digits, hyphens, underscores, and
+// periods.
+func (c *Client) KeysOf(ctx context.Context, cluster string) ([]string, error) {
+	expression, err := clusterKeyExpression(cluster, "KEYS")
+	if err != nil {
+		return nil, err
+	}
+	return c.QueryCtx(ctx, expression)
+}
+
+// clusterKeyExpression returns the expression querying key of cluster, whose
+// leading percent sign is optional, or an error when cluster is not a valid
+// cluster name.
+func clusterKeyExpression(cluster, key string) (string, error) {
+	name := strings.TrimPrefix(cluster, "%")
+	if name == "" {
+		return "", errors.New("cannot query key of empty cluster name")
+	}
+	for i := 0; i < len(name); i++ {
+		b := name[i]
+		if !('a' <= b && b <= 'z' || 'A' <= b && b <= 'Z' || '0' <= b && b <= '9' || b == '-' || b == '_' || b == '.') {
+			return "", fmt.Errorf("cannot query key of cluster with invalid name: %q", cluster)
+		}
+	}
+	return "%" + name + ":" + key, nil
+}
@@ -0,0 +1,92 @@
+package orange
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestExpand(t *testing.T) {
+	withClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if got, want := r.URL.Path, "/range/expand"; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		_, _ = w.Write([]byte("web{1-3,7}.example.com\n"))
+	}, func(client *Client) {
+		expansion, err := client.Expand(context.Background(), "%webservers")
+		ensureError(t, err)
+		if got, want := expansion, "web{1-3,7}.example.com"; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+}
+
+func TestSiblingPath(t *testing.T) {
+	for path, want := range map[string]string{
+		"":                "/range/expand",
+		"/v1/range/list":  "/v1/range/expand",
+		"/range/api/list": "/range/api/expand",
+		"/query":          "/range/expand",
+	} {
+		client, err := NewClient(&Config{Path: path, Servers: []string{"range:8081"}})
+		ensureError(t, err)
+		if got := client.siblingPath("expand"); got != want {
+			t.Errorf("%q: GOT: %v; WANT: %v", path, got, want)
+		}
+	}
+}
+
+func TestClustersOf(t *testing.T) {
+	h := func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.RawQuery {
+		case "%2Aweb42.example.com", "%3Fweb42.example.com":
+			_, _ = w.Write([]byte("webservers\nfrontends\n"))
+		default:
+			w.Header().Set("RangeException", "unexpected query "+r.URL.RawQuery)
+		}
+	}
+
+	withClient(t, h, func(client *Client) {
+		clusters, err := client.ClustersOf(context.Background(), "web42.example.com")
+		ensureError(t, err)
+		ensureStringSlicesMatch(t, clusters, []string{"webservers", "frontends"})
+
+		_, err = client.ClustersOf(context.Background(), "")
+		ensureError(t, err, "empty host")
+	})
+
+	withTestServer(t, h, func(server *httptest.Server) {
+		client, err := NewClient(&Config{
+			HTTPClient:      server.Client(),
+			ReverseOperator: "?",
+			Servers:         []string{strings.TrimPrefix(server.URL, "http://")},
+		})
+		ensureError(t, err)
+
+		clusters, err := client.ClustersOf(context.Background(), "web42.example.com")
+		ensureError(t, err)
+		ensureStringSlicesMatch(t, clusters, []string{"webservers", "frontends"})
+	})
+}
+
+func TestKeysOf(t *testing.T) {
+	withClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if got, want := r.URL.RawQuery, "%25web-servers_1.prod%3AKEYS"; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		_, _ = w.Write([]byte("CLUSTER\nOWNER\n"))
+	}, func(client *Client) {
+		for _, cluster := range []string{"web-servers_1.prod", "%web-servers_1.prod"} {
+			keys, err := client.KeysOf(context.Background(), cluster)
+			ensureError(t, err)
+			ensureStringSlicesMatch(t, keys, []string{"CLUSTER", "OWNER"})
+		}
+
+		for _, cluster := range []string{"", "%", "web,db", "web:KEYS", "%{web}", "web servers"} {
+			_, err := client.KeysOf(context.Background(), cluster)
+			ensureError(t, err, "cannot query key of")
+		}
+	})
+}
@@ -1,31 +1,222 @@
 package orange
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"net"
-	"net/url"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
 )
 
+// ErrNoServersAvailable is wrapped in the error returned by a query when every
+// configured range server, or the one server given by QueryOptions.Server, was
+// tried and none could be reached, so callers and dashboards can distinguish
+// "range is down" from "my query is bad".  Because queries fail over from
+// unreachable servers regardless of the Retry settings, every server is tried
+// before it is returned.
+//
+//     if errors.Is(err, orange.ErrNoServersAvailable) {
+//         // range is down
+//     }
+var ErrNoServersAvailable = errors.New("no range servers available")
+
+// QueryError wraps an error returned while querying a range server with the
+// server address, the HTTP method, and the number of attempts made, so a failure
+// log line tells the operator which replica to look at without reproducing the
+// failure.  Use errors.As to obtain the wrapped error.
+type QueryError struct {
+	Expression string // Expression is the range expression that was queried.
+	Server     string // Server is the address of the range server that was last queried.
+	Method     string // Method is the HTTP method of the final request.
+	Attempts   int    // Attempts is the number of attempts made to resolve the query.
+	Err        error  // Err is the underlying error.
+
+	// includeExpression is true when Config.IncludeExpressionInErrors was set
+	// for the Client that returned the error.
+	includeExpression bool
+}
+
+func (err *QueryError) Error() string {
+	if err.includeExpression {
+		return fmt.Sprintf("%s %s (attempt %d; expression %s): %s", err.Method, err.Server, err.Attempts, sanitizeExpression(err.Expression), err.Err)
+	}
+	return fmt.Sprintf("%s %s (attempt %d): %s", err.Method, err.Server, err.Attempts, err.Err)
+}
+
+// maxErrorExpressionLength is the maximum number of characters of an
+// expression included in an error message.
+const maxErrorExpressionLength = 80
+
+// sanitizeExpression returns a quoted copy of expression suitable for
+// including in an error message, with control characters escaped and long
+// expressions truncated.
+func sanitizeExpression(expression string) string {
+	if utf8.RuneCountInString(expression) > maxErrorExpressionLength {
+		runes := []rune(expression)
+		return strconv.Quote(string(runes[:maxErrorExpressionLength])) + "..."
+	}
+	return strconv.Quote(expression)
+}
+
+// Unwrap returns the underlying error.
+func (err *QueryError) Unwrap() error { return err.Err }
+
 // ErrRangeException is returned when the response includes an HTTP
-// 'RangeException' header.
+// 'RangeException' header.  Use errors.As to obtain the error from a returned
+// error chain, or errors.Is with a zero value target to test whether any error
+// in the chain is a RangeException.
+//
+//     var re *orange.ErrRangeException
+//     if errors.As(err, &re) {
+//         fmt.Fprintf(os.Stderr, "bad expression: %s\n", re.Message)
+//     }
+//
+//     if errors.Is(err, &orange.ErrRangeException{}) {
+//         // any RangeException
+//     }
 type ErrRangeException struct {
+	// Body contains the HTTP response body from the server, limited to
+	// Config.MaxErrorBodyBytes.
+	Body []byte
+
+	// Message is the complete text of the RangeException header.
 	Message string
+
+	// The following fields are parsed from Message when it uses one of the
+	// common RangeException formats, so tooling can highlight the broken part
+	// of the expression.  Each is the zero value when not found in Message.
+	//
+	// Kind is the leading upper case error class, such as "NOCLUSTER" in
+	// "NOCLUSTER: no such cluster: 'foo'".  Token is the first quoted string,
+	// or otherwise the word following "near", identifying the offending part of the
+	// expression.  Position is the character position reported using phrases
+	// such as "at position 12", "at char 12", "column 12", or "offset 12".
+	Kind     string
+	Token    string
+	Position int
 }
 
-func (err ErrRangeException) Error() string {
+// newErrRangeException returns an ErrRangeException for message, with its
+// structured fields parsed from message.
+func newErrRangeException(message string) *ErrRangeException {
+	err := &ErrRangeException{Message: message}
+
+	if m := rangeExceptionKind.FindStringSubmatch(message); m != nil {
+		err.Kind = m[1]
+	}
+	if m := rangeExceptionQuoted.FindStringSubmatch(message); m != nil {
+		err.Token = m[1] + m[2] + m[3] // only one alternative matches
+	} else if m := rangeExceptionNear.FindStringSubmatch(message); m != nil {
+		err.Token = strings.TrimRight(m[1], ",;:.")
+	}
+	if m := rangeExceptionPosition.FindStringSubmatch(message); m != nil {
+		err.Position, _ = strconv.Atoi(m[1])
+	}
+
+	return err
+}
+
+var (
+	rangeExceptionKind     = regexp.MustCompile(`^\s*([A-Z][A-Z0-9_]*)\s*:`)
+	rangeExceptionQuoted   = regexp.MustCompile(`'([^']*)'|"([^"]*)"|` + "`([^`]*)`")
+	rangeExceptionNear     = regexp.MustCompile(`\bnear\s+(\S+)`)
+	rangeExceptionPosition = regexp.MustCompile(`(?i)\b(?:position|pos|char|character|column|col|offset)\s+(\d+)`)
+)
+
+func (err *ErrRangeException) Error() string {
 	return "RangeException: " + err.Message
 }
 
-// ErrStatusNotOK is returned when the response status code is not Ok.
+// Is returns true when target is an *ErrRangeException whose Message is either
+// empty or matches the Message of err.
+func (err *ErrRangeException) Is(target error) bool {
+	t, ok := target.(*ErrRangeException)
+	return ok && (t.Message == "" || t.Message == err.Message)
+}
+
+// ErrStatusNotOK is returned when the response status code is not Ok.  Use
+// errors.As to obtain the error from a returned error chain, or errors.Is with a
+// target whose StatusCode is either zero to match any status, or a specific
+// status code.
+//
+//     if errors.Is(err, &orange.ErrStatusNotOK{StatusCode: http.StatusServiceUnavailable}) {
+//         // range server is unavailable
+//     }
 type ErrStatusNotOK struct {
-	Body       []byte // Body contains the HTTP response body from the server.
-	Status     string // Status is the canonical HTTP status message.
-	StatusCode int    // StatusCode contains the numerical HTTP status code from the server.
+	Body       []byte      // Body contains the HTTP response body from the server, limited to Config.MaxErrorBodyBytes.
+	Header     http.Header // Header contains the HTTP response headers, such as proxy markers or authentication challenges.
+	Status     string      // Status is the canonical HTTP status message.
+	StatusCode int         // StatusCode contains the numerical HTTP status code from the server.
 }
 
-func (err ErrStatusNotOK) Error() string {
+func (err *ErrStatusNotOK) Error() string {
 	return err.Status
 }
 
+// Is returns true when target is an *ErrStatusNotOK whose StatusCode is either
+// zero or matches the StatusCode of err.
+func (err *ErrStatusNotOK) Is(target error) bool {
+	t, ok := target.(*ErrStatusNotOK)
+	return ok && (t.StatusCode == 0 || t.StatusCode == err.StatusCode)
+}
+
+// ErrTooManyRequests is returned when the range server responds with status
+// 429 Too Many Requests, carrying the parsed Retry-After duration so callers
+// implementing their own scheduling can back off intelligently.  It wraps an
+// *ErrStatusNotOK, so callers testing for that error continue to work.
+type ErrTooManyRequests struct {
+	ErrStatusNotOK
+
+	// RetryAfter is the amount of time the server asked the client to wait
+	// before sending another query, or 0 when the response did not include a
+	// valid Retry-After header.
+	RetryAfter time.Duration
+}
+
+func (err *ErrTooManyRequests) Error() string {
+	if err.RetryAfter > 0 {
+		return fmt.Sprintf("%s (retry after %s)", err.Status, err.RetryAfter)
+	}
+	return err.Status
+}
+
+// Is returns true when target is an *ErrTooManyRequests.  Without it the Is
+// method promoted from the embedded ErrStatusNotOK would only match
+// *ErrStatusNotOK targets.
+func (err *ErrTooManyRequests) Is(target error) bool {
+	_, ok := target.(*ErrTooManyRequests)
+	return ok
+}
+
+// Unwrap returns the embedded *ErrStatusNotOK.
+func (err *ErrTooManyRequests) Unwrap() error { return &err.ErrStatusNotOK }
+
+// parseRetryAfter returns the duration specified by a Retry-After header value,
+// which is either a number of seconds or an HTTP date, or 0 when the value is
+// empty or invalid.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if when, err := http.ParseTime(value); err == nil {
+		if d := when.Sub(now); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
 ////////////////////////////////////////
 // Some utility functions for the default method of whether or not a query with
 // an error result ought to be retried.
@@ -39,35 +230,79 @@ type timeout interface {
 }
 
 func isTemporary(err error) bool {
-	t, ok := err.(temporary)
-	return ok && t.Temporary()
+	var t temporary
+	return errors.As(err, &t) && t.Temporary()
 }
 
 func isTimeout(err error) bool {
-	t, ok := err.(timeout)
-	return ok && t.Timeout()
+	var t timeout
+	return errors.As(err, &t) && t.Timeout()
+}
+
+// isUnreachable returns true when err indicates the range server could not be
+// reached, either because its address could not be resolved or a connection to
+// it could not be established.
+func isUnreachable(err error) bool {
+	var dnsError *net.DNSError
+	if errors.As(err, &dnsError) {
+		return true
+	}
+	var opError *net.OpError
+	return errors.As(err, &opError) && opError.Op == "dial"
 }
 
 func makeRetryCallback(count int) func(error) bool {
-	return func(err error) bool {
-		// Because some DNSError errors can be temporary or timeout, most
-		// efficient to check whether those conditions are true first.
-		if isTemporary(err) || isTimeout(err) {
-			return true
-		}
-		// And if error is neither temporary nor a timeout, then it might still
-		// be retryable if it's a DNSError and there are more than one servers
-		// configured to proxy for.
-		if urlError, ok := err.(*url.Error); ok {
-			if netOpError, ok := urlError.Err.(*net.OpError); ok {
-				if _, ok = netOpError.Err.(*net.DNSError); ok {
-					// "no such host": This query may be retried either if there
-					// are more servers in the list of servers, or if the DNS
-					// lookup resulted in a timeout.
-					return count > 1
-				}
-			}
-		}
+	return func(err error) bool { return isRetryable(err, count) }
+}
+
+// isRetryable returns true when the default retry policy retries err for a
+// Client configured with count servers.
+func isRetryable(err error, count int) bool {
+	// Because some DNSError errors can be temporary or timeout, most efficient
+	// to check whether those conditions are true first.
+	if isTemporary(err) || isTimeout(err) {
+		return true
+	}
+	// And if error is neither temporary nor a timeout, then it might still be
+	// retryable if it's a DNSError and there are more than one servers
+	// configured to proxy for.  Use errors.As so errors wrapped anywhere along
+	// the retry path are still classified.
+	var dnsError *net.DNSError
+	if errors.As(err, &dnsError) {
+		// "no such host": This query may be retried either if there are more
+		// servers in the list of servers, or if the DNS lookup resulted in a
+		// timeout.
+		return count > 1
+	}
+	return false
+}
+
+////////////////////////////////////////
+// Exported classification helpers, so application-level retry and alerting
+// logic stays consistent with the library's own classification.
+
+// IsRetryable returns true when the default retry policy used by a Client
+// configured with more than one server would retry err: temporary errors,
+// timeouts, and errors resolving a server address, which may succeed when sent
+// to another server.  Errors reported by range servers, such as
+// *ErrRangeException and *ErrStatusNotOK, are not retryable, nor are
+// context.Canceled and context.DeadlineExceeded, because the caller's context
+// is done and another attempt would fail the same way.
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
 		return false
 	}
+	return isRetryable(err, 2)
+}
+
+// IsRangeException returns true when err or any error it wraps is an
+// *ErrRangeException.
+func IsRangeException(err error) bool {
+	return errors.Is(err, &ErrRangeException{})
+}
+
+// IsStatusNotOK returns true when err or any error it wraps is an
+// *ErrStatusNotOK, including an *ErrTooManyRequests.
+func IsStatusNotOK(err error) bool {
+	return errors.Is(err, &ErrStatusNotOK{})
 }
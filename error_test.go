@@ -0,0 +1,612 @@
+package orange
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestErrorsAs(t *testing.T) {
+	t.Run("ErrRangeException", func(t *testing.T) {
+		err := fmt.Errorf("wrapped: %w", &ErrRangeException{Message: "some error"})
+
+		var re *ErrRangeException
+		if !errors.As(err, &re) {
+			t.Fatalf("GOT: %v; WANT: %v", false, true)
+		}
+		if got, want := re.Message, "some error"; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+
+		if got, want := errors.Is(err, &ErrRangeException{}), true; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := errors.Is(err, &ErrRangeException{Message: "some error"}), true; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := errors.Is(err, &ErrRangeException{Message: "other error"}), false; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := errors.Is(err, &ErrStatusNotOK{}), false; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("ErrStatusNotOK", func(t *testing.T) {
+		err := fmt.Errorf("wrapped: %w", &ErrStatusNotOK{
+			Status:     http.StatusText(http.StatusBadGateway),
+			StatusCode: http.StatusBadGateway,
+		})
+
+		var se *ErrStatusNotOK
+		if !errors.As(err, &se) {
+			t.Fatalf("GOT: %v; WANT: %v", false, true)
+		}
+		if got, want := se.StatusCode, http.StatusBadGateway; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+
+		if got, want := errors.Is(err, &ErrStatusNotOK{}), true; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := errors.Is(err, &ErrStatusNotOK{StatusCode: http.StatusBadGateway}), true; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := errors.Is(err, &ErrStatusNotOK{StatusCode: http.StatusNotFound}), false; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+}
+
+func TestRetryCallback(t *testing.T) {
+	dnsError := &url.Error{
+		Op:  "Get",
+		URL: "http://range.example.com",
+		Err: &net.OpError{Op: "dial", Err: &net.DNSError{Err: "no such host", Name: "range.example.com"}},
+	}
+
+	t.Run("wrapped DNS error with multiple servers", func(t *testing.T) {
+		retry := makeRetryCallback(2)
+		if got, want := retry(fmt.Errorf("wrapped: %w", dnsError)), true; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("DNS error with single server", func(t *testing.T) {
+		retry := makeRetryCallback(1)
+		if got, want := retry(dnsError), false; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("RangeException", func(t *testing.T) {
+		retry := makeRetryCallback(2)
+		if got, want := retry(&ErrRangeException{Message: "some error"}), false; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+}
+
+func TestQueryError(t *testing.T) {
+	h := func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}
+	withTestServer(t, h, func(server *httptest.Server) {
+		address := strings.TrimLeft(server.URL, "http://")
+
+		client, err := NewClient(&Config{
+			HTTPClient:    server.Client(),
+			RetryCallback: func(err error) bool { return errors.Is(err, &ErrStatusNotOK{}) },
+			RetryCount:    2,
+			Servers:       []string{address},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = client.Query("foo")
+
+		var qe *QueryError
+		if !errors.As(err, &qe) {
+			t.Fatalf("GOT: %T; WANT: %T", err, qe)
+		}
+		if got, want := qe.Server, address; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := qe.Method, http.MethodGet; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := qe.Attempts, 3; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		ensureError(t, err, "GET "+address+" (attempt 3)", http.StatusText(http.StatusServiceUnavailable))
+
+		if got, want := errors.Is(err, &ErrStatusNotOK{StatusCode: http.StatusServiceUnavailable}), true; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+}
+
+func TestErrRangeExceptionParsing(t *testing.T) {
+	cases := []struct {
+		message  string
+		kind     string
+		token    string
+		position int
+	}{
+		{"some error", "", "", 0},
+		{"NOCLUSTER: No such cluster: 'foo'", "NOCLUSTER", "foo", 0},
+		{`SYNTAX: parse error near "}" at position 12`, "SYNTAX", "}", 12},
+		{"syntax error near %foo, column 3", "", "%foo", 3},
+		{"NO_KEY: key `ROLE` not defined for cluster", "NO_KEY", "ROLE", 0},
+		{"unexpected token at char 7", "", "", 7},
+	}
+
+	for _, c := range cases {
+		t.Run(c.message, func(t *testing.T) {
+			err := newErrRangeException(c.message)
+			if got, want := err.Message, c.message; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+			if got, want := err.Kind, c.kind; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+			if got, want := err.Token, c.token; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+			if got, want := err.Position, c.position; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+		})
+	}
+}
+
+func TestErrorBodyLimit(t *testing.T) {
+	body := strings.Repeat("x", 64)
+
+	newClient := func(t *testing.T, server *httptest.Server, limit int) *Client {
+		client, err := NewClient(&Config{
+			HTTPClient:        server.Client(),
+			MaxErrorBodyBytes: limit,
+			Servers:           []string{strings.TrimLeft(server.URL, "http://")},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return client
+	}
+
+	t.Run("ErrStatusNotOK truncated", func(t *testing.T) {
+		h := func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, body, http.StatusBadGateway)
+		}
+		withTestServer(t, h, func(server *httptest.Server) {
+			_, err := newClient(t, server, 16).Query("foo")
+			var e *ErrStatusNotOK
+			if !errors.As(err, &e) {
+				t.Fatalf("GOT: %T; WANT: %T", err, e)
+			}
+			if got, want := string(e.Body), body[:16]+string(errorBodyTruncated); got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+		})
+	})
+
+	t.Run("ErrStatusNotOK within limit", func(t *testing.T) {
+		h := func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadGateway)
+			w.Write([]byte(body))
+		}
+		withTestServer(t, h, func(server *httptest.Server) {
+			_, err := newClient(t, server, 64).Query("foo")
+			var e *ErrStatusNotOK
+			if !errors.As(err, &e) {
+				t.Fatalf("GOT: %T; WANT: %T", err, e)
+			}
+			if got, want := string(e.Body), body; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+		})
+	})
+
+	t.Run("ErrRangeException truncated", func(t *testing.T) {
+		h := func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("RangeException", "some error")
+			w.Write([]byte(body))
+		}
+		withTestServer(t, h, func(server *httptest.Server) {
+			_, err := newClient(t, server, 8).Query("foo")
+			var e *ErrRangeException
+			if !errors.As(err, &e) {
+				t.Fatalf("GOT: %T; WANT: %T", err, e)
+			}
+			if got, want := string(e.Body), body[:8]+string(errorBodyTruncated); got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+		})
+	})
+
+	t.Run("negative limit", func(t *testing.T) {
+		_, err := NewClient(&Config{MaxErrorBodyBytes: -1, Servers: []string{"localhost:8081"}})
+		ensureError(t, err, "negative MaxErrorBodyBytes")
+	})
+}
+
+func TestErrTooManyRequests(t *testing.T) {
+	t.Run("parse Retry-After", func(t *testing.T) {
+		now := time.Date(2019, time.June, 1, 12, 0, 0, 0, time.UTC)
+		cases := []struct {
+			value string
+			want  time.Duration
+		}{
+			{"", 0},
+			{"bogus", 0},
+			{"-5", 0},
+			{"120", 2 * time.Minute},
+			{now.Add(30 * time.Second).Format(http.TimeFormat), 30 * time.Second},
+			{now.Add(-30 * time.Second).Format(http.TimeFormat), 0},
+		}
+		for _, c := range cases {
+			if got, want := parseRetryAfter(c.value, now), c.want; got != want {
+				t.Errorf("%q: GOT: %v; WANT: %v", c.value, got, want)
+			}
+		}
+	})
+
+	t.Run("client", func(t *testing.T) {
+		h := func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Retry-After", "7")
+			http.Error(w, "slow down", http.StatusTooManyRequests)
+		}
+		withClient(t, h, func(client *Client) {
+			_, err := client.Query("foo")
+			ensureError(t, err, "retry after 7s")
+
+			var e *ErrTooManyRequests
+			if !errors.As(err, &e) {
+				t.Fatalf("GOT: %T; WANT: %T", err, e)
+			}
+			if got, want := e.RetryAfter, 7*time.Second; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+			ensureStringSlicesMatch(t, lines(e.Body), []string{"slow down"})
+
+			if got, want := errors.Is(err, &ErrStatusNotOK{StatusCode: http.StatusTooManyRequests}), true; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+			if got, want := errors.Is(err, &ErrTooManyRequests{}), true; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+		})
+	})
+
+	t.Run("is", func(t *testing.T) {
+		err := &QueryError{Err: &ErrStatusNotOK{StatusCode: http.StatusBadGateway}}
+		if got, want := errors.Is(err, &ErrTooManyRequests{}), false; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+}
+
+func TestErrNoServersAvailable(t *testing.T) {
+	// Obtain addresses of listeners that are immediately closed, so connections
+	// to them are refused.
+	closedAddress := func(t *testing.T) string {
+		t.Helper()
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		address := listener.Addr().String()
+		if err = listener.Close(); err != nil {
+			t.Fatal(err)
+		}
+		return address
+	}
+
+	t.Run("every server unreachable", func(t *testing.T) {
+		client, err := NewClient(&Config{
+			RetryCallback: func(error) bool { return true },
+			RetryCount:    1,
+			Servers:       []string{closedAddress(t), closedAddress(t)},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = client.Query("foo")
+		if got, want := errors.Is(err, ErrNoServersAvailable), true; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		var qe *QueryError
+		if !errors.As(err, &qe) {
+			t.Errorf("GOT: %T; WANT: %T", err, qe)
+		}
+	})
+
+	t.Run("default config", func(t *testing.T) {
+		// Unreachable servers are failed over to regardless of the Retry
+		// settings, so every server is tried.
+		client, err := NewClient(&Config{
+			Servers: []string{closedAddress(t), closedAddress(t)},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = client.Query("foo")
+		ensureError(t, err, "refused")
+		if got, want := errors.Is(err, ErrNoServersAvailable), true; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		var qe *QueryError
+		if !errors.As(err, &qe) {
+			t.Fatalf("GOT: %T; WANT: %T", err, qe)
+		}
+		if got, want := qe.Attempts, 2; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("fails over to reachable server", func(t *testing.T) {
+		h := func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte("host1\n"))
+		}
+		withTestServer(t, h, func(server *httptest.Server) {
+			client, err := NewClient(&Config{
+				HTTPClient: server.Client(),
+				Servers:    []string{closedAddress(t), strings.TrimPrefix(server.URL, "http://")},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < 2; i++ {
+				values, err := client.Query("foo")
+				if err != nil {
+					t.Fatal(err)
+				}
+				ensureStringSlicesMatch(t, values, []string{"host1"})
+			}
+		})
+	})
+
+	t.Run("pinned server unreachable", func(t *testing.T) {
+		client, err := NewClient(&Config{
+			Servers: []string{closedAddress(t), closedAddress(t)},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = client.QueryWithOptions(context.Background(), "foo", QueryOptions{Server: closedAddress(t)})
+		if got, want := errors.Is(err, ErrNoServersAvailable), true; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("server reachable", func(t *testing.T) {
+		h := func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		}
+		withClient(t, h, func(client *Client) {
+			_, err := client.Query("foo")
+			if got, want := errors.Is(err, ErrNoServersAvailable), false; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+		})
+	})
+}
+
+func TestClassificationHelpers(t *testing.T) {
+	rangeException := &QueryError{Err: &ErrRangeException{Message: "some error"}}
+	statusNotOK := &QueryError{Err: &ErrStatusNotOK{StatusCode: http.StatusBadGateway}}
+	tooMany := &ErrTooManyRequests{ErrStatusNotOK: ErrStatusNotOK{StatusCode: http.StatusTooManyRequests}}
+	dnsError := &QueryError{Err: &net.DNSError{Err: "no such host", Name: "range.example.com"}}
+	timeoutError := &QueryError{Err: &net.DNSError{Err: "i/o timeout", IsTimeout: true}}
+
+	cases := []struct {
+		name             string
+		err              error
+		retryable        bool
+		isRangeException bool
+		isStatusNotOK    bool
+	}{
+		{"nil", nil, false, false, false},
+		{"other", errors.New("other"), false, false, false},
+		{"range exception", rangeException, false, true, false},
+		{"status not ok", statusNotOK, false, false, true},
+		{"too many requests", tooMany, false, false, true},
+		{"dns", dnsError, true, false, false},
+		{"timeout", timeoutError, true, false, false},
+		{"canceled", context.Canceled, false, false, false},
+		{"deadline exceeded", &QueryError{Err: context.DeadlineExceeded}, false, false, false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got, want := IsRetryable(c.err), c.retryable; got != want {
+				t.Errorf("IsRetryable GOT: %v; WANT: %v", got, want)
+			}
+			if got, want := IsRangeException(c.err), c.isRangeException; got != want {
+				t.Errorf("IsRangeException GOT: %v; WANT: %v", got, want)
+			}
+			if got, want := IsStatusNotOK(c.err), c.isStatusNotOK; got != want {
+				t.Errorf("IsStatusNotOK GOT: %v; WANT: %v", got, want)
+			}
+		})
+	}
+}
+
+func TestIncludeExpressionInErrors(t *testing.T) {
+	h := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("RangeException", "some error")
+	}
+
+	newClient := func(t *testing.T, server *httptest.Server, include bool) *Client {
+		client, err := NewClient(&Config{
+			HTTPClient:                server.Client(),
+			IncludeExpressionInErrors: include,
+			Servers:                   []string{strings.TrimLeft(server.URL, "http://")},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return client
+	}
+
+	withTestServer(t, h, func(server *httptest.Server) {
+		t.Run("excluded by default", func(t *testing.T) {
+			_, err := newClient(t, server, false).Query("%secret")
+			if got, avoid := err.Error(), "secret"; strings.Contains(got, avoid) {
+				t.Errorf("GOT: %v; AVOID: %v", got, avoid)
+			}
+			var qe *QueryError
+			if !errors.As(err, &qe) {
+				t.Fatalf("GOT: %T; WANT: %T", err, qe)
+			}
+			if got, want := qe.Expression, "%secret"; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+		})
+
+		t.Run("included", func(t *testing.T) {
+			_, err := newClient(t, server, true).Query("%foo\n,%bar")
+			ensureError(t, err, `expression "%foo\n,%bar"`, "some error")
+		})
+
+		t.Run("truncated", func(t *testing.T) {
+			expression := strings.Repeat("a", maxErrorExpressionLength) + "tail"
+			_, err := newClient(t, server, true).Query(expression)
+			ensureError(t, err, `expression "`+strings.Repeat("a", maxErrorExpressionLength)+`"...`)
+			if got, avoid := err.Error(), "tail"; strings.Contains(got, avoid) {
+				t.Errorf("GOT: %v; AVOID: %v", got, avoid)
+			}
+		})
+	})
+}
+
+func TestErrStatusNotOKHeader(t *testing.T) {
+	h := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="range"`)
+		w.Header().Set("Via", "1.1 proxy.example.com")
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+	}
+	withClient(t, h, func(client *Client) {
+		_, err := client.Query("foo")
+		var e *ErrStatusNotOK
+		if !errors.As(err, &e) {
+			t.Fatalf("GOT: %T; WANT: %T", err, e)
+		}
+		if got, want := e.Header.Get("WWW-Authenticate"), `Bearer realm="range"`; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := e.Header.Get("Via"), "1.1 proxy.example.com"; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+}
+
+func TestOnErrorReport(t *testing.T) {
+	h := func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}
+	withTestServer(t, h, func(server *httptest.Server) {
+		address := strings.TrimLeft(server.URL, "http://")
+
+		var reports []QueryError
+
+		client, err := NewClient(&Config{
+			HTTPClient:    server.Client(),
+			OnErrorReport: func(report QueryError) { reports = append(reports, report) },
+			RetryCallback: func(error) bool { return true },
+			RetryCount:    2,
+			Servers:       []string{address},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = client.Query("foo")
+		ensureError(t, err, http.StatusText(http.StatusServiceUnavailable))
+
+		if got, want := len(reports), 1; got != want {
+			t.Fatalf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := reports[0].Expression, "foo"; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := reports[0].Server, address; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := reports[0].Attempts, 3; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := IsStatusNotOK(reports[0].Err), true; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+
+		if _, err = client.Query("foo"); err == nil {
+			t.Fatal("GOT: nil; WANT: error")
+		}
+		if got, want := len(reports), 2; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+}
+
+func BenchmarkErrorBody(b *testing.B) {
+	page := bytes.Repeat([]byte("<p>proxy error</p>\n"), 1<<16) // over 1 MiB
+	for _, status := range []int{http.StatusInternalServerError, http.StatusRequestURITooLong} {
+		b.Run(http.StatusText(status), func(b *testing.B) {
+			h := func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == http.MethodPut {
+					w.Write([]byte("host1\n"))
+					return
+				}
+				w.WriteHeader(status)
+				w.Write(page)
+			}
+			withClient(b, h, func(client *Client) {
+				client.retryCount = 0
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					_, _ = client.QueryCtx(context.Background(), "foo")
+				}
+			})
+		})
+	}
+}
+
+// countingBody counts the bytes read from it, and whether it was closed.
+type countingBody struct {
+	io.Reader
+	read   int64
+	closed bool
+}
+
+func (c *countingBody) Read(p []byte) (int, error) {
+	n, err := c.Reader.Read(p)
+	c.read += int64(n)
+	return n, err
+}
+
+func (c *countingBody) Close() error { c.closed = true; return nil }
+
+func TestDiscardLimit(t *testing.T) {
+	body := &countingBody{Reader: bytes.NewReader(make([]byte, 10*maxDiscardBytes))}
+	if err := discard(body); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := body.read, int64(maxDiscardBytes); got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+	if got, want := body.closed, true; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+}
@@ -0,0 +1,97 @@
+package orange
+
+import (
+	"io"
+	"net/url"
+)
+
+// escapedExpression holds an expression along with what is needed to send it
+// in a query, computed once for all attempts.  The escaped form is only built
+// when the expression is sent using GET, so a multi-megabyte expression, which
+// is sent using PUT, is never held in memory a second time.
+type escapedExpression struct {
+	expression string
+	length     int    // length of the query escaped expression
+	escaped    string // query escaped expression, built on first use
+}
+
+func newEscapedExpression(expression string) *escapedExpression {
+	length := len(expression)
+	for i := 0; i < len(expression); i++ {
+		if !isUnescaped(expression[i]) && expression[i] != ' ' {
+			length += 2 // escaped as %XX
+		}
+	}
+	return &escapedExpression{expression: expression, length: length}
+}
+
+// String returns the query escaped expression.
+func (e *escapedExpression) String() string {
+	if e.escaped == "" && e.expression != "" {
+		e.escaped = url.QueryEscape(e.expression)
+	}
+	return e.escaped
+}
+
+// formLength returns the length of the body of a PUT query of the expression.
+func (e *escapedExpression) formLength() int64 {
+	return int64(len(queryFormPrefix) + e.length)
+}
+
+// formReader returns a reader of the body of a PUT query of the expression,
+// which escapes the expression as it is read.
+func (e *escapedExpression) formReader() io.ReadCloser {
+	return &formReader{prefix: queryFormPrefix, expression: e.expression}
+}
+
+// isUnescaped returns true for the bytes url.QueryEscape does not escape.
+// Spaces are escaped as a plus sign, and other bytes as %XX.
+func isUnescaped(b byte) bool {
+	return 'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z' || '0' <= b && b <= '9' ||
+		b == '-' || b == '_' || b == '.' || b == '~'
+}
+
+// formReader reads the body of a PUT query, query escaping the expression as
+// it is read, byte for byte the same as url.QueryEscape.
+type formReader struct {
+	prefix     string // remainder of the form prefix
+	expression string // remainder of the unescaped expression
+	pending    []byte // remainder of a partially read escape sequence
+	scratch    [3]byte
+}
+
+func (r *formReader) Read(p []byte) (int, error) {
+	var n int
+	for n < len(p) {
+		switch {
+		case len(r.pending) > 0:
+			c := copy(p[n:], r.pending)
+			r.pending = r.pending[c:]
+			n += c
+		case len(r.prefix) > 0:
+			c := copy(p[n:], r.prefix)
+			r.prefix = r.prefix[c:]
+			n += c
+		case len(r.expression) > 0:
+			b := r.expression[0]
+			r.expression = r.expression[1:]
+			switch {
+			case isUnescaped(b):
+				p[n] = b
+				n++
+			case b == ' ':
+				p[n] = '+'
+				n++
+			default:
+				const hex = "0123456789ABCDEF"
+				r.scratch = [3]byte{'%', hex[b>>4], hex[b&15]}
+				r.pending = r.scratch[:]
+			}
+		default:
+			return n, io.EOF
+		}
+	}
+	return n, nil
+}
+
+func (r *formReader) Close() error { return nil }
@@ -0,0 +1,66 @@
+package orange
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestEscapedExpression(t *testing.T) {
+	expressions := []string{
+		"",
+		"%someCluster",
+		"%cluster1,%cluster2 & host~web",
+		"a b+c/d?e=f&g#h",
+		"héllo\x00\xff",
+		strings.Repeat("%c1 - %c2,", 1000),
+	}
+
+	for _, expression := range expressions {
+		want := url.QueryEscape(expression)
+		escaped := newEscapedExpression(expression)
+
+		if got := escaped.length; got != len(want) {
+			t.Errorf("%q: GOT: %v; WANT: %v", expression, got, len(want))
+		}
+		if got := escaped.String(); got != want {
+			t.Errorf("%q: GOT: %v; WANT: %v", expression, got, want)
+		}
+		if got, want := escaped.formLength(), int64(len(queryFormPrefix+want)); got != want {
+			t.Errorf("%q: GOT: %v; WANT: %v", expression, got, want)
+		}
+
+		// Read one byte at a time to split escape sequences across reads.
+		buf, err := io.ReadAll(iotest.OneByteReader(escaped.formReader()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := string(buf), queryFormPrefix+want; got != want {
+			t.Errorf("%q: GOT: %v; WANT: %v", expression, got, want)
+		}
+	}
+}
+
+func TestQueryPutStreamsBody(t *testing.T) {
+	expression := strings.Repeat("%someCluster - ", defaultQueryURILengthThreshold)
+
+	withClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if got, want := r.Method, http.MethodPut; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := r.ContentLength, int64(len("query="+url.QueryEscape(expression))); got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := r.FormValue("query"), expression; got != want {
+			t.Errorf("GOT: %v; WANT: %v", len(got), len(want))
+		}
+		_, _ = w.Write([]byte("host1\n"))
+	}, func(client *Client) {
+		values, err := client.Query(expression)
+		ensureError(t, err)
+		ensureStringSlicesMatch(t, values, []string{"host1"})
+	})
+}
@@ -5,6 +5,7 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/karrick/orange"
@@ -32,7 +33,7 @@ func main() {
 	}
 
 	if flag.NArg() == 0 {
-		fmt.Fprintf(os.Stderr, "USAGE: %s [-timeout DURATION] q1 q2\n")
+		fmt.Fprintf(os.Stderr, "USAGE: %s [-timeout DURATION] q1 q2\n", filepath.Base(os.Args[0]))
 		os.Exit(1)
 	}
 
@@ -0,0 +1,261 @@
+package orange
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrNotLocal is wrapped in the error returned by ExpandLocal when an expression
+// uses range operators that only a range server can evaluate, such as cluster
+// lookups, so tools can fall back to querying a server.
+//
+//     values, err := orange.ExpandLocal(expression)
+//     if errors.Is(err, orange.ErrNotLocal) {
+//         values, err = client.Query(expression)
+//     }
+var ErrNotLocal = errors.New("expression requires a range server")
+
+// localOperators are the characters of range expressions that ExpandLocal
+// cannot evaluate without a range server.
+const localOperators = "%@&^()/:;$*?!"
+
+// maxLocalExpansion limits the number of values ExpandLocal will produce, so a
+// mistyped range cannot exhaust memory.
+const maxLocalExpansion = 1 << 20
+
+// ExpandLocal expands simple expressions without a round trip to a range
+// server.  It supports comma separated unions, numeric ranges written in range
+// notation as produced by Compress, and brace expansion of alternatives and
+// numeric ranges.  Zero padded numbers keep their width.  Duplicate values are
+// removed, preserving the order in which values first appear.
+//
+//     values, err := orange.ExpandLocal("web{1..3}.example.com,db01..02.example.com")
+//     // values: web1.example.com, web2.example.com, web3.example.com,
+//     //         db01.example.com, db02.example.com
+//
+// Expressions using operators that require a range server, such as %cluster
+// lookups, set intersection, or exclusion, return an error wrapping
+// ErrNotLocal.
+func ExpandLocal(expression string) ([]string, error) {
+	if i := strings.IndexAny(expression, localOperators); i >= 0 {
+		return nil, fmt.Errorf("cannot expand %q locally: %w: operator %q", expression, ErrNotLocal, expression[i])
+	}
+
+	terms, err := splitTopLevel(expression)
+	if err != nil {
+		return nil, fmt.Errorf("cannot expand %q locally: %w", expression, err)
+	}
+
+	var values []string
+	for _, term := range terms {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			return nil, fmt.Errorf("cannot expand %q locally: empty term", expression)
+		}
+		if term[0] == '-' {
+			return nil, fmt.Errorf("cannot expand %q locally: %w: exclusion %q", expression, ErrNotLocal, term)
+		}
+		expanded, err := expandBraces(term)
+		if err != nil {
+			return nil, fmt.Errorf("cannot expand %q locally: %w", expression, err)
+		}
+		for _, v := range expanded {
+			ranged, err := expandRange(v)
+			if err != nil {
+				return nil, fmt.Errorf("cannot expand %q locally: %w", expression, err)
+			}
+			values = append(values, ranged...)
+			if len(values) > maxLocalExpansion {
+				return nil, fmt.Errorf("cannot expand %q locally: more than %d values", expression, maxLocalExpansion)
+			}
+		}
+	}
+	return Unique(values), nil
+}
+
+// splitTopLevel splits s on the commas that are not enclosed in braces.
+func splitTopLevel(s string) ([]string, error) {
+	var terms []string
+	var depth, start int
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '{':
+			depth++
+		case '}':
+			if depth--; depth < 0 {
+				return nil, fmt.Errorf("unbalanced braces")
+			}
+		case ',':
+			if depth == 0 {
+				terms = append(terms, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	if depth != 0 {
+		return nil, fmt.Errorf("unbalanced braces")
+	}
+	return append(terms, s[start:]), nil
+}
+
+// expandBraces returns the expansion of the first brace group in s combined
+// with the expansion of the remainder of s.
+func expandBraces(s string) ([]string, error) {
+	open := strings.IndexByte(s, '{')
+	if open < 0 {
+		return []string{s}, nil
+	}
+
+	depth := 0
+	end := -1
+	for i := open; i < len(s) && end < 0; i++ {
+		switch s[i] {
+		case '{':
+			depth++
+		case '}':
+			if depth--; depth == 0 {
+				end = i
+			}
+		}
+	}
+	if end < 0 {
+		return nil, fmt.Errorf("unbalanced braces")
+	}
+
+	prefix, body, suffix := s[:open], s[open+1:end], s[end+1:]
+
+	var alternatives []string
+	if first, last, ok := strings.Cut(body, ".."); ok && isNumber(first) && isNumber(last) {
+		numbers, err := expandNumbers(first, last)
+		if err != nil {
+			return nil, err
+		}
+		alternatives = numbers
+	} else {
+		terms, err := splitTopLevel(body)
+		if err != nil {
+			return nil, err
+		}
+		for _, term := range terms {
+			expanded, err := expandBraces(term)
+			if err != nil {
+				return nil, err
+			}
+			alternatives = append(alternatives, expanded...)
+		}
+	}
+
+	suffixes, err := expandBraces(suffix)
+	if err != nil {
+		return nil, err
+	}
+	if len(alternatives)*len(suffixes) > maxLocalExpansion {
+		return nil, fmt.Errorf("more than %d values", maxLocalExpansion)
+	}
+
+	values := make([]string, 0, len(alternatives)*len(suffixes))
+	for _, alternative := range alternatives {
+		for _, suffix := range suffixes {
+			values = append(values, prefix+alternative+suffix)
+		}
+	}
+	return values, nil
+}
+
+// expandRange expands a numeric range written in range notation, such as
+// web1..3.example.com or web1..web3.example.com.  A term without a range is
+// returned unchanged.
+func expandRange(term string) ([]string, error) {
+	left, right, ok := strings.Cut(term, "..")
+	if !ok {
+		return []string{term}, nil
+	}
+
+	prefix, first := splitTrailingNumber(left)
+	if first == "" {
+		return nil, fmt.Errorf("range %q does not start with a number", term)
+	}
+
+	// The end of the range may repeat the prefix of its start.
+	if prefix != "" && strings.HasPrefix(right, prefix) && len(right) > len(prefix) && isDigit(right[len(prefix)]) {
+		right = right[len(prefix):]
+	}
+	last := leadingDigits(right)
+	if last == "" {
+		return nil, fmt.Errorf("range %q does not end with a number", term)
+	}
+	suffix := right[len(last):]
+	if strings.Contains(suffix, "..") {
+		return nil, fmt.Errorf("range %q has more than one range", term)
+	}
+
+	numbers, err := expandNumbers(first, last)
+	if err != nil {
+		return nil, err
+	}
+	for i, n := range numbers {
+		numbers[i] = prefix + n + suffix
+	}
+	return numbers, nil
+}
+
+// expandNumbers returns the decimal numbers from first to last, inclusive,
+// counting down when last is less than first.  When either is zero padded, all
+// numbers are padded to the wider of the two.
+func expandNumbers(first, last string) ([]string, error) {
+	a, err := strconv.Atoi(first)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse range start: %w", err)
+	}
+	b, err := strconv.Atoi(last)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse range end: %w", err)
+	}
+
+	var width int
+	if (len(first) > 1 && first[0] == '0') || (len(last) > 1 && last[0] == '0') {
+		width = len(first)
+		if len(last) > width {
+			width = len(last)
+		}
+	}
+
+	step, count := 1, b-a+1
+	if b < a {
+		step, count = -1, a-b+1
+	}
+	if count > maxLocalExpansion {
+		return nil, fmt.Errorf("range %s..%s has more than %d values", first, last, maxLocalExpansion)
+	}
+
+	numbers := make([]string, 0, count)
+	for n := a; len(numbers) < count; n += step {
+		numbers = append(numbers, fmt.Sprintf("%0*d", width, n))
+	}
+	return numbers, nil
+}
+
+// splitTrailingNumber splits s into the text before its trailing run of digits
+// and the digits themselves.
+func splitTrailingNumber(s string) (string, string) {
+	i := len(s)
+	for i > 0 && isDigit(s[i-1]) {
+		i--
+	}
+	return s[:i], s[i:]
+}
+
+// leadingDigits returns the leading run of digits of s.
+func leadingDigits(s string) string {
+	i := 0
+	for i < len(s) && isDigit(s[i]) {
+		i++
+	}
+	return s[:i]
+}
+
+func isNumber(s string) bool {
+	return s != "" && len(leadingDigits(s)) == len(s)
+}
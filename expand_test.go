@@ -0,0 +1,71 @@
+package orange
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestExpandLocal(t *testing.T) {
+	cases := []struct {
+		expression string
+		want       []string
+	}{
+		{"web1.example.com", []string{"web1.example.com"}},
+		{"web1,web2,web1", []string{"web1", "web2"}},
+		{"web{1..3}.example.com", []string{"web1.example.com", "web2.example.com", "web3.example.com"}},
+		{"web{3..1}", []string{"web3", "web2", "web1"}},
+		{"db{08..10}", []string{"db08", "db09", "db10"}},
+		{"{web,db}{1,2}", []string{"web1", "web2", "db1", "db2"}},
+		{"{web{1..2},db}.example.com", []string{"web1.example.com", "web2.example.com", "db.example.com"}},
+		{"web1..3.example.com", []string{"web1.example.com", "web2.example.com", "web3.example.com"}},
+		{"web1..web3", []string{"web1", "web2", "web3"}},
+		{"db01..03", []string{"db01", "db02", "db03"}},
+		{"web1..2, db1", []string{"web1", "web2", "db1"}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.expression, func(t *testing.T) {
+			values, err := ExpandLocal(c.expression)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ensureStringSlicesMatch(t, values, c.want)
+		})
+	}
+}
+
+func TestExpandLocalCompress(t *testing.T) {
+	hosts := []string{"web1.example.com", "web2.example.com", "web3.example.com", "web7.example.com", "db01.example.com", "db02.example.com"}
+	values, err := ExpandLocal(Compress(hosts))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ensureStringSlicesMatch(t, values, hosts)
+}
+
+func TestExpandLocalErrors(t *testing.T) {
+	t.Run("requires server", func(t *testing.T) {
+		for _, expression := range []string{"%cluster", "web1,-web2", "web1..3&web2", "@group"} {
+			_, err := ExpandLocal(expression)
+			if got, want := errors.Is(err, ErrNotLocal), true; got != want {
+				t.Errorf("%q: GOT: %v; WANT: %v", expression, got, want)
+			}
+		}
+	})
+
+	t.Run("malformed", func(t *testing.T) {
+		cases := map[string]string{
+			"web{1,2":           "unbalanced braces",
+			"web}1":             "unbalanced braces",
+			"web1,,web2":        "empty term",
+			"web..3":            "does not start with a number",
+			"web1..x":           "does not end with a number",
+			"web1..2.a..b":      "more than one range",
+			"web{1..999999999}": "more than",
+		}
+		for expression, want := range cases {
+			_, err := ExpandLocal(expression)
+			ensureError(t, err, want)
+		}
+	})
+}
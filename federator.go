@@ -0,0 +1,172 @@
+package orange
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// FederationPolicy controls how a Federator combines the results of its
+// Clients.
+type FederationPolicy int
+
+const (
+	// FederateMerge returns the union of the results of every Client, without
+	// duplicates, taking the Clients in order of their names.
+	FederateMerge FederationPolicy = iota
+
+	// FederateNamespace returns the results of every Client prefixed by its
+	// name and the Federator's Separator, such as "us-east:web1.example.com",
+	// taking the Clients in order of their names.
+	FederateNamespace
+)
+
+// DefaultFederationSeparator separates the name of a Client from each of its
+// results when a Federator uses FederateNamespace.
+const DefaultFederationSeparator = ":"
+
+// Federator sends each query to several Clients, such as one for the range
+// servers of each region, and combines their results according to its Policy.
+//
+//     federator := &orange.Federator{
+//         Clients: map[string]*orange.Client{
+//             "us-east": east,
+//             "eu-west": west,
+//         },
+//         Policy: orange.FederateNamespace,
+//     }
+//     values, err := federator.QueryCtx(ctx, "%web")
+//
+// Its fields must not be modified while it is being used.
+type Federator struct {
+	// Clients maps the name of each source to the Client that queries it.
+	Clients map[string]*Client
+
+	// Policy controls how the results of the Clients are combined.
+	Policy FederationPolicy
+
+	// Separator is used by FederateNamespace.  When empty,
+	// DefaultFederationSeparator is used.
+	Separator string
+}
+
+// FederationError is returned by a Federator when one or more of its Clients
+// failed, mapping each of their names to its error.  Because it implements
+// Unwrap() []error, errors.Is and errors.As examine the error of every failed
+// Client.
+type FederationError struct {
+	// Errors maps the name of each failed Client to the error its query
+	// returned.
+	Errors map[string]error
+}
+
+func (err *FederationError) Error() string {
+	names := err.names()
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d federated queries failed", len(names))
+	for i, name := range names {
+		if i == 0 {
+			b.WriteString(": ")
+		} else {
+			b.WriteString("; ")
+		}
+		fmt.Fprintf(&b, "%s: %s", name, err.Errors[name])
+	}
+	return b.String()
+}
+
+// Unwrap returns the errors of the failed Clients, ordered by name.
+func (err *FederationError) Unwrap() []error {
+	names := err.names()
+	errs := make([]error, len(names))
+	for i, name := range names {
+		errs[i] = err.Errors[name]
+	}
+	return errs
+}
+
+func (err *FederationError) names() []string {
+	names := make([]string, 0, len(err.Errors))
+	for name := range err.Errors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Query sends the query expression to every Client, and returns their combined
+// results.
+func (f *Federator) Query(expression string) ([]string, error) {
+	return f.QueryCtx(context.Background(), expression)
+}
+
+// QueryCtx sends the query expression to every Client concurrently with the
+// provided query context, and returns their combined results.  When one or
+// more Clients fail, the combined results of the others are still returned,
+// along with a *FederationError.
+func (f *Federator) QueryCtx(ctx context.Context, expression string) ([]string, error) {
+	results, err := f.QueryAll(ctx, expression)
+
+	names := make([]string, 0, len(results))
+	for name := range results {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var combined []string
+	switch f.Policy {
+	case FederateNamespace:
+		separator := f.Separator
+		if separator == "" {
+			separator = DefaultFederationSeparator
+		}
+		for _, name := range names {
+			for _, value := range results[name] {
+				combined = append(combined, name+separator+value)
+			}
+		}
+	default:
+		for _, name := range names {
+			combined = append(combined, results[name]...)
+		}
+		combined = Unique(combined)
+	}
+	return combined, err
+}
+
+// QueryAll sends the query expression to every Client concurrently with the
+// provided query context, and returns the results of each Client that
+// succeeded, by name.  When one or more Clients fail, it also returns a
+// *FederationError.
+func (f *Federator) QueryAll(ctx context.Context, expression string) (map[string][]string, error) {
+	results := make(map[string][]string, len(f.Clients))
+	var fe *FederationError
+
+	var lock sync.Mutex
+	var wg sync.WaitGroup
+	wg.Add(len(f.Clients))
+	for name, client := range f.Clients {
+		go func(name string, client *Client) {
+			defer wg.Done()
+			values, err := client.QueryCtx(ctx, expression)
+			lock.Lock()
+			defer lock.Unlock()
+			if err != nil {
+				if fe == nil {
+					fe = &FederationError{Errors: make(map[string]error)}
+				}
+				fe.Errors[name] = err
+				return
+			}
+			results[name] = values
+		}(name, client)
+	}
+	wg.Wait()
+
+	if fe != nil {
+		return results, fe
+	}
+	return results, nil
+}
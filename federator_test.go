@@ -0,0 +1,49 @@
+package orange
+
+import (
+	"errors"
+	"testing"
+)
+
+func newTestFederator(tb testing.TB, policy FederationPolicy) *Federator {
+	tb.Helper()
+	clients := make(map[string]*Client)
+	for name, mock := range map[string]*MockConfig{
+		"east": {Queries: map[string]MockResult{"%web": {Results: []string{"web1", "web2"}}}},
+		"west": {Queries: map[string]MockResult{"%web": {Results: []string{"web2", "web3"}}}},
+		"down": {Queries: map[string]MockResult{"%web": {Err: ErrMockFault}}},
+	} {
+		client, err := NewMockClient(mock)
+		if err != nil {
+			tb.Fatal(err)
+		}
+		clients[name] = client
+	}
+	return &Federator{Clients: clients, Policy: policy}
+}
+
+func TestFederatorMerge(t *testing.T) {
+	values, err := newTestFederator(t, FederateMerge).Query("%web")
+	var fe *FederationError
+	if !errors.As(err, &fe) {
+		t.Fatalf("GOT: %v; WANT: %T", err, fe)
+	}
+	if _, ok := fe.Errors["down"]; !ok {
+		t.Errorf("GOT: %v; WANT: %v", fe.Errors, "down")
+	}
+	if got, want := err, ErrMockFault; !errors.Is(got, want) {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+	ensureStringSlicesMatch(t, values, []string{"web1", "web2", "web3"})
+}
+
+func TestFederatorNamespace(t *testing.T) {
+	federator := newTestFederator(t, FederateNamespace)
+	delete(federator.Clients, "down")
+
+	values, err := federator.Query("%web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ensureStringSlicesMatch(t, values, []string{"east:web1", "east:web2", "west:web2", "west:web3"})
+}
@@ -0,0 +1,42 @@
+package orange
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHeaders(t *testing.T) {
+	withTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if got, want := r.Header.Get("X-Team"), "billing"; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := r.Header.Get("X-Env"), "prod"; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := r.Header.Get("User-Agent"), "custom-user-agent"; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		_, _ = w.Write([]byte("host1\n"))
+	}, func(server *httptest.Server) {
+		headers := http.Header{
+			"x-team":     []string{"billing"},
+			"X-Env":      []string{"prod"},
+			"User-Agent": []string{"ignored"},
+		}
+		client, err := NewClient(&Config{
+			Headers:    headers,
+			HTTPClient: server.Client(),
+			Servers:    []string{strings.TrimPrefix(server.URL, "http://")},
+			UserAgent:  "custom-user-agent",
+		})
+		ensureError(t, err)
+
+		headers.Set("X-Env", "modified") // the client keeps its own copy
+
+		values, err := client.Query("%someCluster")
+		ensureError(t, err)
+		ensureStringSlicesMatch(t, values, []string{"host1"})
+	})
+}
@@ -0,0 +1,42 @@
+package orange
+
+import "context"
+
+// QueryFunc is the signature of QueryCtx, through which Interceptors pass
+// queries.
+type QueryFunc func(ctx context.Context, expression string) ([]string, error)
+
+// Interceptor wraps the execution of a query, so caching, metrics,
+// authentication, and logging can be layered by users in a controlled order.
+// An Interceptor returns a QueryFunc that may inspect or modify the context and
+// expression before invoking next, and the results and error after, or answer
+// the query without invoking next at all.
+//
+//     logging := func(next orange.QueryFunc) orange.QueryFunc {
+//         return func(ctx context.Context, expression string) ([]string, error) {
+//             start := time.Now()
+//             values, err := next(ctx, expression)
+//             log.Printf("%q: %d values in %s: %v", expression, len(values), time.Since(start), err)
+//             return values, err
+//         }
+//     }
+//     client, err := orange.NewClient(&orange.Config{
+//         Interceptors: []orange.Interceptor{logging, metrics},
+//         Servers:      servers,
+//     })
+//
+// Interceptors wrap the queries made by Query and QueryCtx, and therefore also
+// those made by the methods built on them, such as Queries and Watch, but not
+// QueryCallback, QueryForEach, or QueryResponse, whose results are not a slice.
+// They run outside the client's cache and coalescing, so they observe every
+// call, including those answered from the cache.
+type Interceptor func(next QueryFunc) QueryFunc
+
+// chainInterceptors returns query wrapped by interceptors, the first of them
+// outermost.
+func chainInterceptors(interceptors []Interceptor, query QueryFunc) QueryFunc {
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		query = interceptors[i](query)
+	}
+	return query
+}
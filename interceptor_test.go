@@ -0,0 +1,69 @@
+package orange
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestInterceptors(t *testing.T) {
+	var calls []string
+	record := func(name string) Interceptor {
+		return func(next QueryFunc) QueryFunc {
+			return func(ctx context.Context, expression string) ([]string, error) {
+				calls = append(calls, name+" "+expression)
+				return next(ctx, expression)
+			}
+		}
+	}
+	rewrite := func(next QueryFunc) QueryFunc {
+		return func(ctx context.Context, expression string) ([]string, error) {
+			return next(ctx, strings.ToLower(expression))
+		}
+	}
+
+	mock := &MockConfig{Queries: map[string]MockResult{"%web": {Results: []string{"web1"}}}}
+	client, err := NewClient(&Config{
+		HTTPClient:   mock,
+		Interceptors: []Interceptor{record("outer"), rewrite, record("inner")},
+		Servers:      []string{"mock"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	values, err := client.Query("%WEB")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ensureStringSlicesMatch(t, values, []string{"web1"})
+	ensureStringSlicesMatch(t, calls, []string{"outer %WEB", "inner %web"})
+	mock.AssertQueried(t, "%web")
+}
+
+func TestInterceptorShortCircuit(t *testing.T) {
+	deny := func(next QueryFunc) QueryFunc {
+		return func(ctx context.Context, expression string) ([]string, error) {
+			if strings.Contains(expression, "%secret") {
+				return nil, errors.New("denied")
+			}
+			return next(ctx, expression)
+		}
+	}
+
+	mock := &MockConfig{Results: []string{"host1"}}
+	client, err := NewClient(&Config{
+		HTTPClient:   mock,
+		Interceptors: []Interceptor{deny},
+		Servers:      []string{"mock"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = client.Queries([]string{"%public", "%secret"})
+	ensureError(t, err, "denied")
+	mock.AssertQueried(t, "%public")
+	mock.AssertNotQueried(t, "%secret")
+}
@@ -0,0 +1,233 @@
+// Package rangeexpr evaluates a useful subset of range expressions against
+// cluster definitions held in memory, for serving and evaluating range data
+// without a range server.
+//
+// The supported syntax is unions (a,b), intersections (a,&b or a&b),
+// differences (a,-b), grouping with parentheses, cluster lookups (%cluster),
+// key lookups (%cluster:KEY), key listings (%cluster:KEYS), and literal terms,
+// which are expanded by the Evaluator's Expand function.  Operators are
+// evaluated from left to right.
+package rangeexpr
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Clusters maps cluster names to their keys, and each key to its values.  The
+// CLUSTER key of a cluster holds expressions for its members.
+type Clusters map[string]map[string][]string
+
+// maxDepth limits how deeply cluster definitions may refer to other clusters,
+// so a cluster that includes itself is reported rather than recursing forever.
+const maxDepth = 32
+
+// Evaluator evaluates range expressions against Clusters.
+type Evaluator struct {
+	// Clusters holds the cluster definitions.
+	Clusters Clusters
+
+	// Expand, when not nil, expands a literal term, such as web{1..3}, into
+	// its values.  When nil, literal terms evaluate to themselves.
+	Expand func(string) ([]string, error)
+}
+
+// Evaluate returns the values of expression, without duplicates, in the order
+// in which they first appear.
+func (e *Evaluator) Evaluate(expression string) ([]string, error) {
+	return e.evaluate(expression, 0)
+}
+
+func (e *Evaluator) evaluate(expression string, depth int) ([]string, error) {
+	if depth > maxDepth {
+		return nil, fmt.Errorf("cluster definitions nested more than %d deep", maxDepth)
+	}
+	p := &parser{evaluator: e, s: expression, depth: depth}
+	values, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+	if p.skipSpace(); p.i < len(p.s) {
+		return nil, fmt.Errorf("unexpected %q at position %d", p.s[p.i], p.i)
+	}
+	return values, nil
+}
+
+// parser is a recursive descent parser that evaluates an expression as it is
+// parsed.
+type parser struct {
+	evaluator *Evaluator
+	s         string
+	i         int
+	depth     int
+}
+
+func (p *parser) skipSpace() {
+	for p.i < len(p.s) && (p.s[p.i] == ' ' || p.s[p.i] == '\t') {
+		p.i++
+	}
+}
+
+func (p *parser) parseExpression() ([]string, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		p.skipSpace()
+		if p.i == len(p.s) || p.s[p.i] == ')' {
+			return left, nil
+		}
+
+		var operator byte
+		switch rest := p.s[p.i:]; {
+		case strings.HasPrefix(rest, ",&"):
+			operator, p.i = '&', p.i+2
+		case strings.HasPrefix(rest, ",-"):
+			operator, p.i = '-', p.i+2
+		case rest[0] == ',':
+			operator, p.i = ',', p.i+1
+		case rest[0] == '&':
+			operator, p.i = '&', p.i+1
+		default:
+			return nil, fmt.Errorf("unexpected %q at position %d", rest[0], p.i)
+		}
+
+		right, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		left = combine(operator, left, right)
+	}
+}
+
+func (p *parser) parseOperand() ([]string, error) {
+	p.skipSpace()
+	if p.i == len(p.s) {
+		return nil, fmt.Errorf("missing operand at position %d", p.i)
+	}
+
+	if p.s[p.i] == '(' {
+		p.i++
+		values, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+		if p.skipSpace(); p.i == len(p.s) || p.s[p.i] != ')' {
+			return nil, fmt.Errorf("missing closing parenthesis at position %d", p.i)
+		}
+		p.i++
+		return values, nil
+	}
+
+	// A term extends to the next operator, parenthesis, or space that is not
+	// enclosed in braces.
+	start := p.i
+	var braces int
+loop:
+	for ; p.i < len(p.s); p.i++ {
+		switch p.s[p.i] {
+		case '{':
+			braces++
+		case '}':
+			braces--
+		case ',', '&', '(', ')', ' ', '\t':
+			if braces == 0 {
+				break loop
+			}
+		}
+	}
+	if p.i == start {
+		return nil, fmt.Errorf("missing operand at position %d", p.i)
+	}
+	return p.evaluator.term(p.s[start:p.i], p.depth)
+}
+
+// term returns the values of a single term of an expression.
+func (e *Evaluator) term(term string, depth int) ([]string, error) {
+	if term[0] != '%' {
+		if e.Expand == nil {
+			return []string{term}, nil
+		}
+		return e.Expand(term)
+	}
+
+	name, key, ok := strings.Cut(term[1:], ":")
+	if !ok {
+		key = "CLUSTER"
+	}
+	cluster, ok := e.Clusters[name]
+	if !ok {
+		return nil, fmt.Errorf("no such cluster: %q", name)
+	}
+
+	if key == "KEYS" {
+		keys := make([]string, 0, len(cluster))
+		for k := range cluster {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		return keys, nil
+	}
+
+	values, ok := cluster[key]
+	if !ok {
+		return nil, fmt.Errorf("no such key in cluster %q: %q", name, key)
+	}
+	if key != "CLUSTER" {
+		// Only cluster members are expressions; other keys hold plain values,
+		// such as an owner's email address.
+		return append([]string(nil), values...), nil
+	}
+
+	var members []string
+	for _, value := range values {
+		expanded, err := e.evaluate(value, depth+1)
+		if err != nil {
+			return nil, fmt.Errorf("cluster %q: %w", name, err)
+		}
+		members = combine(',', members, expanded)
+	}
+	return members, nil
+}
+
+// combine returns the union, intersection, or difference of left and right,
+// for the operators ',', '&', and '-' respectively.
+func combine(operator byte, left, right []string) []string {
+	set := make(map[string]struct{}, len(right))
+	for _, v := range right {
+		set[v] = struct{}{}
+	}
+
+	seen := make(map[string]struct{}, len(left)+len(right))
+	var values []string
+	add := func(v string) {
+		if _, ok := seen[v]; !ok {
+			seen[v] = struct{}{}
+			values = append(values, v)
+		}
+	}
+
+	for _, v := range left {
+		_, inRight := set[v]
+		switch operator {
+		case ',':
+			add(v)
+		case '&':
+			if inRight {
+				add(v)
+			}
+		case '-':
+			if !inRight {
+				add(v)
+			}
+		}
+	}
+	if operator == ',' {
+		for _, v := range right {
+			add(v)
+		}
+	}
+	return values
+}
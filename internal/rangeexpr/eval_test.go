@@ -0,0 +1,90 @@
+package rangeexpr
+
+import (
+	"strings"
+	"testing"
+)
+
+func ensureValues(tb testing.TB, got, want []string) {
+	tb.Helper()
+	if strings.Join(got, ",") != strings.Join(want, ",") || len(got) != len(want) {
+		tb.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	e := &Evaluator{
+		Clusters: Clusters{
+			"web":  {"CLUSTER": {"web1", "web2", "web3"}, "OWNER": {"web-team@example.com"}},
+			"db":   {"CLUSTER": {"db1", "db2"}},
+			"all":  {"CLUSTER": {"%web", "%db"}},
+			"prod": {"CLUSTER": {"web1", "db1"}},
+		},
+	}
+
+	cases := []struct {
+		expression string
+		want       []string
+	}{
+		{"host1", []string{"host1"}},
+		{"%web", []string{"web1", "web2", "web3"}},
+		{"%web,%db", []string{"web1", "web2", "web3", "db1", "db2"}},
+		{"%web , %web", []string{"web1", "web2", "web3"}},
+		{"%all,&%prod", []string{"web1", "db1"}},
+		{"%all&%prod", []string{"web1", "db1"}},
+		{"%web,-%prod", []string{"web2", "web3"}},
+		{"%web,-web2,db9", []string{"web1", "web3", "db9"}},
+		{"%web,-(web2,web3)", []string{"web1"}},
+		{"(%web,%db)&%prod", []string{"web1", "db1"}},
+		{"%web:OWNER", []string{"web-team@example.com"}},
+		{"%web:KEYS", []string{"CLUSTER", "OWNER"}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.expression, func(t *testing.T) {
+			values, err := e.Evaluate(c.expression)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ensureValues(t, values, c.want)
+		})
+	}
+}
+
+func TestEvaluateExpand(t *testing.T) {
+	e := &Evaluator{
+		Expand: func(term string) ([]string, error) {
+			return []string{term + "a", term + "b"}, nil
+		},
+	}
+	values, err := e.Evaluate("x{1,2},y")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ensureValues(t, values, []string{"x{1,2}a", "x{1,2}b", "ya", "yb"})
+}
+
+func TestEvaluateErrors(t *testing.T) {
+	e := &Evaluator{
+		Clusters: Clusters{
+			"web":  {"CLUSTER": {"web1"}},
+			"loop": {"CLUSTER": {"%loop"}},
+		},
+	}
+
+	cases := map[string]string{
+		"%missing":  "no such cluster",
+		"%web:NOPE": "no such key",
+		"%loop":     "nested more than",
+		"%web,":     "missing operand",
+		"(%web":     "missing closing parenthesis",
+		"%web)":     "unexpected ')'",
+		"":          "missing operand",
+	}
+	for expression, want := range cases {
+		_, err := e.Evaluate(expression)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%q: GOT: %v; WANT: %v", expression, err, want)
+		}
+	}
+}
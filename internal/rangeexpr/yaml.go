@@ -0,0 +1,127 @@
+package rangeexpr
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// ParseCluster parses the classic range YAML definition of a single cluster: a
+// mapping of keys to either a single value or a list of values, written either
+// as a block of "- value" lines or as a flow sequence.
+//
+//     CLUSTER:
+//       - web1..3.example.com
+//       - db1.example.com
+//     OWNER: infra@example.com
+//     ENV: [production, canary]
+//
+// Only this subset of YAML is supported, which is all cluster definitions
+// need.
+func ParseCluster(data []byte) (map[string][]string, error) {
+	cluster := make(map[string][]string)
+	var key string // key of the block sequence being read, if any
+
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimRight(stripComment(line), " \t\r")
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || trimmed == "---" {
+			continue
+		}
+
+		if line[0] == ' ' || line[0] == '\t' || line[0] == '-' {
+			if !strings.HasPrefix(trimmed, "-") {
+				return nil, fmt.Errorf("line %d: expected sequence item: %q", i+1, trimmed)
+			}
+			if key == "" {
+				return nil, fmt.Errorf("line %d: sequence item without a key", i+1)
+			}
+			cluster[key] = append(cluster[key], unquote(strings.TrimSpace(trimmed[1:])))
+			continue
+		}
+
+		k, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected key: %q", i+1, line)
+		}
+		k = unquote(strings.TrimSpace(k))
+		if _, ok := cluster[k]; ok {
+			return nil, fmt.Errorf("line %d: duplicate key: %q", i+1, k)
+		}
+		value = strings.TrimSpace(value)
+
+		switch {
+		case value == "":
+			key = k
+			cluster[k] = nil
+		case strings.HasPrefix(value, "["):
+			if !strings.HasSuffix(value, "]") {
+				return nil, fmt.Errorf("line %d: unterminated flow sequence: %q", i+1, value)
+			}
+			key = ""
+			values := []string{}
+			for _, item := range strings.Split(value[1:len(value)-1], ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					values = append(values, unquote(item))
+				}
+			}
+			cluster[k] = values
+		default:
+			key = ""
+			cluster[k] = []string{unquote(value)}
+		}
+	}
+
+	return cluster, nil
+}
+
+// LoadClusters loads the cluster definitions in the files with a .yaml
+// extension in directory dir of fsys, one cluster per file, named for the file
+// without its extension.
+func LoadClusters(fsys fs.FS, dir string) (Clusters, error) {
+	names, err := fs.Glob(fsys, path.Join(dir, "*.yaml"))
+	if err != nil {
+		return nil, err
+	}
+	clusters := make(Clusters, len(names))
+	for _, name := range names {
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, err
+		}
+		cluster, err := ParseCluster(data)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse %s: %w", name, err)
+		}
+		clusters[strings.TrimSuffix(path.Base(name), ".yaml")] = cluster
+	}
+	return clusters, nil
+}
+
+// stripComment removes a comment from line.  A comment starts with a # at the
+// beginning of the line or following a space, outside of quotes.
+func stripComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// unquote removes the quotes surrounding a single or double quoted scalar.
+func unquote(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
@@ -0,0 +1,74 @@
+package rangeexpr
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestParseCluster(t *testing.T) {
+	cluster, err := ParseCluster([]byte(`---
+# web servers
+CLUSTER:
+  - web1..3.example.com   # front end
+  - "db1.example.com"
+OWNER: infra@example.com
+ENV: [production, 'canary']
+NOTE: "color #1"
+EMPTY: []
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := len(cluster), 5; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+	ensureValues(t, cluster["CLUSTER"], []string{"web1..3.example.com", "db1.example.com"})
+	ensureValues(t, cluster["OWNER"], []string{"infra@example.com"})
+	ensureValues(t, cluster["ENV"], []string{"production", "canary"})
+	ensureValues(t, cluster["NOTE"], []string{"color #1"})
+	ensureValues(t, cluster["EMPTY"], nil)
+}
+
+func TestParseClusterErrors(t *testing.T) {
+	cases := map[string]string{
+		"  - orphan\n":       "sequence item without a key",
+		"CLUSTER:\n  web1\n": "expected sequence item",
+		"CLUSTER\n":          "expected key",
+		"A: 1\nA: 2\n":       "duplicate key",
+		"A: [1, 2\n":         "unterminated flow sequence",
+	}
+	for data, want := range cases {
+		_, err := ParseCluster([]byte(data))
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%q: GOT: %v; WANT: %v", data, err, want)
+		}
+	}
+}
+
+func TestLoadClusters(t *testing.T) {
+	fsys := fstest.MapFS{
+		"clusters/web.yaml":  {Data: []byte("CLUSTER:\n  - web1\n  - web2\n")},
+		"clusters/db.yaml":   {Data: []byte("CLUSTER: db1\n")},
+		"clusters/README.md": {Data: []byte("not a cluster")},
+	}
+
+	clusters, err := LoadClusters(fsys, "clusters")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(clusters), 2; got != want {
+		t.Fatalf("GOT: %v; WANT: %v", got, want)
+	}
+	ensureValues(t, clusters["web"]["CLUSTER"], []string{"web1", "web2"})
+	ensureValues(t, clusters["db"]["CLUSTER"], []string{"db1"})
+
+	t.Run("invalid", func(t *testing.T) {
+		fsys["clusters/bad.yaml"] = &fstest.MapFile{Data: []byte("bad\n")}
+		_, err := LoadClusters(fsys, "clusters")
+		if err == nil || !strings.Contains(err.Error(), "bad.yaml") {
+			t.Errorf("GOT: %v; WANT: %v", err, "bad.yaml")
+		}
+	})
+}
@@ -0,0 +1,27 @@
+package orange
+
+// Servers returns the addresses of the range servers the client sends queries
+// to, in the order they were configured, so monitoring endpoints and debug
+// handlers can report which range servers a running process is using.
+func (c *Client) Servers() []string {
+	return copyStrings(c.servers.values)
+}
+
+// ConfigSnapshot returns a copy of the configuration the client was created
+// with, with the default used in place of each field left at its zero value,
+// such as MaxLineLength, RecentErrors, and HTTPClient.  Modifying the returned
+// Config does not affect the client.
+//
+//     http.HandleFunc("/debug/range", func(w http.ResponseWriter, r *http.Request) {
+//         config := client.ConfigSnapshot()
+//         fmt.Fprintf(w, "servers: %v\nretries: %d\ncache ttl: %s\n",
+//             config.Servers, config.RetryCount, config.CacheTTL)
+//     })
+func (c *Client) ConfigSnapshot() Config {
+	config := c.config
+	config.Headers = config.Headers.Clone()
+	config.Interceptors = append([]Interceptor(nil), config.Interceptors...)
+	config.ResponseHeaders = copyStrings(config.ResponseHeaders)
+	config.Servers = copyStrings(config.Servers)
+	return config
+}
@@ -0,0 +1,66 @@
+package orange
+
+import (
+	"testing"
+	"time"
+)
+
+func TestServers(t *testing.T) {
+	client, err := NewClient(&Config{Servers: []string{"range1:8081", "range2:8081"}})
+	ensureError(t, err)
+
+	servers := client.Servers()
+	ensureStringSlicesMatch(t, servers, []string{"range1:8081", "range2:8081"})
+
+	servers[0] = "modified"
+	ensureStringSlicesMatch(t, client.Servers(), []string{"range1:8081", "range2:8081"})
+}
+
+func TestConfigSnapshot(t *testing.T) {
+	config := &Config{
+		CacheTTL:   time.Minute,
+		RetryCount: 2,
+		Servers:    []string{"range1:8081"},
+	}
+	client, err := NewClient(config)
+	ensureError(t, err)
+
+	snapshot := client.ConfigSnapshot()
+
+	t.Run("configured values", func(t *testing.T) {
+		if got, want := snapshot.CacheTTL, time.Minute; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := snapshot.RetryCount, 2; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		ensureStringSlicesMatch(t, snapshot.Servers, []string{"range1:8081"})
+	})
+
+	t.Run("defaults", func(t *testing.T) {
+		if got, want := snapshot.MaxLineLength, DefaultMaxLineLength; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := snapshot.ScanBufferSize, DefaultScanBufferSize; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := snapshot.Scheme, "http"; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := snapshot.Path, DefaultPath; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if snapshot.HTTPClient == nil {
+			t.Errorf("GOT: %v; WANT: %v", nil, "default HTTP client")
+		}
+		if snapshot.RetryCallback == nil {
+			t.Errorf("GOT: %v; WANT: %v", nil, "default retry callback")
+		}
+	})
+
+	t.Run("copy", func(t *testing.T) {
+		snapshot.Servers[0] = "modified"
+		config.Servers[0] = "modified"
+		ensureStringSlicesMatch(t, client.ConfigSnapshot().Servers, []string{"range1:8081"})
+	})
+}
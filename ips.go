@@ -0,0 +1,32 @@
+package orange
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// ParseIPs parses each of the values as an IPv4 or IPv6 address, for queries
+// against IP bearing range keys.  Surrounding white space and blank values are
+// ignored.  It returns an error identifying the first value that is not an IP
+// address.
+func ParseIPs(values []string) ([]netip.Addr, error) {
+	addrs := make([]netip.Addr, 0, len(values))
+	for i, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		addr, err := netip.ParseAddr(value)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse result %d as IP address: %q", i+1, value)
+		}
+		addrs = append(addrs, addr)
+	}
+	return addrs, nil
+}
+
+// IPs parses the response lines as IP addresses.  See ParseIPs.
+func (r *Response) IPs() ([]netip.Addr, error) {
+	return ParseIPs(r.Split())
+}
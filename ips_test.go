@@ -0,0 +1,43 @@
+package orange
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func TestParseIPs(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		addrs, err := ParseIPs([]string{"10.0.0.1", " 2001:db8::1 ", ""})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []netip.Addr{netip.MustParseAddr("10.0.0.1"), netip.MustParseAddr("2001:db8::1")}
+		if got, want := len(addrs), len(want); got != want {
+			t.Fatalf("GOT: %v; WANT: %v", got, want)
+		}
+		for i := range want {
+			if got, want := addrs[i], want[i]; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := ParseIPs([]string{"10.0.0.1", "web1.example.com"})
+		ensureError(t, err, "result 2", `"web1.example.com"`)
+	})
+
+	t.Run("response", func(t *testing.T) {
+		response := &Response{Body: []byte("10.0.0.1\n10.0.0.2\n")}
+		addrs, err := response.IPs()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := len(addrs), 2; got != want {
+			t.Fatalf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := addrs[1], netip.MustParseAddr("10.0.0.2"); got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+}
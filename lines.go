@@ -0,0 +1,233 @@
+package orange
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// ErrLineTooLong is returned when a line of a query response is longer than the
+// configured Config.MaxLineLength.
+type ErrLineTooLong struct {
+	Limit int // Limit is the maximum line length, in bytes.
+}
+
+func (err *ErrLineTooLong) Error() string {
+	return fmt.Sprintf("response line exceeds maximum length of %d bytes", err.Limit)
+}
+
+// contentTypeDelimiters maps response media types to the delimiter used to
+// separate their results when Config.Delimiter is zero.
+var contentTypeDelimiters = map[string]byte{
+	"text/csv": ',',
+}
+
+// responseDelimiter returns the delimiter separating results in a response with
+// the specified headers, preferring the configured delimiter when not zero.
+func (c *Client) responseDelimiter(header http.Header) byte {
+	if c.delimiter != 0 {
+		return c.delimiter
+	}
+	if mediaType, _, err := mime.ParseMediaType(header.Get("Content-Type")); err == nil {
+		if delimiter, ok := contentTypeDelimiters[mediaType]; ok {
+			return delimiter
+		}
+	}
+	return '\n'
+}
+
+// estimatedLineLength is the assumed average length of a response line,
+// including its delimiter, used to estimate the number of results in a
+// response from its length.  Host names are typically somewhat longer, so the
+// estimate rarely falls short.
+const estimatedLineLength = 16
+
+// maxEstimatedLines caps the estimated number of results in a response, so a
+// bogus Content-Length cannot cause a huge allocation.
+const maxEstimatedLines = 1 << 20
+
+// estimateLines returns the number of results likely in a response of
+// contentLength bytes, or 0 when contentLength is not known.
+func estimateLines(contentLength int64) int {
+	if contentLength <= 0 {
+		return 0
+	}
+	n := contentLength/estimatedLineLength + 1
+	if n > maxEstimatedLines {
+		return maxEstimatedLines
+	}
+	return int(n)
+}
+
+// scanLines invokes callback for each line read from ior, consistently for
+// every query method.  Some servers, especially those behind older proxies,
+// emit \r\n line endings or trailing blank lines, so carriage returns are
+// stripped from the end of each line and blank lines are skipped.  It returns
+// *ErrLineTooLong when a line, including its line ending, exceeds limit bytes.
+//
+// When delimiter is neither zero nor a newline, results are separated by both
+// the delimiter and newlines, and spaces surrounding each result are trimmed.
+func scanLines(ior io.Reader, limit int, delimiter byte, callback func(string) error) error {
+	initial := limit
+	if initial > bufio.MaxScanTokenSize {
+		initial = bufio.MaxScanTokenSize
+	}
+	return scanLinesBuffer(ior, make([]byte, 0, initial), limit, delimiter, callback)
+}
+
+// scanLines invokes callback for each line read from ior, as the package
+// function scanLines does, but using a buffer from the client's pool of
+// ScanBufferSize buffers, and counting each line in Stats.ResultLines.
+func (c *Client) scanLines(ior io.Reader, delimiter byte, callback func(string) error) error {
+	var results int
+	buf := c.scanBuffers.Get().(*[]byte)
+	err := scanLinesBuffer(ior, (*buf)[:0], c.maxLineLength, delimiter, func(line string) error {
+		results++
+		return callback(line)
+	})
+	c.scanBuffers.Put(buf)
+	c.stats.addResults(results)
+	return err
+}
+
+// scanLinesBuffer is scanLines with buf as the initial buffer of the scanner.
+// The scanner allocates a larger buffer for lines that do not fit in buf.
+func scanLinesBuffer(ior io.Reader, buf []byte, limit int, delimiter byte, callback func(string) error) error {
+	return scanLineBytes(ior, buf, limit, delimiter, func(line []byte) error {
+		return callback(string(line))
+	})
+}
+
+// scanLineBytes is scanLinesBuffer, but invokes callback with each line as a
+// slice of the scanner's buffer, which is only valid until callback returns.
+func scanLineBytes(ior io.Reader, buf []byte, limit int, delimiter byte, callback func([]byte) error) error {
+	s := bufio.NewScanner(ior)
+	s.Buffer(buf, limit)
+	delimited := delimiter != 0 && delimiter != '\n'
+	if delimited {
+		s.Split(splitDelimited(delimiter))
+	}
+	for s.Scan() {
+		line := bytes.TrimRight(s.Bytes(), "\r")
+		if delimited {
+			line = bytes.TrimSpace(line)
+		}
+		if len(line) == 0 {
+			continue
+		}
+		if err := callback(line); err != nil {
+			return err
+		}
+	}
+	if err := s.Err(); err != nil {
+		if err == bufio.ErrTooLong {
+			return &ErrLineTooLong{Limit: limit}
+		}
+		return err
+	}
+	return nil
+}
+
+// lineArenaSize is the size of the buffer in which appendLines collects lines
+// before converting them to strings together.
+const lineArenaSize = 4096
+
+// appendLines appends each line read from ior to lines, with the semantics of
+// scanLines, and returns the extended slice.  Rather than allocating a string
+// for each line, it copies lines into an arena, and converts each full arena
+// into a single string, of which the lines are substrings, so results
+// allocate a string for every few kilobytes rather than for every line.  Lines
+// longer than the arena are allocated individually.  Each line appended is
+// counted in Stats.ResultLines.
+func (c *Client) appendLines(lines []string, ior io.Reader, delimiter byte) ([]string, error) {
+	initial := len(lines)
+	arena := make([]byte, 0, lineArenaSize)
+	var ends []int // end offset in arena of each line not yet appended
+
+	flush := func() {
+		if len(ends) == 0 {
+			return
+		}
+		s := string(arena)
+		var start int
+		for _, end := range ends {
+			lines = append(lines, s[start:end])
+			start = end
+		}
+		arena, ends = arena[:0], ends[:0]
+	}
+
+	buf := c.scanBuffers.Get().(*[]byte)
+	err := scanLineBytes(ior, (*buf)[:0], c.maxLineLength, delimiter, func(line []byte) error {
+		if len(line) > lineArenaSize {
+			flush()
+			lines = append(lines, string(line))
+			return nil
+		}
+		if len(arena)+len(line) > lineArenaSize {
+			flush()
+		}
+		arena = append(arena, line...)
+		ends = append(ends, len(arena))
+		return nil
+	})
+	c.scanBuffers.Put(buf)
+	flush()
+	c.stats.addResults(len(lines) - initial)
+	return lines, err
+}
+
+// splitDelimited returns a bufio.SplitFunc that splits its input on either
+// delimiter or newline.
+func splitDelimited(delimiter byte) bufio.SplitFunc {
+	return func(data []byte, atEOF bool) (int, []byte, error) {
+		for i, b := range data {
+			if b == delimiter || b == '\n' {
+				return i + 1, data[:i], nil
+			}
+		}
+		if atEOF && len(data) > 0 {
+			return len(data), data, nil
+		}
+		return 0, nil, nil
+	}
+}
+
+// splitLines invokes callback for each line of body, with the same semantics
+// as scanLines, but each line is a substring of body rather than a separate
+// allocation.
+func splitLines(body string, limit int, delimiter byte, callback func(string) error) error {
+	delimited := delimiter != 0 && delimiter != '\n'
+	for len(body) > 0 {
+		i := strings.IndexByte(body, '\n')
+		if delimited {
+			if j := strings.IndexByte(body, delimiter); j >= 0 && (i < 0 || j < i) {
+				i = j
+			}
+		}
+		var line string
+		if i < 0 {
+			line, body = body, ""
+		} else {
+			line, body = body[:i], body[i+1:]
+		}
+		if len(line) >= limit {
+			return &ErrLineTooLong{Limit: limit}
+		}
+		line = strings.TrimRight(line, "\r")
+		if delimited {
+			line = strings.TrimSpace(line)
+		}
+		if line == "" {
+			continue
+		}
+		if err := callback(line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
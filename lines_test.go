@@ -0,0 +1,293 @@
+package orange
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLineSplitting(t *testing.T) {
+	const body = "result1\r\n\r\nresult2\r\n\n\r\n"
+	want := []string{"result1", "result2"}
+
+	h := func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+	}
+
+	withClient(t, h, func(client *Client) {
+		t.Run("Query", func(t *testing.T) {
+			values, err := client.Query("foo")
+			if err != nil {
+				t.Fatal(err)
+			}
+			ensureResultsEqual(t, values, want)
+		})
+
+		t.Run("QueryForEach", func(t *testing.T) {
+			var values []string
+			err := client.QueryForEach(context.Background(), "foo", func(line string) error {
+				values = append(values, line)
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			ensureResultsEqual(t, values, want)
+		})
+
+		t.Run("QueryForEach callback error", func(t *testing.T) {
+			stop := errors.New("stop")
+			var count int
+			err := client.QueryForEach(context.Background(), "foo", func(line string) error {
+				count++
+				return stop
+			})
+			if got, want := errors.Is(err, stop), true; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+			if got, want := count, 1; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+		})
+
+		t.Run("Response.Split", func(t *testing.T) {
+			response, err := client.QueryResponse(context.Background(), "foo")
+			if err != nil {
+				t.Fatal(err)
+			}
+			ensureResultsEqual(t, response.Split(), want)
+		})
+	})
+}
+
+func TestMaxLineLength(t *testing.T) {
+	long := strings.Repeat("x", 100)
+
+	h := func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("short\n" + long + "\n"))
+	}
+
+	withTestServer(t, h, func(server *httptest.Server) {
+		newClient := func(t *testing.T, maxLineLength int) *Client {
+			client, err := NewClient(&Config{
+				HTTPClient:    server.Client(),
+				MaxLineLength: maxLineLength,
+				Servers:       []string{strings.TrimPrefix(server.URL, "http://")},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			return client
+		}
+
+		t.Run("default", func(t *testing.T) {
+			values, err := newClient(t, 0).Query("foo")
+			if err != nil {
+				t.Fatal(err)
+			}
+			ensureResultsEqual(t, values, []string{"short", long})
+		})
+
+		t.Run("exceeded", func(t *testing.T) {
+			_, err := newClient(t, 50).Query("foo")
+			var tooLong *ErrLineTooLong
+			if !errors.As(err, &tooLong) {
+				t.Fatalf("GOT: %v; WANT: %T", err, tooLong)
+			}
+			if got, want := tooLong.Limit, 50; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+		})
+
+		t.Run("QueryForEach exceeded", func(t *testing.T) {
+			var count int
+			err := newClient(t, 50).QueryForEach(context.Background(), "foo", func(line string) error {
+				count++
+				return nil
+			})
+			var tooLong *ErrLineTooLong
+			if !errors.As(err, &tooLong) {
+				t.Fatalf("GOT: %v; WANT: %T", err, tooLong)
+			}
+			if got, want := count, 1; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+		})
+	})
+}
+
+func TestMaxLineLengthNegative(t *testing.T) {
+	_, err := NewClient(&Config{MaxLineLength: -1, Servers: []string{"localhost"}})
+	ensureError(t, err, "negative MaxLineLength")
+}
+
+func TestDelimiter(t *testing.T) {
+	want := []string{"result1", "result2", "result3"}
+
+	t.Run("detected from Content-Type", func(t *testing.T) {
+		h := func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+			w.Write([]byte("result1, result2,\r\nresult3\n"))
+		}
+		withClient(t, h, func(client *Client) {
+			values, err := client.Query("foo")
+			if err != nil {
+				t.Fatal(err)
+			}
+			ensureResultsEqual(t, values, want)
+
+			response, err := client.QueryResponse(context.Background(), "foo")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := response.Delimiter, byte(','); got != want {
+				t.Errorf("GOT: %q; WANT: %q", got, want)
+			}
+			ensureResultsEqual(t, response.Split(), want)
+		})
+	})
+
+	t.Run("newline by default", func(t *testing.T) {
+		h := func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/plain")
+			w.Write([]byte("result1,result2\n"))
+		}
+		withClient(t, h, func(client *Client) {
+			values, err := client.Query("foo")
+			if err != nil {
+				t.Fatal(err)
+			}
+			ensureResultsEqual(t, values, []string{"result1,result2"})
+		})
+	})
+
+	t.Run("configured", func(t *testing.T) {
+		h := func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/plain")
+			w.Write([]byte("result1;result2\nresult3"))
+		}
+		withTestServer(t, h, func(server *httptest.Server) {
+			client, err := NewClient(&Config{
+				Delimiter:  ';',
+				HTTPClient: server.Client(),
+				Servers:    []string{strings.TrimPrefix(server.URL, "http://")},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var values []string
+			err = client.QueryForEach(context.Background(), "foo", func(line string) error {
+				values = append(values, line)
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			ensureResultsEqual(t, values, want)
+		})
+	})
+}
+
+func TestSplitLinesMatchesScanLines(t *testing.T) {
+	bodies := []string{
+		"",
+		"result1\r\n\r\nresult2\r\n\n\r\n",
+		"result1\nresult2",
+		"web1, web2,\nweb3 ,, \n",
+		strings.Repeat("x", 15) + "\n",
+		strings.Repeat("x", 16) + "\n",
+	}
+
+	for _, delimiter := range []byte{0, ','} {
+		for _, body := range bodies {
+			var want, got []string
+			wantErr := scanLines(strings.NewReader(body), 16, delimiter, func(line string) error {
+				want = append(want, line)
+				return nil
+			})
+			gotErr := splitLines(body, 16, delimiter, func(line string) error {
+				got = append(got, line)
+				return nil
+			})
+			if (gotErr == nil) != (wantErr == nil) {
+				t.Errorf("%q: GOT: %v; WANT: %v", body, gotErr, wantErr)
+				continue
+			}
+			if wantErr == nil {
+				ensureStringSlicesMatch(t, got, want)
+			}
+		}
+	}
+}
+
+func TestZeroCopy(t *testing.T) {
+	h := func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("result1\r\n\r\nresult2\r\n"))
+	}
+
+	withClient(t, h, func(client *Client) {
+		client.zeroCopy = true
+		values, err := client.Query("foo")
+		if err != nil {
+			t.Fatal(err)
+		}
+		ensureStringSlicesMatch(t, values, []string{"result1", "result2"})
+	})
+}
+
+func TestScanBufferSize(t *testing.T) {
+	long := strings.Repeat("x", 100)
+	h := func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("short\n" + long + "\n"))
+	}
+
+	withTestServer(t, h, func(server *httptest.Server) {
+		client, err := NewClient(&Config{
+			HTTPClient:     server.Client(),
+			ScanBufferSize: 8,
+			Servers:        []string{strings.TrimPrefix(server.URL, "http://")},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Buffers are reused, and grow for long lines.
+		for i := 0; i < 3; i++ {
+			values, err := client.Query("foo")
+			if err != nil {
+				t.Fatal(err)
+			}
+			ensureStringSlicesMatch(t, values, []string{"short", long})
+		}
+	})
+
+	_, err := NewClient(&Config{ScanBufferSize: -1, Servers: []string{"localhost"}})
+	ensureError(t, err, "negative ScanBufferSize")
+}
+
+func TestAppendLines(t *testing.T) {
+	client, err := NewClient(&Config{Servers: []string{"localhost"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var body strings.Builder
+	var want []string
+	for i := 0; i < 1000; i++ {
+		line := fmt.Sprintf("host%d.example.com", i)
+		if i%250 == 0 {
+			line = strings.Repeat("x", lineArenaSize+i) // longer than the arena
+		}
+		body.WriteString(line + "\r\n\n")
+		want = append(want, line)
+	}
+
+	got, err := client.appendLines([]string{"first"}, strings.NewReader(body.String()), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ensureStringSlicesMatch(t, got, append([]string{"first"}, want...))
+}
@@ -0,0 +1,202 @@
+package orange
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/karrick/orange/internal/rangeexpr"
+)
+
+// LocalQuerier evaluates range expressions locally against cluster definitions
+// loaded from classic range YAML files, such as a checkout of the files served
+// by a range server, enabling fully offline operation and local testing
+// against real data.  It provides the same query methods as Client.
+//
+//     querier, err := orange.NewLocalQuerier(os.DirFS("/src/range-data"), "clusters")
+//     if err != nil {
+//         return err
+//     }
+//     hosts, err := querier.Query("%web,&%production")
+//
+// The supported syntax is unions (a,b), intersections (a,&b or a&b),
+// differences (a,-b), grouping with parentheses, cluster lookups (%cluster),
+// key lookups (%cluster:KEY), key listings (%cluster:KEYS), and the brace and
+// numeric range expansion of ExpandLocal.  Expressions that cannot be
+// evaluated return an *ErrRangeException, as a range server would.
+type LocalQuerier struct {
+	evaluator rangeexpr.Evaluator
+}
+
+// NewLocalQuerier returns a LocalQuerier that evaluates expressions against the
+// clusters defined by the files with a .yaml extension in directory dir of
+// fsys, one cluster per file, named for the file without its extension.
+func NewLocalQuerier(fsys fs.FS, dir string) (*LocalQuerier, error) {
+	clusters, err := rangeexpr.LoadClusters(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	return &LocalQuerier{evaluator: rangeexpr.Evaluator{Clusters: clusters, Expand: ExpandLocal}}, nil
+}
+
+// Query returns the values of expression.
+func (q *LocalQuerier) Query(expression string) ([]string, error) {
+	return q.QueryCtx(context.Background(), expression)
+}
+
+// QueryCtx returns the values of expression, or the context error when ctx is
+// already done.
+func (q *LocalQuerier) QueryCtx(ctx context.Context, expression string) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	values, err := q.evaluator.Evaluate(expression)
+	if err != nil {
+		return nil, newErrRangeException(err.Error())
+	}
+	return values, nil
+}
+
+// QueryCallback invokes callback with an io.Reader of the values of expression,
+// one per line.
+func (q *LocalQuerier) QueryCallback(ctx context.Context, expression string, callback func(io.Reader) error) error {
+	values, err := q.QueryCtx(ctx, expression)
+	if err != nil {
+		return err
+	}
+	return callback(strings.NewReader(joinLines(values)))
+}
+
+// QueryForEach invokes callback with each of the values of expression.
+func (q *LocalQuerier) QueryForEach(ctx context.Context, expression string, callback func(string) error) error {
+	values, err := q.QueryCtx(ctx, expression)
+	if err != nil {
+		return err
+	}
+	for _, value := range values {
+		if err := callback(value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// QueryChunks invokes callback with successive chunks of at most chunkSize of
+// the values of expression.
+func (q *LocalQuerier) QueryChunks(ctx context.Context, expression string, chunkSize int, callback func([]string) error) error {
+	return forEachChunk(chunkSize, func(send func(string) error) error {
+		return q.QueryForEach(ctx, expression, send)
+	}, callback)
+}
+
+// QueryResponse returns a Response holding the values of expression.
+func (q *LocalQuerier) QueryResponse(ctx context.Context, expression string) (*Response, error) {
+	start := time.Now()
+	values, err := q.QueryCtx(ctx, expression)
+	if err != nil {
+		return nil, err
+	}
+	return &Response{
+		Expression: expression,
+		Server:     "local",
+		Attempts:   1,
+		Duration:   time.Since(start),
+		Body:       []byte(joinLines(values)),
+	}, nil
+}
+
+// QueryStream returns a Stream yielding the values of expression.
+func (q *LocalQuerier) QueryStream(ctx context.Context, expression string) *Stream {
+	return newStream(ctx, func(ctx context.Context, send func(string) error) error {
+		return q.QueryForEach(ctx, expression, send)
+	})
+}
+
+// Queries returns the values of each of the expressions, in the same order as
+// the expressions.  See Client.Queries for how errors are returned.
+func (q *LocalQuerier) Queries(expressions []string) ([][]string, error) {
+	return q.QueriesCtx(context.Background(), expressions)
+}
+
+// QueriesCtx returns the values of each of the expressions, in the same order
+// as the expressions.  See Client.Queries for how errors are returned.
+func (q *LocalQuerier) QueriesCtx(ctx context.Context, expressions []string) ([][]string, error) {
+	return queriesCtx(ctx, expressions, q.QueryCtx, nil)
+}
+
+// QueriesConcurrency is QueriesCtx, but runs at most concurrency of the
+// queries at once.  See Client.QueriesConcurrency.
+func (q *LocalQuerier) QueriesConcurrency(ctx context.Context, expressions []string, concurrency int) ([][]string, error) {
+	return queriesCtx(ctx, expressions, q.QueryCtx, limitRun(ctx, concurrency, nil))
+}
+
+// Expand returns the values of expression folded into compact range notation
+// by Compress, in place of the expansion a range server would provide.  See
+// Client.Expand.
+func (q *LocalQuerier) Expand(ctx context.Context, expression string) (string, error) {
+	values, err := q.QueryCtx(ctx, expression)
+	if err != nil {
+		return "", err
+	}
+	return Compress(values), nil
+}
+
+// QueryWithOptions returns the values of expression.  Only the Timeout of
+// options has an effect.  See Client.QueryWithOptions.
+func (q *LocalQuerier) QueryWithOptions(ctx context.Context, expression string, options QueryOptions) ([]string, error) {
+	if err := options.validate(); err != nil {
+		return nil, err
+	}
+	ctx, cancel := options.withTimeout(ctx)
+	defer cancel()
+	return q.QueryCtx(ctx, expression)
+}
+
+// QueryUnion returns the union of the values of expressions, without
+// duplicates.  See Client.QueryUnion.
+func (q *LocalQuerier) QueryUnion(ctx context.Context, expressions []string) ([]string, error) {
+	return queryUnion(ctx, expressions, q.QueryCtx)
+}
+
+// ClustersOf returns the names of the clusters whose members include host,
+// sorted.  See Client.ClustersOf.
+func (q *LocalQuerier) ClustersOf(ctx context.Context, host string) ([]string, error) {
+	if _, err := clustersOfExpression(DefaultReverseOperator, host); err != nil {
+		return nil, err
+	}
+	var clusters []string
+	for name := range q.evaluator.Clusters {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		members, err := q.evaluator.Evaluate("%" + name)
+		if err != nil {
+			continue // clusters without members, or with bad definitions, contain no hosts
+		}
+		if Results(members).Contains(host) {
+			clusters = append(clusters, name)
+		}
+	}
+	sort.Strings(clusters)
+	return clusters, nil
+}
+
+// KeysOf returns the keys defined on cluster, sorted.  See Client.KeysOf.
+func (q *LocalQuerier) KeysOf(ctx context.Context, cluster string) ([]string, error) {
+	expression, err := clusterKeyExpression(cluster, "KEYS")
+	if err != nil {
+		return nil, err
+	}
+	return q.QueryCtx(ctx, expression)
+}
+
+// joinLines returns values as a response body, one per line.
+func joinLines(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return strings.Join(values, "\n") + "\n"
+}
@@ -0,0 +1,155 @@
+package orange
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func newTestLocalQuerier(tb testing.TB) *LocalQuerier {
+	tb.Helper()
+	fsys := fstest.MapFS{
+		"clusters/web.yaml": {Data: []byte("CLUSTER:\n  - web{1..3}.example.com\nOWNER: infra\n")},
+		"clusters/db.yaml":  {Data: []byte("CLUSTER: [db1.example.com, web3.example.com]\n")},
+		"clusters/all.yaml": {Data: []byte("CLUSTER:\n  - '%web'\n  - '%db'\n")},
+		"clusters/README":   {Data: []byte("not a cluster\n")},
+	}
+	querier, err := NewLocalQuerier(fsys, "clusters")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return querier
+}
+
+func TestLocalQuerier(t *testing.T) {
+	querier := newTestLocalQuerier(t)
+
+	cases := []struct {
+		expression string
+		want       string
+	}{
+		{"%web", "web1.example.com,web2.example.com,web3.example.com"},
+		{"%web,&%db", "web3.example.com"},
+		{"%all,-%web", "db1.example.com"},
+		{"%web:OWNER", "infra"},
+		{"%web:KEYS", "CLUSTER,OWNER"},
+		{"host{1,2}", "host1,host2"},
+	}
+
+	for _, c := range cases {
+		values, err := querier.Query(c.expression)
+		if err != nil {
+			t.Fatalf("%q: %s", c.expression, err)
+		}
+		if got, want := strings.Join(values, ","), c.want; got != want {
+			t.Errorf("%q: GOT: %v; WANT: %v", c.expression, got, want)
+		}
+	}
+}
+
+func TestLocalQuerierRangeException(t *testing.T) {
+	querier := newTestLocalQuerier(t)
+
+	_, err := querier.Query("%missing")
+	var rangeException *ErrRangeException
+	if !errors.As(err, &rangeException) {
+		t.Fatalf("GOT: %v; WANT: %T", err, rangeException)
+	}
+}
+
+func TestLocalQuerierContext(t *testing.T) {
+	querier := newTestLocalQuerier(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := querier.QueryCtx(ctx, "%web")
+	if got, want := err, context.Canceled; !errors.Is(got, want) {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+}
+
+func TestLocalQuerierResponse(t *testing.T) {
+	querier := newTestLocalQuerier(t)
+
+	response, err := querier.QueryResponse(context.Background(), "%db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ensureStringSlicesMatch(t, response.Split(), []string{"db1.example.com", "web3.example.com"})
+
+	results, err := querier.Queries([]string{"%db", "%web:OWNER"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(results), 2; got != want {
+		t.Fatalf("GOT: %v; WANT: %v", got, want)
+	}
+	ensureStringSlicesMatch(t, results[1], []string{"infra"})
+}
+
+func TestLocalQuerierMethods(t *testing.T) {
+	querier := newTestLocalQuerier(t)
+	ctx := context.Background()
+
+	t.Run("Expand", func(t *testing.T) {
+		expansion, err := querier.Expand(ctx, "%all")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := expansion, "web1..3.example.com,db1.example.com"; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+
+		_, err = querier.Expand(ctx, "%missing")
+		var rangeException *ErrRangeException
+		if !errors.As(err, &rangeException) {
+			t.Errorf("GOT: %v; WANT: %T", err, rangeException)
+		}
+	})
+
+	t.Run("QueryWithOptions", func(t *testing.T) {
+		values, err := querier.QueryWithOptions(ctx, "%db", QueryOptions{NoRetry: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ensureStringSlicesMatch(t, values, []string{"db1.example.com", "web3.example.com"})
+	})
+
+	t.Run("QueryUnion", func(t *testing.T) {
+		values, err := querier.QueryUnion(ctx, []string{"%db", "%web"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ensureStringSlicesMatch(t, values, []string{"db1.example.com", "web3.example.com", "web1.example.com", "web2.example.com"})
+	})
+
+	t.Run("ClustersOf", func(t *testing.T) {
+		clusters, err := querier.ClustersOf(ctx, "web3.example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		ensureStringSlicesMatch(t, clusters, []string{"all", "db", "web"})
+
+		clusters, err = querier.ClustersOf(ctx, "unknown.example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		ensureStringSlicesMatch(t, clusters, nil)
+	})
+
+	t.Run("KeysOf", func(t *testing.T) {
+		keys, err := querier.KeysOf(ctx, "web")
+		if err != nil {
+			t.Fatal(err)
+		}
+		ensureStringSlicesMatch(t, keys, []string{"CLUSTER", "OWNER"})
+
+		_, err = querier.KeysOf(ctx, "missing")
+		var rangeException *ErrRangeException
+		if !errors.As(err, &rangeException) {
+			t.Errorf("GOT: %v; WANT: %T", err, rangeException)
+		}
+	})
+}
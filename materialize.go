@@ -0,0 +1,120 @@
+package orange
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Manifest maps the names of files to the expressions whose results they hold,
+// for Materialize.
+type Manifest map[string]string
+
+// ReadManifest reads a Manifest with one file per line, its name followed by
+// white space and its expression.  Blank lines and lines starting with # are
+// ignored.
+//
+//     # name        expression
+//     web.hosts     %web
+//     backends.txt  %web,&%production
+func ReadManifest(r io.Reader) (Manifest, error) {
+	manifest := make(Manifest)
+	scanner := bufio.NewScanner(r)
+	var line int
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || text[0] == '#' {
+			continue
+		}
+		i := strings.IndexAny(text, " \t")
+		if i < 0 {
+			return nil, fmt.Errorf("cannot read manifest line %d: missing expression", line)
+		}
+		name, expression := text[:i], strings.TrimSpace(text[i+1:])
+		if _, ok := manifest[name]; ok {
+			return nil, fmt.Errorf("cannot read manifest line %d: duplicate file name: %q", line, name)
+		}
+		manifest[name] = expression
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// Materialize queries each of the expressions in manifest, and writes their
+// results to the named files in dir, one per line, for systems that consume
+// range data as files, such as firewall or load balancer configuration
+// generators.  It returns the sorted names of the files whose contents
+// changed, so callers reload their consumers only when needed.
+//
+//     changed, err := client.Materialize(ctx, "/etc/range", manifest)
+//     if err != nil {
+//         return err
+//     }
+//     if len(changed) > 0 {
+//         reloadHAProxy()
+//     }
+//
+// Each file is replaced atomically, so consumers never read a partial file,
+// and files whose contents are unchanged are not written.  When some queries
+// fail, the files of the others are still written, and a *BatchError maps each
+// failed expression to its error.  The files of failed queries are left as
+// they were.
+func (c *Client) Materialize(ctx context.Context, dir string, manifest Manifest) ([]string, error) {
+	names := make([]string, 0, len(manifest))
+	for name := range manifest {
+		if !filepath.IsLocal(name) {
+			return nil, fmt.Errorf("cannot materialize file outside of directory: %q", name)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	expressions := make([]string, len(names))
+	for i, name := range names {
+		expressions[i] = manifest[name]
+	}
+	results, queryErr := c.QueriesCtx(ctx, expressions)
+	var be *BatchError
+	if queryErr != nil && !errors.As(queryErr, &be) {
+		return nil, queryErr
+	}
+
+	var changed []string
+	for i, name := range names {
+		if be != nil {
+			if _, ok := be.Errors[expressions[i]]; ok {
+				continue
+			}
+		}
+		var buf bytes.Buffer
+		for _, value := range results[i] {
+			buf.WriteString(value)
+			buf.WriteByte('\n')
+		}
+
+		pathname := filepath.Join(dir, name)
+		previous, err := os.ReadFile(pathname)
+		if err == nil && bytes.Equal(previous, buf.Bytes()) {
+			continue
+		}
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return changed, err
+		}
+		if err = writeFileAtomic(pathname, buf.Bytes()); err != nil {
+			return changed, fmt.Errorf("cannot materialize %q: %w", name, err)
+		}
+		changed = append(changed, name)
+	}
+	return changed, queryErr
+}
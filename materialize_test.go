@@ -0,0 +1,80 @@
+package orange
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadManifest(t *testing.T) {
+	manifest, err := ReadManifest(strings.NewReader("# name expression\n\nweb.hosts %web\nboth.txt\t %web,&%db \n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(manifest), 2; got != want {
+		t.Fatalf("GOT: %v; WANT: %v", got, want)
+	}
+	if got, want := manifest["both.txt"], "%web,&%db"; got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+
+	_, err = ReadManifest(strings.NewReader("web.hosts\n"))
+	ensureError(t, err, "line 1: missing expression")
+
+	_, err = ReadManifest(strings.NewReader("a %web\na %db\n"))
+	ensureError(t, err, "line 2: duplicate file name")
+}
+
+func TestMaterialize(t *testing.T) {
+	dir := t.TempDir()
+	mock := &MockConfig{Queries: map[string]MockResult{
+		"%web": {Results: []string{"web1", "web2"}},
+		"%db":  {Results: []string{"db1"}},
+		"%bad": {Err: errors.New("boom")},
+	}}
+	client, err := NewMockClient(mock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest := Manifest{"web.hosts": "%web", "db/db.hosts": "%db"}
+
+	changed, err := client.Materialize(context.Background(), dir, manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ensureStringSlicesMatch(t, changed, []string{"db/db.hosts", "web.hosts"})
+
+	buf, err := os.ReadFile(filepath.Join(dir, "web.hosts"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(buf), "web1\nweb2\n"; got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+
+	// Unchanged files are not reported.
+	mock.Queries["%db"] = MockResult{Results: []string{"db1", "db2"}}
+	changed, err = client.Materialize(context.Background(), dir, manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ensureStringSlicesMatch(t, changed, []string{"db/db.hosts"})
+
+	// Failed queries leave their files alone.
+	manifest["bad.hosts"] = "%bad"
+	changed, err = client.Materialize(context.Background(), dir, manifest)
+	var be *BatchError
+	if !errors.As(err, &be) {
+		t.Fatalf("GOT: %v; WANT: %T", err, be)
+	}
+	ensureStringSlicesMatch(t, changed, nil)
+	if _, err = os.Stat(filepath.Join(dir, "bad.hosts")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("GOT: %v; WANT: %v", err, os.ErrNotExist)
+	}
+
+	_, err = client.Materialize(context.Background(), dir, Manifest{"../escape": "%web"})
+	ensureError(t, err, "outside of directory")
+}
@@ -0,0 +1,386 @@
+package orange
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MockConfig is a Doer that answers range queries without a range server, so
+// code that queries range can be tested without network access.  Use it with
+// NewMockClient, or as the HTTPClient of a Config.
+//
+//     client, err := orange.NewMockClient(&orange.MockConfig{
+//         Queries: map[string]orange.MockResult{
+//             "%cluster1": {Results: []string{"host1", "host2"}},
+//             "%cluster2": {Err: errors.New("connection refused")},
+//         },
+//         Results: []string{"default"},
+//     })
+//
+// The results for a query are looked up first in Queries, then in Fixtures,
+// then by invoking Callback, and finally taken from Results.
+type MockConfig struct {
+	// Callback, when not nil, is invoked with the expression of each query not
+	// found in Queries, and its return values are used as the query results.
+	// When it returns an *ErrRangeException, its message and body are sent as
+	// a RangeException response.  It may be invoked concurrently by concurrent
+	// queries.
+	Callback func(expression string) ([]string, error)
+
+	// Queries maps specific expressions to their results, so tests can use a
+	// single client for several expressions without writing a Callback.
+	Queries map[string]MockResult
+
+	// Fixtures, when not nil, holds one file of results per expression, one
+	// result per line, so large realistic datasets can back tests without
+	// giant string literals.  Use MockFixtureName to name the file for an
+	// expression.  Fixtures may be an embed.FS, or os.DirFS for a directory of
+	// golden files.  It is consulted for expressions not found in Queries,
+	// before invoking Callback.
+	Fixtures fs.FS
+
+	// Results are returned for each query not found in Queries or Fixtures
+	// when Callback is nil.
+	Results []string
+
+	// TimeDelay is how long to wait before responding to each query.  The wait
+	// ends early, and Do returns the context error, when the context of the
+	// request is done.
+	TimeDelay time.Duration
+
+	// Clock, when not nil, is used to wait for TimeDelay and the jitter, such
+	// as a VirtualClock so delays take no real time.
+	Clock Clock
+
+	// FailureRate is the probability, from 0 to 1, that a request fails with
+	// ErrMockFault rather than being answered, for exercising timeout and
+	// retry handling.
+	FailureRate float64
+
+	// JitterMin and JitterMax bound a random delay, chosen uniformly for each
+	// request, added to TimeDelay.
+	JitterMin, JitterMax time.Duration
+
+	// Seed seeds the random choices of FailureRate and the jitter, so a noisy
+	// test is reproducible.
+	Seed int64
+
+	// lock protects the state below, so a mock may be shared by parallel
+	// tests and concurrent queries.
+	lock     sync.Mutex
+	requests []MockRequest
+	counts   map[string]int
+	changed  chan struct{} // closed and replaced when a request is recorded
+	rng      *rand.Rand
+}
+
+// ErrMockFault is returned by MockConfig.Do for requests chosen to fail by
+// FailureRate.  It reports itself as a temporary timeout, so the default retry
+// policy retries it just like a transient network failure.
+var ErrMockFault error = new(mockFault)
+
+type mockFault struct{}
+
+func (*mockFault) Error() string   { return "mock injected fault" }
+func (*mockFault) Temporary() bool { return true }
+func (*mockFault) Timeout() bool   { return true }
+
+// MockRequest is a request received by MockConfig, recorded so tests can verify
+// what the code under test asked range.
+type MockRequest struct {
+	Method     string      // Method is the HTTP method of the request.
+	URL        string      // URL is the request URL.
+	Expression string      // Expression is the decoded range expression.
+	Header     http.Header // Header holds the request headers.
+	Body       []byte      // Body is the request body, if any.
+}
+
+// MockT is the subset of testing.TB used by the MockConfig assertion methods.
+type MockT interface {
+	Helper()
+	Errorf(format string, args ...interface{})
+}
+
+// MockResult is the canned result of a query expression for MockConfig.
+type MockResult struct {
+	// Results are the values returned by the query.
+	Results []string
+
+	// Err, when not nil, is returned by Do in place of a response, just as a
+	// failure to reach the range server would be.
+	Err error
+
+	// RangeException, when not empty, is sent as the RangeException header of
+	// the response in place of the results, so the query returns an
+	// *ErrRangeException with this message, as for a bad expression.
+	RangeException string
+
+	// ExceptionBody is the response body sent along with RangeException.
+	ExceptionBody string
+}
+
+// NewMockClient returns a Client that resolves queries using config rather than
+// a range server.
+func NewMockClient(config *MockConfig) (*Client, error) {
+	return NewClient(&Config{
+		HTTPClient: config,
+		Servers:    []string{"mock"},
+	})
+}
+
+// Do responds to the range query in request with the configured results.
+func (m *MockConfig) Do(request *http.Request) (*http.Response, error) {
+	// Long expressions are sent using PUT, with the expression in the body.
+	expression, err := requestExpression(request)
+	if err != nil {
+		return nil, err
+	}
+
+	recorded := MockRequest{
+		Method:     request.Method,
+		URL:        request.URL.String(),
+		Expression: expression,
+		Header:     request.Header.Clone(),
+	}
+	if request.Body != nil {
+		recorded.Body, err = io.ReadAll(request.Body)
+		_ = request.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	results, err := m.answer(request.Context(), recorded)
+	if err != nil {
+		var rangeException *ErrRangeException
+		if errors.As(err, &rangeException) {
+			header := make(http.Header)
+			header.Set("RangeException", rangeException.Message)
+			return mockResponse(request, http.StatusOK, header, string(rangeException.Body)), nil
+		}
+		return nil, err
+	}
+
+	var body string
+	if len(results) > 0 {
+		body = strings.Join(results, "\n") + "\n"
+	}
+	return mockResponse(request, http.StatusOK, make(http.Header), body), nil
+}
+
+// answer waits for any configured delay, records the request, and returns the
+// results for its expression, or the injected fault.
+func (m *MockConfig) answer(ctx context.Context, recorded MockRequest) ([]string, error) {
+	delay, fail := m.noise()
+	if delay > 0 {
+		clock := m.Clock
+		if clock == nil {
+			clock = realClock{}
+		}
+		select {
+		case <-clock.After(delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	m.lock.Lock()
+	m.requests = append(m.requests, recorded)
+	if m.counts == nil {
+		m.counts = make(map[string]int)
+	}
+	m.counts[recorded.Expression]++
+	if m.changed != nil {
+		close(m.changed)
+		m.changed = nil
+	}
+	m.lock.Unlock()
+
+	if fail {
+		return nil, ErrMockFault
+	}
+	return m.resultsFor(recorded.Expression)
+}
+
+// noise returns how long to delay the response to a request, and whether the
+// request should fail, as chosen by the fault injection settings.
+func (m *MockConfig) noise() (time.Duration, bool) {
+	delay := m.TimeDelay
+	if m.FailureRate <= 0 && m.JitterMin <= 0 && m.JitterMax <= 0 {
+		return delay, false
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.rng == nil {
+		m.rng = rand.New(rand.NewSource(m.Seed))
+	}
+
+	delay += m.JitterMin
+	if spread := m.JitterMax - m.JitterMin; spread > 0 {
+		delay += time.Duration(m.rng.Int63n(int64(spread) + 1))
+	}
+	return delay, m.FailureRate > 0 && m.rng.Float64() < m.FailureRate
+}
+
+// Requests returns the requests received, in the order they were received.
+func (m *MockConfig) Requests() []MockRequest {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return append([]MockRequest(nil), m.requests...)
+}
+
+// Count returns the number of requests received for expression.
+func (m *MockConfig) Count(expression string) int {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.counts[expression]
+}
+
+// TotalCount returns the number of requests received for all expressions.
+func (m *MockConfig) TotalCount() int {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return len(m.requests)
+}
+
+// WaitForCount blocks until at least n requests have been received for
+// expression, or ctx is done, in which case it returns the context error.  It
+// supports tests of code that queries range from other go-routines.
+//
+//     go codeUnderTest(client)
+//     ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+//     defer cancel()
+//     if err := mock.WaitForCount(ctx, "%cluster1", 3); err != nil {
+//         t.Fatal(err)
+//     }
+func (m *MockConfig) WaitForCount(ctx context.Context, expression string, n int) error {
+	for {
+		m.lock.Lock()
+		count := m.counts[expression]
+		if m.changed == nil {
+			m.changed = make(chan struct{})
+		}
+		changed := m.changed
+		m.lock.Unlock()
+
+		if count >= n {
+			return nil
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return fmt.Errorf("received %d of %d requests for %q: %w", count, n, expression, ctx.Err())
+		}
+	}
+}
+
+// AssertCount reports an error to t unless exactly n requests were received for
+// expression.
+func (m *MockConfig) AssertCount(t MockT, expression string, n int) {
+	t.Helper()
+	if count := m.Count(expression); count != n {
+		t.Errorf("GOT: %v; WANT: %v requests for %q", count, n, expression)
+	}
+}
+
+// AssertQueried reports an error to t unless expression was queried.
+//
+//     mock := &orange.MockConfig{Results: []string{"host1"}}
+//     client, _ := orange.NewMockClient(mock)
+//     codeUnderTest(client)
+//     mock.AssertQueried(t, "%cluster1")
+func (m *MockConfig) AssertQueried(t MockT, expression string) {
+	t.Helper()
+	if !m.queried(expression) {
+		t.Errorf("GOT: %v; WANT: query %q", m.expressions(), expression)
+	}
+}
+
+// AssertNotQueried reports an error to t when expression was queried.
+func (m *MockConfig) AssertNotQueried(t MockT, expression string) {
+	t.Helper()
+	if m.queried(expression) {
+		t.Errorf("GOT: %v; WANT: no query %q", m.expressions(), expression)
+	}
+}
+
+func (m *MockConfig) queried(expression string) bool {
+	for _, request := range m.Requests() {
+		if request.Expression == expression {
+			return true
+		}
+	}
+	return false
+}
+
+// expressions returns the expressions of the requests received.
+func (m *MockConfig) expressions() []string {
+	requests := m.Requests()
+	expressions := make([]string, len(requests))
+	for i, request := range requests {
+		expressions[i] = request.Expression
+	}
+	return expressions
+}
+
+// resultsFor returns the configured results for expression.  A configured
+// RangeException is returned as an *ErrRangeException.
+func (m *MockConfig) resultsFor(expression string) ([]string, error) {
+	if result, ok := m.Queries[expression]; ok {
+		if result.RangeException != "" && result.Err == nil {
+			e := newErrRangeException(result.RangeException)
+			if result.ExceptionBody != "" {
+				e.Body = []byte(result.ExceptionBody)
+			}
+			return nil, e
+		}
+		return result.Results, result.Err
+	}
+	if name := MockFixtureName(expression); m.Fixtures != nil && fs.ValidPath(name) {
+		buf, err := fs.ReadFile(m.Fixtures, name)
+		if err == nil {
+			return (&Response{Body: buf}).Split(), nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+	if m.Callback != nil {
+		return m.Callback(expression)
+	}
+	return m.Results, nil
+}
+
+// MockFixtureName returns the name of the file in MockConfig.Fixtures holding
+// the results for expression.  It is the expression escaped so it is a valid
+// file name, for example "%25cluster1" for "%cluster1".
+func MockFixtureName(expression string) string {
+	return url.PathEscape(expression)
+}
+
+// mockResponse returns a response to request with the specified status code,
+// headers, and body.
+func mockResponse(request *http.Request, statusCode int, header http.Header, body string) *http.Response {
+	return &http.Response{
+		Status:        strconv.Itoa(statusCode) + " " + http.StatusText(statusCode),
+		StatusCode:    statusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       request,
+	}
+}
@@ -0,0 +1,189 @@
+package orange
+
+import (
+	"context"
+	"io"
+	"strings"
+	"time"
+)
+
+// MockQuerier answers queries directly from the results configured in a
+// MockConfig, with none of the HTTP, retry, or transport code of a Client, so
+// unit tests of code that queries range depend on nothing but the canned
+// results.  It provides the same query methods as Client.
+//
+//     mock := &orange.MockConfig{Queries: map[string]orange.MockResult{
+//         "%cluster1": {Results: []string{"host1", "host2"}},
+//     }}
+//     querier := orange.NewMockQuerier(mock)
+//     codeUnderTest(querier)
+//     mock.AssertQueried(t, "%cluster1")
+//
+// Queries are recorded in the MockConfig with only their Expression, and its
+// delays and injected faults apply just as they do to a mock Client.
+type MockQuerier struct {
+	config *MockConfig
+}
+
+// NewMockQuerier returns a MockQuerier that answers queries using config.
+func NewMockQuerier(config *MockConfig) *MockQuerier {
+	return &MockQuerier{config: config}
+}
+
+// Query returns the results configured for expression.
+func (q *MockQuerier) Query(expression string) ([]string, error) {
+	return q.QueryCtx(context.Background(), expression)
+}
+
+// QueryCtx returns the results configured for expression, or the context error
+// when ctx is done before a configured delay elapses.
+func (q *MockQuerier) QueryCtx(ctx context.Context, expression string) ([]string, error) {
+	results, err := q.config.answer(ctx, MockRequest{Expression: expression})
+	if err != nil {
+		return nil, err
+	}
+	return copyStrings(results), nil
+}
+
+// QueryCallback invokes callback with an io.Reader of the results configured
+// for expression, one per line.
+func (q *MockQuerier) QueryCallback(ctx context.Context, expression string, callback func(io.Reader) error) error {
+	body, err := q.body(ctx, expression)
+	if err != nil {
+		return err
+	}
+	return callback(strings.NewReader(body))
+}
+
+// QueryForEach invokes callback with each of the results configured for
+// expression.
+func (q *MockQuerier) QueryForEach(ctx context.Context, expression string, callback func(string) error) error {
+	results, err := q.config.answer(ctx, MockRequest{Expression: expression})
+	if err != nil {
+		return err
+	}
+	for _, result := range results {
+		if err := callback(result); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// QueryChunks invokes callback with successive chunks of at most chunkSize of
+// the results configured for expression.
+func (q *MockQuerier) QueryChunks(ctx context.Context, expression string, chunkSize int, callback func([]string) error) error {
+	return forEachChunk(chunkSize, func(send func(string) error) error {
+		return q.QueryForEach(ctx, expression, send)
+	}, callback)
+}
+
+// QueryResponse returns a Response holding the results configured for
+// expression.
+func (q *MockQuerier) QueryResponse(ctx context.Context, expression string) (*Response, error) {
+	start := time.Now()
+	body, err := q.body(ctx, expression)
+	if err != nil {
+		return nil, err
+	}
+	return &Response{
+		Expression: expression,
+		Server:     "mock",
+		Attempts:   1,
+		Duration:   time.Since(start),
+		Body:       []byte(body),
+	}, nil
+}
+
+// QueryStream returns a Stream yielding the results configured for expression.
+func (q *MockQuerier) QueryStream(ctx context.Context, expression string) *Stream {
+	return newStream(ctx, func(ctx context.Context, send func(string) error) error {
+		return q.QueryForEach(ctx, expression, send)
+	})
+}
+
+// Queries returns the results configured for each of the expressions, in the
+// same order as the expressions.  See Client.Queries for how errors are
+// returned.
+func (q *MockQuerier) Queries(expressions []string) ([][]string, error) {
+	return q.QueriesCtx(context.Background(), expressions)
+}
+
+// QueriesCtx returns the results configured for each of the expressions, in the
+// same order as the expressions.  See Client.Queries for how errors are
+// returned.
+func (q *MockQuerier) QueriesCtx(ctx context.Context, expressions []string) ([][]string, error) {
+	return queriesCtx(ctx, expressions, q.QueryCtx, nil)
+}
+
+// QueriesConcurrency is QueriesCtx, but runs at most concurrency of the
+// queries at once.  See Client.QueriesConcurrency.
+func (q *MockQuerier) QueriesConcurrency(ctx context.Context, expressions []string, concurrency int) ([][]string, error) {
+	return queriesCtx(ctx, expressions, q.QueryCtx, limitRun(ctx, concurrency, nil))
+}
+
+// Expand returns the results configured for expression, joined by commas, as
+// Client.Expand joins the lines of an expansion.  Configure the expansion
+// itself, such as "web{1-3}", as the single result of expression.
+func (q *MockQuerier) Expand(ctx context.Context, expression string) (string, error) {
+	results, err := q.config.answer(ctx, MockRequest{Expression: expression})
+	if err != nil {
+		return "", err
+	}
+	return strings.Join(results, ","), nil
+}
+
+// QueryWithOptions returns the results configured for expression.  Only the
+// Timeout of options has an effect, and its Method and Header are recorded
+// with the query.  See Client.QueryWithOptions.
+func (q *MockQuerier) QueryWithOptions(ctx context.Context, expression string, options QueryOptions) ([]string, error) {
+	if err := options.validate(); err != nil {
+		return nil, err
+	}
+	ctx, cancel := options.withTimeout(ctx)
+	defer cancel()
+
+	results, err := q.config.answer(ctx, MockRequest{Method: options.Method, Expression: expression, Header: options.Header})
+	if err != nil {
+		return nil, err
+	}
+	return copyStrings(results), nil
+}
+
+// QueryUnion returns the union of the results configured for each of the
+// expressions, without duplicates.  Unlike Client.QueryUnion, each expression
+// is queried and recorded separately, so results are configured for each
+// expression rather than for merged expressions.
+func (q *MockQuerier) QueryUnion(ctx context.Context, expressions []string) ([]string, error) {
+	return queryUnion(ctx, expressions, q.QueryCtx)
+}
+
+// ClustersOf returns the results configured for the reverse lookup query of
+// host, using DefaultReverseOperator, such as "*web42.example.com".  See
+// Client.ClustersOf.
+func (q *MockQuerier) ClustersOf(ctx context.Context, host string) ([]string, error) {
+	expression, err := clustersOfExpression(DefaultReverseOperator, host)
+	if err != nil {
+		return nil, err
+	}
+	return q.QueryCtx(ctx, expression)
+}
+
+// KeysOf returns the results configured for the %cluster:KEYS query of cluster.
+// See Client.KeysOf.
+func (q *MockQuerier) KeysOf(ctx context.Context, cluster string) ([]string, error) {
+	expression, err := clusterKeyExpression(cluster, "KEYS")
+	if err != nil {
+		return nil, err
+	}
+	return q.QueryCtx(ctx, expression)
+}
+
+// body returns the results configured for expression as a response body.
+func (q *MockQuerier) body(ctx context.Context, expression string) (string, error) {
+	results, err := q.config.answer(ctx, MockRequest{Expression: expression})
+	if err != nil || len(results) == 0 {
+		return "", err
+	}
+	return strings.Join(results, "\n") + "\n", nil
+}
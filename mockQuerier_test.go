@@ -0,0 +1,196 @@
+package orange
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestMockQuerier(t *testing.T) {
+	refused := errors.New("connection refused")
+	mock := &MockConfig{
+		Queries: map[string]MockResult{
+			"%cluster1":      {Results: []string{"host1", "host2"}},
+			"%cluster2":      {Err: refused},
+			"%cluster1:KEYS": {Results: []string{"CLUSTER", "OWNER"}},
+			"*host1":         {Results: []string{"cluster1"}},
+			"%cluster4":      {Results: []string{"host2", "host3"}},
+			"%cluster5":      {Results: []string{"host{1-3}"}},
+		},
+	}
+	querier := NewMockQuerier(mock)
+	ctx := context.Background()
+
+	t.Run("Query", func(t *testing.T) {
+		values, err := querier.Query("%cluster1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		ensureStringSlicesMatch(t, values, []string{"host1", "host2"})
+
+		// Modifying results does not modify the configured results.
+		values[0] = "modified"
+		values, err = querier.QueryCtx(ctx, "%cluster1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		ensureStringSlicesMatch(t, values, []string{"host1", "host2"})
+	})
+
+	t.Run("error", func(t *testing.T) {
+		_, err := querier.Query("%cluster2")
+		if got, want := errors.Is(err, refused), true; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("QueryCallback", func(t *testing.T) {
+		var body []byte
+		err := querier.QueryCallback(ctx, "%cluster1", func(ior io.Reader) error {
+			var err error
+			body, err = io.ReadAll(ior)
+			return err
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := string(body), "host1\nhost2\n"; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+	})
+
+	t.Run("QueryForEach", func(t *testing.T) {
+		var values []string
+		err := querier.QueryForEach(ctx, "%cluster1", func(value string) error {
+			values = append(values, value)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ensureStringSlicesMatch(t, values, []string{"host1", "host2"})
+	})
+
+	t.Run("QueryResponse", func(t *testing.T) {
+		response, err := querier.QueryResponse(ctx, "%cluster1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		ensureStringSlicesMatch(t, response.Split(), []string{"host1", "host2"})
+		if got, want := response.Expression, "%cluster1"; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("QueryStream", func(t *testing.T) {
+		stream := querier.QueryStream(ctx, "%cluster1")
+		defer stream.Close()
+		var values []string
+		for stream.Next() {
+			values = append(values, stream.Value())
+		}
+		if err := stream.Err(); err != nil {
+			t.Fatal(err)
+		}
+		ensureStringSlicesMatch(t, values, []string{"host1", "host2"})
+
+		stream = querier.QueryStream(ctx, "%cluster2")
+		defer stream.Close()
+		if got, want := stream.Next(), false; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := errors.Is(stream.Err(), refused), true; got != want {
+			t.Errorf("GOT: %v; WANT: %v", stream.Err(), want)
+		}
+	})
+
+	t.Run("Queries", func(t *testing.T) {
+		results, err := querier.Queries([]string{"%cluster1", "%cluster2"})
+		var be *BatchError
+		if !errors.As(err, &be) {
+			t.Fatalf("GOT: %v; WANT: %T", err, be)
+		}
+		if got, want := len(be.Errors), 1; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		ensureStringSlicesMatch(t, results[0], []string{"host1", "host2"})
+	})
+
+	t.Run("Expand", func(t *testing.T) {
+		expansion, err := querier.Expand(ctx, "%cluster5")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := expansion, "host{1-3}"; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+
+		expansion, err = querier.Expand(ctx, "%cluster1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := expansion, "host1,host2"; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+
+		_, err = querier.Expand(ctx, "%cluster2")
+		if got, want := errors.Is(err, refused), true; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("QueryWithOptions", func(t *testing.T) {
+		values, err := querier.QueryWithOptions(ctx, "%cluster1", QueryOptions{Method: "PUT", Timeout: time.Minute})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ensureStringSlicesMatch(t, values, []string{"host1", "host2"})
+		requests := mock.Requests()
+		if got, want := requests[len(requests)-1].Method, "PUT"; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+
+		_, err = querier.QueryWithOptions(ctx, "%cluster1", QueryOptions{Method: "POST"})
+		ensureError(t, err, "unsupported method")
+	})
+
+	t.Run("QueryUnion", func(t *testing.T) {
+		values, err := querier.QueryUnion(ctx, []string{"%cluster1", "%cluster4"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ensureStringSlicesMatch(t, values, []string{"host1", "host2", "host3"})
+
+		_, err = querier.QueryUnion(ctx, []string{"%cluster1", "%cluster2"})
+		if got, want := errors.Is(err, refused), true; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("ClustersOf", func(t *testing.T) {
+		clusters, err := querier.ClustersOf(ctx, "host1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		ensureStringSlicesMatch(t, clusters, []string{"cluster1"})
+
+		_, err = querier.ClustersOf(ctx, "")
+		ensureError(t, err, "empty host")
+	})
+
+	t.Run("KeysOf", func(t *testing.T) {
+		keys, err := querier.KeysOf(ctx, "%cluster1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		ensureStringSlicesMatch(t, keys, []string{"CLUSTER", "OWNER"})
+
+		_, err = querier.KeysOf(ctx, "cluster1,cluster2")
+		ensureError(t, err, "invalid name")
+	})
+
+	mock.AssertQueried(t, "%cluster1")
+	mock.AssertNotQueried(t, "%cluster3")
+}
@@ -0,0 +1,447 @@
+package orange
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestMockClient(t *testing.T) {
+	refused := errors.New("connection refused")
+
+	client, err := NewMockClient(&MockConfig{
+		Queries: map[string]MockResult{
+			"%cluster1":   {Results: []string{"host1", "host2"}},
+			"%cluster2":   {Err: refused},
+			"%empty":      {},
+			"a b&c=%d+/e": {Results: []string{"escaped"}},
+		},
+		Results: []string{"default"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("query", func(t *testing.T) {
+		values, err := client.Query("%cluster1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		ensureStringSlicesMatch(t, values, []string{"host1", "host2"})
+	})
+
+	t.Run("error", func(t *testing.T) {
+		_, err := client.Query("%cluster2")
+		if got, want := errors.Is(err, refused), true; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		values, err := client.Query("%empty")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := len(values), 0; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("escaped expression", func(t *testing.T) {
+		values, err := client.Query("a b&c=%d+/e")
+		if err != nil {
+			t.Fatal(err)
+		}
+		ensureStringSlicesMatch(t, values, []string{"escaped"})
+	})
+
+	t.Run("default results", func(t *testing.T) {
+		values, err := client.Query("%other")
+		if err != nil {
+			t.Fatal(err)
+		}
+		ensureStringSlicesMatch(t, values, []string{"default"})
+	})
+}
+
+func TestMockClientCallback(t *testing.T) {
+	var expressions []string
+
+	client, err := NewMockClient(&MockConfig{
+		Callback: func(expression string) ([]string, error) {
+			expressions = append(expressions, expression)
+			return []string{expression + "-result"}, nil
+		},
+		Queries: map[string]MockResult{"%canned": {Results: []string{"canned"}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	values, err := client.Query("%canned")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ensureStringSlicesMatch(t, values, []string{"canned"})
+
+	values, err = client.Query("%other")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ensureStringSlicesMatch(t, values, []string{"%other-result"})
+	ensureStringSlicesMatch(t, expressions, []string{"%other"})
+}
+
+// recordingT is a MockT that records reported errors.
+type recordingT struct {
+	errors []string
+}
+
+func (r *recordingT) Helper() {}
+
+func (r *recordingT) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func TestMockRequests(t *testing.T) {
+	mock := &MockConfig{Results: []string{"host1"}}
+
+	client, err := NewClient(&Config{
+		HTTPClient: mock,
+		Servers:    []string{"range.example.com"},
+		UserAgent:  "custom-user-agent",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = client.Query("%cluster1"); err != nil {
+		t.Fatal(err)
+	}
+
+	requests := mock.Requests()
+	if got, want := len(requests), 1; got != want {
+		t.Fatalf("GOT: %v; WANT: %v", got, want)
+	}
+	request := requests[0]
+	if got, want := request.Method, http.MethodGet; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+	if got, want := request.URL, "http://range.example.com/range/list?%25cluster1"; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+	if got, want := request.Expression, "%cluster1"; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+	if got, want := request.Header.Get("User-Agent"), "custom-user-agent"; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+
+	t.Run("AssertQueried", func(t *testing.T) {
+		r := new(recordingT)
+		mock.AssertQueried(r, "%cluster1")
+		if got, want := len(r.errors), 0; got != want {
+			t.Errorf("GOT: %v; WANT: %v", r.errors, want)
+		}
+		mock.AssertQueried(r, "%cluster2")
+		if got, want := len(r.errors), 1; got != want {
+			t.Errorf("GOT: %v; WANT: %v", r.errors, want)
+		}
+	})
+
+	t.Run("AssertNotQueried", func(t *testing.T) {
+		r := new(recordingT)
+		mock.AssertNotQueried(r, "%cluster2")
+		if got, want := len(r.errors), 0; got != want {
+			t.Errorf("GOT: %v; WANT: %v", r.errors, want)
+		}
+		mock.AssertNotQueried(r, "%cluster1")
+		if got, want := len(r.errors), 1; got != want {
+			t.Errorf("GOT: %v; WANT: %v", r.errors, want)
+		}
+	})
+}
+
+func TestMockRequestsBody(t *testing.T) {
+	mock := &MockConfig{}
+	client, err := NewMockClient(mock)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Long expressions are sent using PUT.
+	expression := strings.Repeat("a", defaultQueryURILengthThreshold)
+	if _, err = client.Query(expression); err != nil {
+		t.Fatal(err)
+	}
+
+	requests := mock.Requests()
+	if got, want := len(requests), 1; got != want {
+		t.Fatalf("GOT: %v; WANT: %v", got, want)
+	}
+	if got, want := requests[0].Method, http.MethodPut; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+	if got, want := string(requests[0].Body), "query="+expression; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+	if got, want := requests[0].Expression, expression; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+}
+
+func TestMockPutExpression(t *testing.T) {
+	// Long expressions are sent using PUT, and must resolve just as short
+	// expressions sent using GET do.
+	expression := "%cluster1," + strings.Repeat("a", defaultQueryURILengthThreshold)
+
+	var callbackExpressions []string
+	client, err := NewMockClient(&MockConfig{
+		Queries: map[string]MockResult{expression: {Results: []string{"canned"}}},
+		Callback: func(expression string) ([]string, error) {
+			callbackExpressions = append(callbackExpressions, expression)
+			return []string{"callback"}, nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	values, err := client.Query(expression)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ensureStringSlicesMatch(t, values, []string{"canned"})
+
+	values, err = client.Query(expression + "b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ensureStringSlicesMatch(t, values, []string{"callback"})
+	ensureStringSlicesMatch(t, callbackExpressions, []string{expression + "b"})
+}
+
+func TestMockFaultInjection(t *testing.T) {
+	t.Run("failure rate", func(t *testing.T) {
+		run := func() []bool {
+			client, err := NewMockClient(&MockConfig{FailureRate: 0.5, Seed: 42, Results: []string{"host1"}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var failures []bool
+			for i := 0; i < 100; i++ {
+				_, err := client.Query("foo")
+				if err != nil && !errors.Is(err, ErrMockFault) {
+					t.Fatal(err)
+				}
+				failures = append(failures, err != nil)
+			}
+			return failures
+		}
+
+		first, second := run(), run()
+		var count int
+		for i := range first {
+			if first[i] != second[i] {
+				t.Fatalf("GOT: %v; WANT: %v", second, first)
+			}
+			if first[i] {
+				count++
+			}
+		}
+		if count < 25 || count > 75 {
+			t.Errorf("GOT: %v; WANT: about 50 failures", count)
+		}
+	})
+
+	t.Run("retried", func(t *testing.T) {
+		if got, want := IsRetryable(ErrMockFault), true; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("jitter", func(t *testing.T) {
+		mock := &MockConfig{JitterMin: 5 * time.Millisecond, JitterMax: 10 * time.Millisecond}
+		for i := 0; i < 10; i++ {
+			delay, fail := mock.noise()
+			if delay < 5*time.Millisecond || delay > 10*time.Millisecond {
+				t.Errorf("GOT: %v; WANT: between 5ms and 10ms", delay)
+			}
+			if fail {
+				t.Errorf("GOT: %v; WANT: %v", fail, false)
+			}
+		}
+	})
+}
+
+func TestMockTimeDelay(t *testing.T) {
+	t.Run("context", func(t *testing.T) {
+		client, err := NewMockClient(&MockConfig{TimeDelay: time.Hour})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		start := time.Now()
+		_, err = client.QueryCtx(ctx, "foo")
+		if got, want := errors.Is(err, context.DeadlineExceeded), true; got != want {
+			t.Errorf("GOT: %v; WANT: %v", err, want)
+		}
+		if elapsed := time.Since(start); elapsed > time.Minute {
+			t.Errorf("GOT: %v; WANT: less than %v", elapsed, time.Minute)
+		}
+	})
+
+	t.Run("virtual clock", func(t *testing.T) {
+		clock := NewVirtualClock(time.Time{})
+		client, err := NewMockClient(&MockConfig{Clock: clock, TimeDelay: time.Hour, Results: []string{"host1"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		values, err := client.Query("foo")
+		if err != nil {
+			t.Fatal(err)
+		}
+		ensureStringSlicesMatch(t, values, []string{"host1"})
+		if got, want := clock.Elapsed(), time.Hour; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+}
+
+func TestMockFixtures(t *testing.T) {
+	fixtures := fstest.MapFS{
+		MockFixtureName("%cluster1"):      {Data: []byte("host1\r\nhost2\n\nhost3\n")},
+		MockFixtureName("%a/b"):           {Data: []byte("slashed\n")},
+		MockFixtureName("%overridden"):    {Data: []byte("fixture\n")},
+		MockFixtureName("%empty-fixture"): {Data: nil},
+	}
+
+	client, err := NewMockClient(&MockConfig{
+		Fixtures: fixtures,
+		Queries:  map[string]MockResult{"%overridden": {Results: []string{"canned"}}},
+		Results:  []string{"default"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string][]string{
+		"%cluster1":      {"host1", "host2", "host3"},
+		"%a/b":           {"slashed"},
+		"%overridden":    {"canned"},
+		"%empty-fixture": nil,
+		"%missing":       {"default"},
+	}
+	for expression, want := range cases {
+		values, err := client.Query(expression)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ensureStringSlicesMatch(t, values, want)
+	}
+}
+
+func TestMockCountsConcurrent(t *testing.T) {
+	mock := &MockConfig{Results: []string{"host1"}}
+	client, err := NewMockClient(mock)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	const workers, queries = 8, 25
+	for i := 0; i < workers; i++ {
+		go func(i int) {
+			for j := 0; j < queries; j++ {
+				_, _ = client.Query(fmt.Sprintf("%%cluster%d", i%2))
+			}
+		}(i)
+	}
+
+	if err := mock.WaitForCount(ctx, "%cluster0", workers/2*queries); err != nil {
+		t.Fatal(err)
+	}
+	if err := mock.WaitForCount(ctx, "%cluster1", workers/2*queries); err != nil {
+		t.Fatal(err)
+	}
+
+	mock.AssertCount(t, "%cluster0", workers/2*queries)
+	if got, want := mock.TotalCount(), workers*queries; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+
+	t.Run("AssertCount", func(t *testing.T) {
+		r := new(recordingT)
+		mock.AssertCount(r, "%cluster0", 1)
+		if got, want := len(r.errors), 1; got != want {
+			t.Errorf("GOT: %v; WANT: %v", r.errors, want)
+		}
+	})
+
+	t.Run("context done", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		err := mock.WaitForCount(ctx, "%never", 1)
+		if got, want := errors.Is(err, context.DeadlineExceeded), true; got != want {
+			t.Errorf("GOT: %v; WANT: %v", err, want)
+		}
+	})
+}
+
+func TestMockRangeException(t *testing.T) {
+	mock := &MockConfig{
+		Queries: map[string]MockResult{
+			"%good":  {Results: []string{"host1"}},
+			"%bogus": {RangeException: "NO SUCH CLUSTER: bogus", ExceptionBody: "details"},
+		},
+		Callback: func(expression string) ([]string, error) {
+			return nil, &ErrRangeException{Message: "callback exception"}
+		},
+	}
+
+	check := func(t *testing.T, err error, message, body string) {
+		t.Helper()
+		var rangeException *ErrRangeException
+		if !errors.As(err, &rangeException) {
+			t.Fatalf("GOT: %v; WANT: %T", err, rangeException)
+		}
+		if got, want := rangeException.Message, message; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := string(rangeException.Body), body; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	}
+
+	t.Run("client", func(t *testing.T) {
+		client, err := NewMockClient(mock)
+		if err != nil {
+			t.Fatal(err)
+		}
+		values, err := client.Query("%good")
+		if err != nil {
+			t.Fatal(err)
+		}
+		ensureStringSlicesMatch(t, values, []string{"host1"})
+
+		_, err = client.Query("%bogus")
+		check(t, err, "NO SUCH CLUSTER: bogus", "details")
+
+		_, err = client.Query("%other")
+		check(t, err, "callback exception", "")
+	})
+
+	t.Run("querier", func(t *testing.T) {
+		_, err := NewMockQuerier(mock).Query("%bogus")
+		check(t, err, "NO SUCH CLUSTER: bogus", "details")
+	})
+}
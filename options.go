@@ -0,0 +1,54 @@
+package orange
+
+import "time"
+
+// Option configures a Client created by NewClientOpts.  Because an Option
+// modifies the Config from which the Client is created, any Config field can be
+// set by a custom Option.
+//
+//     withCache := func(ttl time.Duration) orange.Option {
+//         return func(config *orange.Config) { config.CacheTTL = ttl }
+//     }
+type Option func(*Config)
+
+// NewClientOpts returns a new instance that sends queries to the specified
+// range servers, configured by opts, which are applied in order.
+//
+//     client, err := orange.NewClientOpts([]string{"range1:8081", "range2:8081"},
+//         orange.WithRetryCount(2),
+//         orange.WithUserAgent("inventory-sync/1.4"),
+//     )
+//
+// It is equivalent to NewClient with a Config whose Servers are servers, and
+// whose other fields are set by opts.
+func NewClientOpts(servers []string, opts ...Option) (*Client, error) {
+	config := &Config{Servers: servers}
+	for _, opt := range opts {
+		opt(config)
+	}
+	return NewClient(config)
+}
+
+// WithHTTPClient returns an Option that sets Config.HTTPClient, the Doer used
+// to send requests.
+func WithHTTPClient(doer Doer) Option {
+	return func(config *Config) { config.HTTPClient = doer }
+}
+
+// WithRetryCount returns an Option that sets Config.RetryCount, the number of
+// times a failed query is retried.
+func WithRetryCount(count int) Option {
+	return func(config *Config) { config.RetryCount = count }
+}
+
+// WithRetryPause returns an Option that sets Config.RetryPause, the amount of
+// time to wait before retrying a query.
+func WithRetryPause(pause time.Duration) Option {
+	return func(config *Config) { config.RetryPause = pause }
+}
+
+// WithUserAgent returns an Option that sets Config.UserAgent, the User-Agent
+// header sent with each request.
+func WithUserAgent(userAgent string) Option {
+	return func(config *Config) { config.UserAgent = userAgent }
+}
@@ -0,0 +1,43 @@
+package orange
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewClientOpts(t *testing.T) {
+	withTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if got, want := r.Header.Get("User-Agent"), "inventory-sync/1.4"; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		_, _ = w.Write([]byte("host1\n"))
+	}, func(server *httptest.Server) {
+		client, err := NewClientOpts([]string{strings.TrimPrefix(server.URL, "http://")},
+			WithHTTPClient(server.Client()),
+			WithRetryCount(2),
+			WithRetryPause(time.Second),
+			WithUserAgent("inventory-sync/1.4"),
+		)
+		ensureError(t, err)
+
+		config := client.ConfigSnapshot()
+		if got, want := config.RetryCount, 2; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := config.RetryPause, time.Second; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+
+		values, err := client.Query("%someCluster")
+		ensureError(t, err)
+		ensureStringSlicesMatch(t, values, []string{"host1"})
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := NewClientOpts([]string{"range:8081"}, WithRetryCount(-1))
+		ensureError(t, err, "negative RetryCount")
+	})
+}
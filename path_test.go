@@ -0,0 +1,33 @@
+package orange
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPath(t *testing.T) {
+	withTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if got, want := r.URL.Path, "/v1/range/list"; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		_, _ = w.Write([]byte("host1\n"))
+	}, func(server *httptest.Server) {
+		client, err := NewClient(&Config{
+			HTTPClient: server.Client(),
+			Path:       "/v1/range/list",
+			Servers:    []string{strings.TrimPrefix(server.URL, "http://")},
+		})
+		ensureError(t, err)
+
+		values, err := client.Query("%someCluster")
+		ensureError(t, err)
+		ensureStringSlicesMatch(t, values, []string{"host1"})
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := NewClient(&Config{Path: "range/list", Servers: []string{"range:8081"}})
+		ensureError(t, err, "not starting with a slash")
+	})
+}
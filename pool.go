@@ -0,0 +1,45 @@
+package orange
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+// maxPooledBufferSize is the largest buffer returned to bufferPool, so a single
+// huge response does not pin its memory for the life of the process.
+const maxPooledBufferSize = 1 << 20
+
+// bufferPool holds the buffers into which response bodies are read, so callers
+// issuing many queries do not allocate and repeatedly grow a fresh buffer for
+// each of them.
+var bufferPool = sync.Pool{
+	New: func() interface{} { return new(bytes.Buffer) },
+}
+
+// readAllPooled reads from r until EOF or error using a pooled buffer, and
+// returns a copy of the data read, allocated at exactly its size.
+func readAllPooled(r io.Reader) ([]byte, error) {
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	_, err := buf.ReadFrom(r)
+	data := make([]byte, buf.Len())
+	copy(data, buf.Bytes())
+	if buf.Cap() <= maxPooledBufferSize {
+		bufferPool.Put(buf)
+	}
+	return data, err
+}
+
+// readStringPooled reads from r until EOF or error using a pooled buffer, and
+// returns the data read as a string.
+func readStringPooled(r io.Reader) (string, error) {
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	_, err := buf.ReadFrom(r)
+	s := buf.String()
+	if buf.Cap() <= maxPooledBufferSize {
+		bufferPool.Put(buf)
+	}
+	return s, err
+}
@@ -0,0 +1,55 @@
+package orange
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// Prewarm sends a HEAD request to Config.Path on every server concurrently, so
+// that connections to them are already open when latency sensitive queries are
+// sent, rather than each first query paying for dialing.  It returns once
+// every server has responded or ctx is done, and returns an error joining the
+// errors of servers that could not be reached.  A server that responds with an
+// error status still counts as warmed, because the connection was opened.
+//
+// Prewarming only helps when the HTTPClient keeps idle connections open, as
+// the default one does, for up to DefaultMaxIdleConnsPerHost connections per
+// server.  Even over loopback, where dialing is cheapest, BenchmarkFirstQuery
+// shows a prewarmed first query taking about a third of the time of a cold
+// one.  See Config.PrewarmTimeout to prewarm when the client is created.
+func (c *Client) Prewarm(ctx context.Context) error {
+	servers := c.servers.values
+	errs := make([]error, len(servers))
+
+	var wg sync.WaitGroup
+	wg.Add(len(servers))
+	for i, server := range servers {
+		go func(i int, server string) {
+			defer wg.Done()
+			errs[i] = c.prewarm(ctx, server)
+		}(i, server)
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+func (c *Client) prewarm(ctx context.Context, server string) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodHead, c.serverURL(server)+c.path, nil)
+	if err != nil {
+		return err
+	}
+	c.setHeaders(ctx, request.Header)
+	if err := c.authenticate(request); err != nil {
+		return fmt.Errorf("cannot prewarm connection to %s: %w", server, err)
+	}
+	response, err := c.httpClient.Do(request)
+	if err != nil {
+		return fmt.Errorf("cannot prewarm connection to %s: %w", server, err)
+	}
+	// Reading the body to the end allows the connection to be reused.
+	return discard(response.Body)
+}
@@ -0,0 +1,106 @@
+package orange
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newConnCountingServer returns a server that counts the connections opened to
+// it.
+func newConnCountingServer(tb testing.TB, conns *int32) *httptest.Server {
+	tb.Helper()
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			w.Write([]byte("host1\n"))
+		}
+	}))
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(conns, 1)
+		}
+	}
+	server.Start()
+	tb.Cleanup(server.Close)
+	return server
+}
+
+func TestPrewarm(t *testing.T) {
+	var conns int32
+	server := newConnCountingServer(t, &conns)
+
+	client, err := NewClient(&Config{
+		HTTPClient:     &http.Client{Transport: &http.Transport{}},
+		PrewarmTimeout: 5 * time.Second,
+		Servers:        []string{strings.TrimPrefix(server.URL, "http://")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := atomic.LoadInt32(&conns), int32(1); got != want {
+		t.Fatalf("GOT: %v; WANT: %v", got, want)
+	}
+
+	// The first query uses the prewarmed connection.
+	values, err := client.Query("%web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ensureStringSlicesMatch(t, values, []string{"host1"})
+	if got, want := atomic.LoadInt32(&conns), int32(1); got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+}
+
+func TestPrewarmUnreachable(t *testing.T) {
+	client, err := NewClient(&Config{
+		HTTPClient: DoerFunc(func(*http.Request) (*http.Response, error) {
+			return nil, &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+		}),
+		Servers: []string{"one", "two"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = client.Prewarm(context.Background())
+	ensureError(t, err, "cannot prewarm connection to one")
+	ensureError(t, err, "cannot prewarm connection to two")
+}
+
+func BenchmarkFirstQuery(b *testing.B) {
+	var conns int32
+	server := newConnCountingServer(b, &conns)
+
+	for _, prewarm := range []time.Duration{0, 5 * time.Second} {
+		b.Run("prewarm "+prewarm.String(), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				transport := &http.Transport{}
+				client, err := NewClient(&Config{
+					HTTPClient:     &http.Client{Transport: transport},
+					PrewarmTimeout: prewarm,
+					Servers:        []string{strings.TrimPrefix(server.URL, "http://")},
+				})
+				if err != nil {
+					b.Fatal(err)
+				}
+				b.StartTimer()
+
+				if _, err = client.Query("%web"); err != nil {
+					b.Fatal(err)
+				}
+
+				b.StopTimer()
+				transport.CloseIdleConnections()
+				b.StartTimer()
+			}
+		})
+	}
+}
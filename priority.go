@@ -0,0 +1,155 @@
+package orange
+
+import (
+	"container/heap"
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+// Priority is the urgency of a query.  Queries waiting for
+// Config.MaxConcurrentQueries are sent in order of decreasing priority, so
+// interactive lookups are not starved by background bulk jobs.  Higher values
+// are more urgent.
+type Priority int
+
+// Priorities of queries.  Queries not given a priority have PriorityNormal.
+const (
+	PriorityBackground  Priority = -1
+	PriorityNormal      Priority = 0
+	PriorityInteractive Priority = 1
+)
+
+// priorityHeader is the request header that tells range servers the priority of
+// a query, for servers that schedule their own work.
+const priorityHeader = "X-Range-Priority"
+
+// priorityKey is the context key of a query priority.
+type priorityKey struct{}
+
+// WithQueryPriority returns a copy of ctx that causes queries sent with it to
+// have the specified priority.  The priority orders queries waiting for
+// Config.MaxConcurrentQueries, and is sent to range servers in the
+// X-Range-Priority header.
+//
+//     ctx = orange.WithQueryPriority(ctx, orange.PriorityInteractive)
+//     values, err := client.QueryCtx(ctx, "%someCluster")
+func WithQueryPriority(ctx context.Context, priority Priority) context.Context {
+	return context.WithValue(ctx, priorityKey{}, priority)
+}
+
+// queryPriority returns the priority of queries sent with ctx, and whether one
+// was given.
+func queryPriority(ctx context.Context) (Priority, bool) {
+	priority, ok := ctx.Value(priorityKey{}).(Priority)
+	return priority, ok
+}
+
+// setPriorityHeader sets the priority header of a request sent with ctx, when
+// the query was given a priority.
+func setPriorityHeader(ctx context.Context, header http.Header) {
+	if priority, ok := queryPriority(ctx); ok {
+		header.Set(priorityHeader, strconv.Itoa(int(priority)))
+	}
+}
+
+// concurrencyLimiter limits the number of queries sent at once, admitting
+// waiting queries in order of decreasing priority, and in the order they
+// arrived when their priorities are equal.
+type concurrencyLimiter struct {
+	lock    sync.Mutex
+	limit   int
+	active  int
+	seq     uint64
+	waiters waiterHeap
+}
+
+func newConcurrencyLimiter(limit int) *concurrencyLimiter {
+	return &concurrencyLimiter{limit: limit}
+}
+
+// acquire blocks until the caller may send a query with the given priority,
+// or until ctx is done, in which case it returns ctx.Err().  Each successful
+// acquire must be followed by a release.
+func (l *concurrencyLimiter) acquire(ctx context.Context, priority Priority) error {
+	l.lock.Lock()
+	if l.active < l.limit {
+		l.active++
+		l.lock.Unlock()
+		return nil
+	}
+	w := &waiter{priority: priority, seq: l.seq, ready: make(chan struct{})}
+	l.seq++
+	heap.Push(&l.waiters, w)
+	l.lock.Unlock()
+
+	select {
+	case <-w.ready:
+		return nil
+	case <-ctx.Done():
+		l.lock.Lock()
+		if w.index < 0 {
+			// The slot was handed over while ctx was done, so pass it on.
+			l.lock.Unlock()
+			l.release()
+		} else {
+			heap.Remove(&l.waiters, w.index)
+			l.lock.Unlock()
+		}
+		return ctx.Err()
+	}
+}
+
+// release ends a query, handing its slot to the most urgent waiting query.
+func (l *concurrencyLimiter) release() {
+	l.lock.Lock()
+	if len(l.waiters) > 0 {
+		w := heap.Pop(&l.waiters).(*waiter)
+		close(w.ready)
+	} else {
+		l.active--
+	}
+	l.lock.Unlock()
+}
+
+// waiter is a query waiting for a concurrencyLimiter.
+type waiter struct {
+	priority Priority
+	seq      uint64
+	index    int // index in waiterHeap, or -1 once admitted
+	ready    chan struct{}
+}
+
+// waiterHeap is a heap of waiters, with the most urgent first.
+type waiterHeap []*waiter
+
+func (h waiterHeap) Len() int { return len(h) }
+
+func (h waiterHeap) Less(i, j int) bool {
+	if h[i].priority != h[j].priority {
+		return h[i].priority > h[j].priority
+	}
+	return h[i].seq < h[j].seq
+}
+
+func (h waiterHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *waiterHeap) Push(x interface{}) {
+	w := x.(*waiter)
+	w.index = len(*h)
+	*h = append(*h, w)
+}
+
+func (h *waiterHeap) Pop() interface{} {
+	old := *h
+	w := old[len(old)-1]
+	old[len(old)-1] = nil
+	w.index = -1
+	*h = old[:len(old)-1]
+	return w
+}
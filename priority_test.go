@@ -0,0 +1,118 @@
+package orange
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestQueryPriorityHeader(t *testing.T) {
+	var header string
+	var present bool
+	withClient(t, func(w http.ResponseWriter, r *http.Request) {
+		_, present = r.Header[priorityHeader]
+		header = r.Header.Get(priorityHeader)
+	}, func(client *Client) {
+		_, err := client.Query("%someCluster")
+		ensureError(t, err)
+		if got, want := present, false; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+
+		ctx := WithQueryPriority(context.Background(), PriorityBackground)
+		_, err = client.QueryCtx(ctx, "%someCluster")
+		ensureError(t, err)
+		if got, want := header, "-1"; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+}
+
+func TestMaxConcurrentQueries(t *testing.T) {
+	release := make(chan struct{})
+	var lock sync.Mutex
+	var order []string
+
+	withTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		order = append(order, r.URL.RawQuery)
+		lock.Unlock()
+		if r.URL.RawQuery == "first" {
+			<-release
+		}
+	}, func(server *httptest.Server) {
+		client, err := NewClient(&Config{
+			HTTPClient:           server.Client(),
+			MaxConcurrentQueries: 1,
+			Servers:              []string{strings.TrimPrefix(server.URL, "http://")},
+		})
+		ensureError(t, err)
+
+		waiters := func() int {
+			client.concurrency.lock.Lock()
+			defer client.concurrency.lock.Unlock()
+			return len(client.concurrency.waiters)
+		}
+		waitFor := func(n int) {
+			for waiters() != n {
+				time.Sleep(time.Millisecond)
+			}
+		}
+
+		var wg sync.WaitGroup
+		query := func(priority Priority, expression string) {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := client.QueryCtx(WithQueryPriority(context.Background(), priority), expression)
+				ensureError(t, err)
+			}()
+		}
+
+		query(PriorityNormal, "first")
+		for len(orderSnapshot(&lock, &order)) != 1 {
+			time.Sleep(time.Millisecond)
+		}
+		query(PriorityBackground, "background1")
+		waitFor(1)
+		query(PriorityNormal, "normal")
+		waitFor(2)
+		query(PriorityBackground, "background2")
+		waitFor(3)
+		query(PriorityInteractive, "interactive")
+		waitFor(4)
+
+		t.Run("canceled while waiting", func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan error)
+			go func() {
+				_, err := client.QueryCtx(ctx, "canceled")
+				done <- err
+			}()
+			waitFor(5)
+			cancel()
+			if got, want := <-done, context.Canceled; !errors.Is(got, want) {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+			if got, want := waiters(), 4; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+		})
+
+		close(release)
+		wg.Wait()
+
+		ensureStringSlicesMatch(t, order, []string{"first", "interactive", "normal", "background1", "background2"})
+	})
+}
+
+func orderSnapshot(lock *sync.Mutex, order *[]string) []string {
+	lock.Lock()
+	defer lock.Unlock()
+	return append([]string(nil), *order...)
+}
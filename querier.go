@@ -0,0 +1,41 @@
+package orange
+
+import (
+	"context"
+	"io"
+)
+
+// Querier resolves range queries.  Code that queries range can accept a
+// Querier rather than a *Client, so tests and alternative implementations can
+// be injected in its place.  *Client, including one returned by
+// NewMockClient, *MockQuerier, and *LocalQuerier all satisfy it.
+//
+//     type Inventory struct {
+//         Range orange.Querier
+//     }
+//
+//     func (i *Inventory) WebServers(ctx context.Context) ([]string, error) {
+//         return i.Range.QueryCtx(ctx, "%webservers")
+//     }
+type Querier interface {
+	// Query returns the results of expression.
+	Query(expression string) ([]string, error)
+
+	// QueryCtx returns the results of expression, using ctx to cancel the
+	// query.
+	QueryCtx(ctx context.Context, expression string) ([]string, error)
+
+	// QueryCallback invokes callback with an io.Reader of the results of
+	// expression, one per line.
+	QueryCallback(ctx context.Context, expression string, callback func(io.Reader) error) error
+
+	// Expand returns the values of expression folded into compact range
+	// notation.
+	Expand(ctx context.Context, expression string) (string, error)
+}
+
+var (
+	_ Querier = (*Client)(nil)
+	_ Querier = (*LocalQuerier)(nil)
+	_ Querier = (*MockQuerier)(nil)
+)
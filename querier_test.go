@@ -0,0 +1,71 @@
+package orange
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"testing"
+	"testing/fstest"
+)
+
+// webServers stands in for code that accepts a Querier rather than a *Client.
+func webServers(ctx context.Context, querier Querier) ([]string, string, error) {
+	values, err := querier.QueryCtx(ctx, "%web")
+	if err != nil {
+		return nil, "", err
+	}
+
+	var body []byte
+	err = querier.QueryCallback(ctx, "%web", func(ior io.Reader) error {
+		body, err = io.ReadAll(ior)
+		return err
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	if got, want := string(body), joinLines(values); got != want {
+		return nil, "", fmt.Errorf("callback body %q differs from results %q", got, want)
+	}
+
+	expansion, err := querier.Expand(ctx, "%web")
+	return values, expansion, err
+}
+
+func TestQuerier(t *testing.T) {
+	mock := &MockConfig{Queries: map[string]MockResult{
+		"%web": {Results: []string{"web1", "web2"}},
+	}}
+	client, err := NewMockClient(mock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	local, err := NewLocalQuerier(fstest.MapFS{
+		"clusters/web.yaml": {Data: []byte("CLUSTER: [web1, web2]\n")},
+	}, "clusters")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	queriers := []struct {
+		name      string
+		querier   Querier
+		expansion string
+	}{
+		{"Client", client, "web1,web2"},
+		{"MockQuerier", NewMockQuerier(mock), "web1,web2"},
+		{"LocalQuerier", local, "web1..2"},
+	}
+
+	for _, q := range queriers {
+		t.Run(q.name, func(t *testing.T) {
+			values, expansion, err := webServers(context.Background(), q.querier)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ensureStringSlicesMatch(t, values, []string{"web1", "web2"})
+			if got, want := expansion, q.expansion; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+		})
+	}
+}
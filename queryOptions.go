@@ -0,0 +1,94 @@
+package orange
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// QueryOptions overrides client settings for a single query sent by
+// QueryWithOptions, for clients serving mixed workloads, such as interactive
+// lookups and batch jobs.  Each field left at its zero value uses the client
+// setting.
+type QueryOptions struct {
+	// Timeout, when greater than 0, limits how long the query may take,
+	// including all retries.
+	Timeout time.Duration
+
+	// Server, when not empty, is the only range server the query is sent to,
+	// including all retries.  It need not be one of Config.Servers.
+	Server string
+
+	// RetryCount, when greater than 0, overrides Config.RetryCount.  NoRetry
+	// prevents the query from being retried.
+	RetryCount int
+	NoRetry    bool
+
+	// Method is the preferred HTTP method, either GET or PUT.  See
+	// Request.Method.
+	Method string
+
+	// Header holds additional headers sent with the query.  See
+	// Request.Header.
+	Header http.Header
+}
+
+// QueryWithOptions sends the query expression to the range servers with the
+// provided query context, overriding client settings as specified by options,
+// and returns the results.  Like QueryCtx, results are de-duplicated and sorted
+// when configured to, but because options change how a query is resolved, its
+// results are neither cached nor coalesced, and Interceptors are not used.
+//
+//     values, err := client.QueryWithOptions(ctx, "%someCluster", orange.QueryOptions{
+//         Timeout: 250 * time.Millisecond,
+//         NoRetry: true,
+//     })
+func (c *Client) QueryWithOptions(ctx context.Context, expression string, options QueryOptions) ([]string, error) {
+	if err := options.validate(); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := options.withTimeout(ctx)
+	defer cancel()
+
+	rr := &Request{
+		Expression: expression,
+		Method:     options.Method,
+		Header:     options.Header,
+		server:     options.Server,
+	}
+	switch {
+	case options.NoRetry:
+		rr.retryCount = new(int)
+	case options.RetryCount > 0:
+		rr.retryCount = &options.RetryCount
+	}
+
+	return c.requestLines(ctx, rr)
+}
+
+// validate returns an error when options cannot be used for a query.
+func (options QueryOptions) validate() error {
+	switch options.Method {
+	case "", http.MethodGet, http.MethodPut:
+	default:
+		return fmt.Errorf("cannot query using unsupported method: %q", options.Method)
+	}
+	if options.RetryCount < 0 {
+		return fmt.Errorf("cannot query with negative RetryCount: %d", options.RetryCount)
+	}
+	if err := validateServerURL(options.Server); err != nil {
+		return fmt.Errorf("cannot query with invalid server URL: %w", err)
+	}
+	return nil
+}
+
+// withTimeout returns ctx limited by the Timeout of options, for queriers that
+// use no other option.
+func (options QueryOptions) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if options.Timeout > 0 {
+		return context.WithTimeout(ctx, options.Timeout)
+	}
+	return ctx, func() {}
+}
@@ -0,0 +1,96 @@
+package orange
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestQueryWithOptions(t *testing.T) {
+	var requests int
+	var lastMethod, lastTeam string
+	h := func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		lastMethod, lastTeam = r.Method, r.Header.Get("X-Team")
+		switch r.FormValue("query") + r.URL.RawQuery {
+		case "fail":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case "slow":
+			<-r.Context().Done()
+		default:
+			_, _ = w.Write([]byte("host2\nhost1\n"))
+		}
+	}
+
+	withTestServer(t, h, func(server *httptest.Server) {
+		other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte("other\n"))
+		}))
+		defer other.Close()
+
+		client, err := NewClient(&Config{
+			HTTPClient:    server.Client(),
+			RetryCallback: func(error) bool { return true },
+			RetryCount:    3,
+			Servers:       []string{strings.TrimPrefix(server.URL, "http://")},
+			Sorted:        true,
+		})
+		ensureError(t, err)
+		ctx := context.Background()
+
+		t.Run("method and header", func(t *testing.T) {
+			values, err := client.QueryWithOptions(ctx, "%someCluster", QueryOptions{
+				Method: http.MethodPut,
+				Header: http.Header{"X-Team": []string{"billing"}},
+			})
+			ensureError(t, err)
+			ensureStringSlicesMatch(t, values, []string{"host1", "host2"})
+			if got, want := lastMethod, http.MethodPut; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+			if got, want := lastTeam, "billing"; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+		})
+
+		t.Run("server", func(t *testing.T) {
+			values, err := client.QueryWithOptions(ctx, "%someCluster", QueryOptions{Server: other.URL})
+			ensureError(t, err)
+			ensureStringSlicesMatch(t, values, []string{"other"})
+		})
+
+		t.Run("no retry", func(t *testing.T) {
+			requests = 0
+			_, err := client.QueryWithOptions(ctx, "fail", QueryOptions{NoRetry: true})
+			ensureError(t, err, "503")
+			if got, want := requests, 1; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+		})
+
+		t.Run("retry count", func(t *testing.T) {
+			requests = 0
+			_, err := client.QueryWithOptions(ctx, "fail", QueryOptions{RetryCount: 1})
+			ensureError(t, err, "503")
+			if got, want := requests, 2; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+		})
+
+		t.Run("timeout", func(t *testing.T) {
+			_, err := client.QueryWithOptions(ctx, "slow", QueryOptions{Timeout: 10 * time.Millisecond, NoRetry: true})
+			if got, want := err, context.DeadlineExceeded; !errors.Is(got, want) {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+		})
+
+		t.Run("invalid", func(t *testing.T) {
+			_, err := client.QueryWithOptions(ctx, "%someCluster", QueryOptions{Method: http.MethodPost})
+			ensureError(t, err, "unsupported method")
+		})
+	})
+}
@@ -0,0 +1,124 @@
+package rangetest
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Fake is a configurable http.Handler that speaks the range protocol with
+// canned responses, so other projects can stand up fake range servers in their
+// own httptest servers.
+//
+//     server := httptest.NewServer(&rangetest.Fake{
+//         Results:         map[string][]string{"%web": {"web1", "web2"}},
+//         RangeExceptions: map[string]string{"%bogus": "NO SUCH CLUSTER"},
+//         Statuses:        map[string]int{"%overloaded": http.StatusServiceUnavailable},
+//     })
+//     defer server.Close()
+//
+// Its fields must not be modified while it is serving requests.
+type Fake struct {
+	// Results maps expressions to their results.
+	Results map[string][]string
+
+	// Default are the results of expressions not found in Results.  When nil,
+	// such expressions are answered with a RangeException.
+	Default []string
+
+	// RangeExceptions maps expressions to the message of the RangeException
+	// header sent in response to them.
+	RangeExceptions map[string]string
+
+	// Statuses maps expressions to the HTTP status code sent in response to
+	// them, in place of their results.
+	Statuses map[string]int
+
+	// StatusCode, when not zero, is the HTTP status code sent in response to
+	// every query, in place of its results, such as to simulate an outage.
+	StatusCode int
+
+	// Methods are the HTTP methods accepted for queries.  Other methods are
+	// answered with 405 Method Not Allowed, such as to simulate a server that
+	// does not accept PUT.  When empty, GET, POST, and PUT are accepted.
+	Methods []string
+}
+
+// ServeHTTP answers the range query in r with the configured response.
+func (f *Fake) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	expression, ok := readQuery(w, r, f.Methods)
+	if !ok {
+		return
+	}
+
+	if f.StatusCode != 0 {
+		http.Error(w, http.StatusText(f.StatusCode), f.StatusCode)
+		return
+	}
+	if status, ok := f.Statuses[expression]; ok {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+	if message, ok := f.RangeExceptions[expression]; ok {
+		w.Header().Set("RangeException", message)
+		return
+	}
+
+	values, ok := f.Results[expression]
+	if !ok {
+		if f.Default == nil {
+			w.Header().Set("RangeException", "no results configured for expression: "+expression)
+			return
+		}
+		values = f.Default
+	}
+	writeValues(w, values)
+}
+
+// readQuery returns the range expression of r.  When r is not a range query
+// using one of the allowed methods, which default to GET, POST, and PUT, it
+// writes an error response and returns false.
+func readQuery(w http.ResponseWriter, r *http.Request, methods []string) (string, bool) {
+	if r.URL.Path != "/range/list" {
+		http.NotFound(w, r)
+		return "", false
+	}
+
+	if len(methods) == 0 {
+		methods = []string{http.MethodGet, http.MethodPost, http.MethodPut}
+	}
+	var allowed bool
+	for _, method := range methods {
+		if r.Method == method {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		w.Header().Set("Allow", strings.Join(methods, ", "))
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return "", false
+	}
+
+	if r.Method == http.MethodGet {
+		expression, err := url.QueryUnescape(r.URL.RawQuery)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return "", false
+		}
+		return expression, true
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return "", false
+	}
+	return r.PostForm.Get("query"), true
+}
+
+// writeValues writes values as the body of a range response, one per line.
+func writeValues(w http.ResponseWriter, values []string) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if len(values) > 0 {
+		_, _ = w.Write([]byte(strings.Join(values, "\n") + "\n"))
+	}
+}
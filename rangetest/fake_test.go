@@ -0,0 +1,123 @@
+package rangetest
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/karrick/orange"
+)
+
+func newFakeClient(tb testing.TB, fake *Fake) *orange.Client {
+	tb.Helper()
+	server := httptest.NewServer(fake)
+	tb.Cleanup(server.Close)
+
+	client, err := orange.NewClient(&orange.Config{
+		HTTPClient: server.Client(),
+		Servers:    []string{strings.TrimPrefix(server.URL, "http://")},
+	})
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return client
+}
+
+func TestFake(t *testing.T) {
+	client := newFakeClient(t, &Fake{
+		Results:         map[string][]string{"%web": {"web1", "web2"}},
+		RangeExceptions: map[string]string{"%bogus": "NO SUCH CLUSTER"},
+		Statuses:        map[string]int{"%overloaded": http.StatusServiceUnavailable},
+	})
+
+	t.Run("results", func(t *testing.T) {
+		values, err := client.Query("%web")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := strings.Join(values, ","), "web1,web2"; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("range exception", func(t *testing.T) {
+		_, err := client.Query("%bogus")
+		var rangeException *orange.ErrRangeException
+		if !errors.As(err, &rangeException) {
+			t.Fatalf("GOT: %v; WANT: %T", err, rangeException)
+		}
+		if got, want := rangeException.Message, "NO SUCH CLUSTER"; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("status", func(t *testing.T) {
+		_, err := client.Query("%overloaded")
+		var statusNotOK *orange.ErrStatusNotOK
+		if !errors.As(err, &statusNotOK) {
+			t.Fatalf("GOT: %v; WANT: %T", err, statusNotOK)
+		}
+		if got, want := statusNotOK.StatusCode, http.StatusServiceUnavailable; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("not configured", func(t *testing.T) {
+		_, err := client.Query("%other")
+		if got, want := orange.IsRangeException(err), true; got != want {
+			t.Errorf("GOT: %v; WANT: %v", err, want)
+		}
+	})
+}
+
+func TestFakeDefaultAndStatusCode(t *testing.T) {
+	fake := &Fake{Default: []string{"default"}}
+	client := newFakeClient(t, fake)
+
+	values, err := client.Query("%anything")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.Join(values, ","), "default"; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+
+	client = newFakeClient(t, &Fake{Default: []string{"default"}, StatusCode: http.StatusBadGateway})
+	_, err = client.Query("%anything")
+	if got, want := orange.IsStatusNotOK(err), true; got != want {
+		t.Errorf("GOT: %v; WANT: %v", err, want)
+	}
+}
+
+func TestFakeMethods(t *testing.T) {
+	// A server that does not accept PUT causes the client to fall back to GET
+	// for long expressions.
+	var methods []string
+	fake := &Fake{Default: []string{"host1"}, Methods: []string{http.MethodGet}}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		methods = append(methods, r.Method)
+		fake.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	client, err := orange.NewClient(&orange.Config{
+		HTTPClient: server.Client(),
+		Servers:    []string{strings.TrimPrefix(server.URL, "http://")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	values, err := client.Query(strings.Repeat("a", 5000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.Join(values, ","), "host1"; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+	if got, want := strings.Join(methods, ","), "PUT,GET"; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+}
@@ -0,0 +1,80 @@
+package rangetest
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/karrick/orange"
+)
+
+// UpdateGoldenEnv names the environment variable that, when set to a non-empty
+// value, causes Golden to rewrite its golden files with the current results
+// rather than comparing against them.
+const UpdateGoldenEnv = "RANGETEST_UPDATE_GOLDEN"
+
+// Golden snapshots the results of each expression to a golden file in dir, and
+// on subsequent runs reports any hosts added to or removed from those results
+// as test errors, so teams can detect unexpected range data drift in tests
+// they own.
+//
+//     func TestRangeDrift(t *testing.T) {
+//         client, err := orange.NewClient(&orange.Config{Servers: servers})
+//         if err != nil {
+//             t.Fatal(err)
+//         }
+//         rangetest.Golden(t, client.Query, "testdata/golden", "%web", "%db")
+//     }
+//
+// A missing golden file is written with the current results.  Run the test
+// with the RANGETEST_UPDATE_GOLDEN environment variable set to accept changed
+// results.  Golden files hold one result per line, in natural sort order, and
+// are named by orange.MockFixtureName, so the same directory can provide
+// MockConfig.Fixtures.
+func Golden(tb testing.TB, query func(expression string) ([]string, error), dir string, expressions ...string) {
+	tb.Helper()
+	update := os.Getenv(UpdateGoldenEnv) != ""
+
+	for _, expression := range expressions {
+		values, err := query(expression)
+		if err != nil {
+			tb.Errorf("cannot query %q: %s", expression, err)
+			continue
+		}
+		values = append([]string(nil), values...)
+		orange.SortHosts(values)
+
+		name := filepath.Join(dir, orange.MockFixtureName(expression))
+		buf, err := os.ReadFile(name)
+		if update || errors.Is(err, fs.ErrNotExist) {
+			if err = writeGolden(name, values); err != nil {
+				tb.Fatalf("cannot write golden file for %q: %s", expression, err)
+			}
+			tb.Logf("wrote golden file for %q: %s", expression, name)
+			continue
+		}
+		if err != nil {
+			tb.Fatalf("cannot read golden file for %q: %s", expression, err)
+		}
+
+		golden := (&orange.Response{Body: buf}).Split()
+		added, removed := orange.Diff(golden, values)
+		if len(added) > 0 || len(removed) > 0 {
+			tb.Errorf("%q drifted from %s: added: %v; removed: %v", expression, name, added, removed)
+		}
+	}
+}
+
+func writeGolden(name string, values []string) error {
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return err
+	}
+	var data string
+	if len(values) > 0 {
+		data = strings.Join(values, "\n") + "\n"
+	}
+	return os.WriteFile(name, []byte(data), 0o644)
+}
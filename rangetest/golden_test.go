@@ -0,0 +1,76 @@
+package rangetest
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// recordingTB records the errors reported by Golden.
+type recordingTB struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recordingTB) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func (r *recordingTB) Logf(format string, args ...interface{}) {}
+
+func TestGolden(t *testing.T) {
+	dir := t.TempDir()
+	clusters := Clusters{
+		"web": {"CLUSTER": {"web10", "web9", "web1"}},
+	}
+	client := newClient(t, clusters)
+
+	// The first run writes the golden file.
+	r := &recordingTB{TB: t}
+	Golden(r, client.Query, dir, "%web")
+	if got, want := len(r.errors), 0; got != want {
+		t.Fatalf("GOT: %v; WANT: %v", r.errors, want)
+	}
+	buf, err := os.ReadFile(filepath.Join(dir, "%25web"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(buf), "web1\nweb9\nweb10\n"; got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+
+	// An unchanged run reports nothing.
+	r = &recordingTB{TB: t}
+	Golden(r, client.Query, dir, "%web")
+	if got, want := len(r.errors), 0; got != want {
+		t.Fatalf("GOT: %v; WANT: %v", r.errors, want)
+	}
+
+	// Drift is reported.
+	clusters["web"]["CLUSTER"] = []string{"web1", "web9", "web11"}
+	r = &recordingTB{TB: t}
+	Golden(r, client.Query, dir, "%web")
+	if got, want := len(r.errors), 1; got != want {
+		t.Fatalf("GOT: %v; WANT: %v", r.errors, want)
+	}
+	if got, want := r.errors[0], "added: [web11]; removed: [web10]"; !strings.Contains(got, want) {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+
+	// Updating accepts the drift.
+	t.Setenv(UpdateGoldenEnv, "1")
+	r = &recordingTB{TB: t}
+	Golden(r, client.Query, dir, "%web")
+	if got, want := len(r.errors), 0; got != want {
+		t.Fatalf("GOT: %v; WANT: %v", r.errors, want)
+	}
+	buf, err = os.ReadFile(filepath.Join(dir, "%25web"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(buf), "web1\nweb9\nweb11\n"; got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+}
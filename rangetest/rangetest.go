@@ -0,0 +1,73 @@
+// Package rangetest provides an in-memory range server for tests, which
+// evaluates a useful subset of range syntax against cluster definitions held
+// in memory, so integration tests exercise the real client wire path against
+// realistic semantics.
+//
+//     server := rangetest.NewServer(rangetest.Clusters{
+//         "web": {"CLUSTER": {"web1..3.example.com"}},
+//         "db":  {"CLUSTER": {"db1.example.com", "db2.example.com"}},
+//     })
+//     defer server.Close()
+//
+//     client, err := orange.NewClient(&orange.Config{
+//         HTTPClient: server.Client(),
+//         Servers:    []string{strings.TrimPrefix(server.URL, "http://")},
+//     })
+//
+// Supported syntax includes unions (a,b), intersections (a,&b or a&b),
+// differences (a,-b), grouping with parentheses, cluster lookups (%cluster),
+// key lookups (%cluster:KEY), key listings (%cluster:KEYS), and the brace and
+// numeric range expansion of orange.ExpandLocal.  Expressions that cannot be
+// evaluated are answered with a RangeException header, as a range server
+// would.
+package rangetest
+
+import (
+	"context"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/karrick/orange"
+	"github.com/karrick/orange/internal/rangeexpr"
+	"github.com/karrick/orange/server"
+)
+
+// Clusters maps cluster names to their keys, and each key to its values.  The
+// CLUSTER key of a cluster holds expressions for its members.
+type Clusters = rangeexpr.Clusters
+
+// LoadClusters loads cluster definitions written in the classic range YAML
+// format from the files with a .yaml extension in directory dir of fsys, one
+// cluster per file, named for the file without its extension.
+//
+//     clusters, err := rangetest.LoadClusters(os.DirFS("testdata"), "clusters")
+func LoadClusters(fsys fs.FS, dir string) (Clusters, error) {
+	return rangeexpr.LoadClusters(fsys, dir)
+}
+
+// Handler is an http.Handler that answers range queries sent to /range/list or
+// /range/expand by evaluating them against its clusters.
+type Handler struct {
+	handler *server.Handler
+}
+
+// NewHandler returns a Handler that evaluates range queries against clusters.
+func NewHandler(clusters Clusters) *Handler {
+	evaluator := rangeexpr.Evaluator{Clusters: clusters, Expand: orange.ExpandLocal}
+	return &Handler{handler: server.New(server.BackendFunc(func(_ context.Context, expression string) ([]string, error) {
+		return evaluator.Evaluate(expression)
+	}))}
+}
+
+// NewServer starts and returns a new httptest.Server using a Handler that
+// evaluates range queries against clusters.  The caller should call Close when
+// finished, to shut it down.
+func NewServer(clusters Clusters) *httptest.Server {
+	return httptest.NewServer(NewHandler(clusters))
+}
+
+// ServeHTTP answers the range query in r, as described by package server.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.handler.ServeHTTP(w, r)
+}
@@ -0,0 +1,126 @@
+package rangetest
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/karrick/orange"
+)
+
+func newClient(tb testing.TB, clusters Clusters) *orange.Client {
+	tb.Helper()
+	server := NewServer(clusters)
+	tb.Cleanup(server.Close)
+
+	client, err := orange.NewClient(&orange.Config{
+		HTTPClient: server.Client(),
+		Servers:    []string{strings.TrimPrefix(server.URL, "http://")},
+	})
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return client
+}
+
+func TestServer(t *testing.T) {
+	client := newClient(t, Clusters{
+		"web": {"CLUSTER": {"web{1..3}.example.com"}},
+		"db":  {"CLUSTER": {"db01..02.example.com"}},
+		"all": {"CLUSTER": {"%web,%db"}},
+	})
+
+	cases := []struct {
+		expression string
+		want       []string
+	}{
+		{"%web", []string{"web1.example.com", "web2.example.com", "web3.example.com"}},
+		{"%all,&%db", []string{"db01.example.com", "db02.example.com"}},
+		{"%web,-web2.example.com", []string{"web1.example.com", "web3.example.com"}},
+		// Long expressions are sent using PUT.
+		{strings.Repeat("%db,", 2000) + "%db", []string{"db01.example.com", "db02.example.com"}},
+	}
+
+	for _, c := range cases {
+		values, err := client.Query(c.expression)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := strings.Join(values, ","), strings.Join(c.want, ","); got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	}
+}
+
+func TestServerRangeException(t *testing.T) {
+	client := newClient(t, Clusters{})
+
+	_, err := client.Query("%missing")
+	var rangeException *orange.ErrRangeException
+	if !errors.As(err, &rangeException) {
+		t.Fatalf("GOT: %v; WANT: %T", err, rangeException)
+	}
+	if got, want := rangeException.Message, "no such cluster"; !strings.Contains(got, want) {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+}
+
+func TestHandlerProtocol(t *testing.T) {
+	server := NewServer(Clusters{"web": {"CLUSTER": {"web1"}}})
+	defer server.Close()
+
+	t.Run("not found", func(t *testing.T) {
+		response, err := http.Get(server.URL + "/range/other?%25web")
+		if err != nil {
+			t.Fatal(err)
+		}
+		response.Body.Close()
+		if got, want := response.StatusCode, http.StatusNotFound; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("methods", func(t *testing.T) {
+		response, err := http.PostForm(server.URL+"/range/list", url.Values{"query": {"%web"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		response.Body.Close()
+		if got, want := response.StatusCode, http.StatusOK; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+
+		request, err := http.NewRequest(http.MethodDelete, server.URL+"/range/list", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		response, err = http.DefaultClient.Do(request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		response.Body.Close()
+		if got, want := response.StatusCode, http.StatusMethodNotAllowed; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+}
+
+func TestLoadClusters(t *testing.T) {
+	clusters, err := LoadClusters(fstest.MapFS{
+		"clusters/web.yaml": {Data: []byte("CLUSTER:\n  - web1..2\n")},
+	}, "clusters")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	values, err := newClient(t, clusters).Query("%web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.Join(values, ","), "web1,web2"; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+}
@@ -0,0 +1,118 @@
+package orange
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"time"
+)
+
+// ErrRateLimited is returned by a query when Config.RateLimit is exceeded and
+// its FailFast option is set.
+var ErrRateLimited = errors.New("range query rate limit exceeded")
+
+// RateLimit limits the rate at which a client sends queries to range servers,
+// so batch jobs are good citizens toward shared range infrastructure without
+// throttling themselves.
+//
+//     client, err := orange.NewClient(&orange.Config{
+//         RateLimit: orange.RateLimit{QPS: 50, Burst: 10},
+//         Servers:   servers,
+//     })
+//
+// Queries answered from the cache or coalesced with another query in flight
+// are not limited, and the retries of a query are not counted separately.
+type RateLimit struct {
+	// QPS is the sustained number of queries per second allowed.  When 0,
+	// queries are not limited.
+	QPS float64
+
+	// Burst is the number of queries that may be sent at once after the
+	// client has been idle, beyond the sustained rate.  When 0, it is 1.
+	Burst int
+
+	// FailFast causes a query exceeding the limit to return ErrRateLimited
+	// rather than wait until it may be sent.
+	FailFast bool
+}
+
+// rateLimiter is a token bucket enforcing a RateLimit.
+type rateLimiter struct {
+	lock     sync.Mutex
+	qps      float64
+	burst    float64
+	failFast bool
+	tokens   float64   // may be negative when callers are waiting for tokens
+	last     time.Time // time tokens was last updated
+	now      func() time.Time
+}
+
+func newRateLimiter(limit RateLimit, now func() time.Time) *rateLimiter {
+	burst := float64(limit.Burst)
+	if burst == 0 {
+		burst = 1
+	}
+	return &rateLimiter{
+		qps:      limit.QPS,
+		burst:    burst,
+		failFast: limit.FailFast,
+		tokens:   burst,
+		last:     now(),
+		now:      now,
+	}
+}
+
+// reserve takes a token from the bucket, and returns how long the caller must
+// wait before sending its query.  When failFast is set and no token is
+// available, it takes nothing and returns false.
+func (l *rateLimiter) reserve() (time.Duration, bool) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	now := l.now()
+	if elapsed := now.Sub(l.last); elapsed > 0 {
+		l.tokens = math.Min(l.burst, l.tokens+elapsed.Seconds()*l.qps)
+	}
+	l.last = now
+
+	if l.tokens >= 1 {
+		l.tokens--
+		return 0, true
+	}
+	if l.failFast {
+		return 0, false
+	}
+	l.tokens--
+	return time.Duration(-l.tokens / l.qps * float64(time.Second)), true
+}
+
+// unreserve returns a token taken by a caller that gave up waiting for it.
+func (l *rateLimiter) unreserve() {
+	l.lock.Lock()
+	l.tokens = math.Min(l.burst, l.tokens+1)
+	l.lock.Unlock()
+}
+
+// waitRateLimit returns once the client may send a request, or the error that
+// prevents it from being sent: ErrRateLimited, or the context error when ctx is
+// done while waiting.  Callers record the failure.
+func (c *Client) waitRateLimit(ctx context.Context) error {
+	if c.rateLimiter == nil {
+		return nil
+	}
+	wait, ok := c.rateLimiter.reserve()
+	if !ok {
+		return ErrRateLimited
+	}
+	if wait == 0 {
+		return nil
+	}
+	select {
+	case <-c.clock.After(wait):
+		return nil
+	case <-ctx.Done():
+		c.rateLimiter.unreserve()
+		return contextError(ctx)
+	}
+}
@@ -0,0 +1,97 @@
+package orange
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func withRateLimitedClient(t *testing.T, limit RateLimit, callback func(*Client, *VirtualClock)) {
+	withTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("host1\n"))
+	}, func(server *httptest.Server) {
+		clock := NewVirtualClock(time.Unix(1000000000, 0))
+		client, err := NewClient(&Config{
+			Clock:      clock,
+			HTTPClient: server.Client(),
+			RateLimit:  limit,
+			Servers:    []string{strings.TrimPrefix(server.URL, "http://")},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		callback(client, clock)
+	})
+}
+
+func TestRateLimit(t *testing.T) {
+	t.Run("negative", func(t *testing.T) {
+		_, err := NewClient(&Config{RateLimit: RateLimit{QPS: -1}, Servers: []string{"range"}})
+		ensureError(t, err, "negative RateLimit.QPS")
+	})
+
+	t.Run("waits", func(t *testing.T) {
+		withRateLimitedClient(t, RateLimit{QPS: 10, Burst: 2}, func(client *Client, clock *VirtualClock) {
+			for i := 0; i < 5; i++ {
+				_, err := client.Query("%someCluster")
+				ensureError(t, err)
+			}
+			// The burst of two is sent at once, and each of the remaining
+			// three queries waits a tenth of a second.
+			if got, want := clock.Elapsed(), 300*time.Millisecond; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+		})
+	})
+
+	t.Run("fail fast", func(t *testing.T) {
+		withRateLimitedClient(t, RateLimit{QPS: 10, FailFast: true}, func(client *Client, clock *VirtualClock) {
+			var reports []QueryError
+			client.onErrorReport = func(qe QueryError) { reports = append(reports, qe) }
+
+			_, err := client.Query("%someCluster")
+			ensureError(t, err)
+
+			_, err = client.Query("%someCluster")
+			if got, want := err, ErrRateLimited; !errors.Is(got, want) {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+
+			clock.After(100 * time.Millisecond)
+			_, err = client.Query("%someCluster")
+			ensureError(t, err)
+
+			if got, want := client.Stats().Queries, uint64(2); got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+			if got, want := client.Stats().Errors, uint64(1); got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+			if got, want := len(reports), 1; got != want {
+				t.Fatalf("GOT: %v; WANT: %v", got, want)
+			}
+			if got, want := reports[0].Err, ErrRateLimited; !errors.Is(got, want) {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+		})
+	})
+
+	t.Run("canceled while waiting", func(t *testing.T) {
+		withRateLimitedClient(t, RateLimit{QPS: 10}, func(client *Client, clock *VirtualClock) {
+			client.clock = realClock{}
+			_, err := client.Query("%someCluster")
+			ensureError(t, err)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			_, err = client.QueryCtx(ctx, "%someCluster")
+			if got, want := err, context.Canceled; !errors.Is(got, want) {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+		})
+	})
+}
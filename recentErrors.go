@@ -0,0 +1,63 @@
+package orange
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultRecentErrors is used when RecentErrors is zero to control how many of
+// the most recent query errors a Client retains.
+const DefaultRecentErrors = 16
+
+// RecentError describes a failed attempt to query a range server.
+type RecentError struct {
+	Time       time.Time // Time is when the attempt failed.
+	Server     string    // Server is the address of the range server that was queried.
+	Expression string    // Expression is the range expression that was queried.
+	Err        error     // Err is the error the attempt returned.
+}
+
+// RecentErrors returns the most recent errors the Client has observed, oldest
+// first, so a crashing service can dump exactly what the range client saw
+// without requiring verbose logging to have been enabled in advance.
+func (c *Client) RecentErrors() []RecentError {
+	return c.recentErrors.list()
+}
+
+// recentErrors is a fixed size ring buffer of the most recent errors.
+type recentErrors struct {
+	lock   sync.Mutex
+	values []RecentError
+	next   int  // index where the next error will be stored
+	full   bool // true after the ring buffer has wrapped around
+}
+
+func newRecentErrors(size int) *recentErrors {
+	return &recentErrors{values: make([]RecentError, size)}
+}
+
+func (re *recentErrors) add(value RecentError) {
+	re.lock.Lock()
+	re.values[re.next] = value
+	re.next++
+	if re.next == len(re.values) {
+		re.next = 0
+		re.full = true
+	}
+	re.lock.Unlock()
+}
+
+func (re *recentErrors) list() []RecentError {
+	re.lock.Lock()
+	defer re.lock.Unlock()
+
+	if !re.full {
+		values := make([]RecentError, re.next)
+		copy(values, re.values[:re.next])
+		return values
+	}
+
+	values := make([]RecentError, 0, len(re.values))
+	values = append(values, re.values[re.next:]...)
+	return append(values, re.values[:re.next]...)
+}
@@ -0,0 +1,78 @@
+package orange
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRecentErrors(t *testing.T) {
+	t.Run("ring buffer", func(t *testing.T) {
+		re := newRecentErrors(3)
+
+		if got, want := len(re.list()), 0; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+
+		for _, s := range []string{"one", "two"} {
+			re.add(RecentError{Expression: s})
+		}
+		values := re.list()
+		if got, want := len(values), 2; got != want {
+			t.Fatalf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := values[0].Expression, "one"; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+
+		for _, s := range []string{"three", "four"} {
+			re.add(RecentError{Expression: s})
+		}
+		values = re.list()
+		if got, want := len(values), 3; got != want {
+			t.Fatalf("GOT: %v; WANT: %v", got, want)
+		}
+		for i, want := range []string{"two", "three", "four"} {
+			if got := values[i].Expression; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+		}
+	})
+
+	t.Run("client records failed attempts", func(t *testing.T) {
+		h := func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("RangeException", "some error")
+		}
+		withClient(t, h, func(client *Client) {
+			start := time.Unix(1000000000, 0)
+			client.clock = NewVirtualClock(start)
+
+			_, err := client.Query("foo")
+			ensureError(t, err, "some error")
+
+			values := client.RecentErrors()
+			if got, want := len(values), 1; got != want {
+				t.Fatalf("GOT: %v; WANT: %v", got, want)
+			}
+			if got, want := values[0].Expression, "foo"; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+			if got, want := values[0].Server, client.servers.values[0]; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+			var re *ErrRangeException
+			if !errors.As(values[0].Err, &re) {
+				t.Errorf("GOT: %T; WANT: %T", values[0].Err, &ErrRangeException{})
+			}
+			if got, want := values[0].Time, start; !got.Equal(want) {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+		})
+	})
+
+	t.Run("negative size", func(t *testing.T) {
+		_, err := NewClient(&Config{RecentErrors: -1, Servers: []string{"localhost:8081"}})
+		ensureError(t, err, "negative RecentErrors")
+	})
+}
@@ -0,0 +1,154 @@
+package orange
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Response holds the results of a query along with metadata describing how the
+// query was resolved.
+type Response struct {
+	// Expression is the range expression that was queried.
+	Expression string
+
+	// Server is the address of the range server that provided the response.
+	Server string
+
+	// Method is the HTTP method used for the successful request.
+	Method string
+
+	// Attempts is the number of attempts made to resolve the query, including
+	// the successful attempt.
+	Attempts int
+
+	// Duration is the amount of time taken to resolve the query, including
+	// all retries.
+	Duration time.Duration
+
+	// Header contains the response headers named by Config.ResponseHeaders,
+	// such as a server provided request identifier.
+	Header http.Header
+
+	// Warnings contains the values of any Warning headers provided by the
+	// range server, such as notices that part of an expression could not be
+	// evaluated.
+	Warnings []string
+
+	// Delimiter is the byte separating results in Body, either the configured
+	// Config.Delimiter or the one detected from the response Content-Type.
+	// Newlines always separate results.
+	Delimiter byte
+
+	// Body is the unparsed response body.
+	Body []byte
+
+	// contentLength is the Content-Length of the response, or -1 when unknown,
+	// used to size the results before reading them.
+	contentLength int64
+}
+
+// QueryResponse sends the query expression to the range client with the
+// provided query context, and returns the response along with metadata
+// describing how the query was resolved, such as which server provided the
+// response and any captured correlation headers.
+//
+//     response, err := client.QueryResponse(ctx, "%someCluster")
+//     if err != nil {
+//         return err
+//     }
+//     log.Printf("server: %s; request id: %s", response.Server, response.RequestID())
+//     values := response.Split()
+func (c *Client) QueryResponse(ctx context.Context, expression string) (*Response, error) {
+	response := &Response{Expression: expression}
+	start := time.Now()
+
+	err := c.queryCallback(ctx, expression, func(ior io.Reader) error {
+		var err error
+		response.Body, err = readAllPooled(ior)
+		return err
+	}, response)
+	if err != nil {
+		return nil, err
+	}
+
+	response.Duration = time.Since(start)
+	return response, nil
+}
+
+// RequestID returns the server provided X-Request-Id header, or the empty string
+// when the header was not captured.
+func (r *Response) RequestID() string {
+	return r.Header.Get("X-Request-Id")
+}
+
+// Split returns the response body as a slice of results separated by
+// Delimiter.  Like Query, results are stripped of carriage returns and blank
+// results are skipped.
+func (r *Response) Split() []string {
+	var lines []string
+	// The entire body is already in memory, so no line can exceed the limit.
+	_ = scanLines(bytes.NewReader(r.Body), len(r.Body)+1, r.Delimiter, func(line string) error {
+		lines = append(lines, line)
+		return nil
+	}) // reading from a byte slice cannot fail
+	return lines
+}
+
+// MarshalJSON returns the JSON encoding of the response results along with its
+// metadata, suitable for services that proxy range results to browsers.  The
+// duration is encoded in milliseconds, and results are never null.
+//
+//     {
+//         "expression": "%someCluster",
+//         "server": "range.example.com",
+//         "method": "GET",
+//         "attempts": 1,
+//         "durationMs": 12.5,
+//         "requestId": "abc123",
+//         "warnings": ["199 range \"cluster is deprecated\""],
+//         "results": ["host1", "host2"]
+//     }
+func (r *Response) MarshalJSON() ([]byte, error) {
+	results := r.Split()
+	if results == nil {
+		results = []string{}
+	}
+	return json.Marshal(struct {
+		Expression string   `json:"expression"`
+		Server     string   `json:"server,omitempty"`
+		Method     string   `json:"method,omitempty"`
+		Attempts   int      `json:"attempts,omitempty"`
+		DurationMs float64  `json:"durationMs"`
+		RequestID  string   `json:"requestId,omitempty"`
+		Warnings   []string `json:"warnings,omitempty"`
+		Results    []string `json:"results"`
+	}{
+		Expression: r.Expression,
+		Server:     r.Server,
+		Method:     r.Method,
+		Attempts:   r.Attempts,
+		DurationMs: float64(r.Duration) / float64(time.Millisecond),
+		RequestID:  r.RequestID(),
+		Warnings:   r.Warnings,
+		Results:    results,
+	})
+}
+
+// captureHeaders returns a copy of the named headers found in header, or nil
+// when none are found.
+func captureHeaders(header http.Header, names []string) http.Header {
+	var captured http.Header
+	for _, name := range names {
+		if values, ok := header[http.CanonicalHeaderKey(name)]; ok {
+			if captured == nil {
+				captured = make(http.Header, len(names))
+			}
+			captured[http.CanonicalHeaderKey(name)] = append([]string(nil), values...)
+		}
+	}
+	return captured
+}
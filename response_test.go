@@ -0,0 +1,124 @@
+package orange
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestQueryResponse(t *testing.T) {
+	h := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Request-Id", "abc123")
+		w.Header().Set("Server", "range/1.2")
+		w.Header().Set("X-Not-Captured", "ignored")
+		w.Write([]byte("result1\nresult2\n"))
+	}
+	withTestServer(t, h, func(server *httptest.Server) {
+		address := strings.TrimLeft(server.URL, "http://")
+
+		var events []AttemptEvent
+
+		client, err := NewClient(&Config{
+			HTTPClient: server.Client(),
+			OnAttempt:  func(event AttemptEvent) { events = append(events, event) },
+			Servers:    []string{address},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		response, err := client.QueryResponse(context.Background(), "foo")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ensureStringSlicesMatch(t, response.Split(), []string{"result1", "result2"})
+
+		if got, want := response.Expression, "foo"; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := response.Server, address; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := response.Method, http.MethodGet; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := response.Attempts, 1; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := response.RequestID(), "abc123"; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := response.Header.Get("Server"), "range/1.2"; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := response.Header.Get("X-Not-Captured"), ""; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+
+		if got, want := len(events), 1; got != want {
+			t.Fatalf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := events[0].Header.Get("X-Request-Id"), "abc123"; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+}
+
+func TestResponseMarshalJSON(t *testing.T) {
+	h := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Request-Id", "abc123")
+		w.Header().Add("Warning", `199 range "cluster is deprecated"`)
+		w.Write([]byte("result1\nresult2\n"))
+	}
+	withClient(t, h, func(client *Client) {
+		response, err := client.QueryResponse(context.Background(), "foo")
+		if err != nil {
+			t.Fatal(err)
+		}
+		response.Duration = 1500 * time.Microsecond
+
+		buf, err := json.Marshal(response)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := `{"expression":"foo","server":"` + response.Server + `","method":"GET","attempts":1,"durationMs":1.5,"requestId":"abc123","warnings":["199 range \"cluster is deprecated\""],"results":["result1","result2"]}`
+		if got := string(buf); got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+}
+
+func TestResponseMarshalJSONEmpty(t *testing.T) {
+	buf, err := json.Marshal(&Response{Expression: "foo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(buf), `{"expression":"foo","durationMs":0,"results":[]}`; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+}
+
+func BenchmarkQueryResponse(b *testing.B) {
+	h := func(w http.ResponseWriter, r *http.Request) {
+		w.Write(largeResponse)
+	}
+
+	withClient(b, h, func(client *Client) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			response, err := client.QueryResponse(context.Background(), "foo")
+			if err != nil {
+				b.Fatal(err)
+			}
+			if got, want := len(response.Body), len(largeResponse); got != want {
+				b.Fatalf("GOT: %v; WANT: %v", got, want)
+			}
+		}
+	})
+}
@@ -0,0 +1,209 @@
+package orange
+
+import (
+	"fmt"
+	"math/rand"
+	"path"
+	"regexp"
+)
+
+// Results is a list of values returned by a range query, with set operations
+// so downstream set math on query results does not get reimplemented in every
+// service.  Because it is backed by []string, a slice returned by Query may be
+// converted directly.
+//
+//     values, err := client.Query("%cluster1")
+//     if err != nil {
+//         return err
+//     }
+//     others, err := client.Query("%cluster2")
+//     if err != nil {
+//         return err
+//     }
+//     both := orange.Results(values).Intersect(others)
+//
+// The set operations return new Results without duplicate values, preserving
+// the order in which values first appear, and never modify their operands.
+type Results []string
+
+// Contains returns true when value is one of the results.  It scans the
+// results, so when results are consulted repeatedly use Set instead.
+func (r Results) Contains(value string) bool {
+	for _, v := range r {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Union returns the values found in either r or other, with the values of r
+// first.
+func (r Results) Union(other []string) Results {
+	seen := make(map[string]struct{}, len(r)+len(other))
+	union := make(Results, 0, len(r)+len(other))
+	for _, values := range [][]string{r, other} {
+		for _, v := range values {
+			if _, ok := seen[v]; !ok {
+				seen[v] = struct{}{}
+				union = append(union, v)
+			}
+		}
+	}
+	return union
+}
+
+// Intersect returns the values of r that are also found in other.
+func (r Results) Intersect(other []string) Results {
+	return r.filterSet(toSet(other), true)
+}
+
+// Difference returns the values of r that are not found in other.
+func (r Results) Difference(other []string) Results {
+	return r.filterSet(toSet(other), false)
+}
+
+// Filter returns the values of r that match pattern, so expansions can be
+// narrowed without re-querying with expressions the server may not support.
+// A pattern enclosed in slashes, as in range expressions, is a regular
+// expression matched anywhere in each value; any other pattern is a glob,
+// using the syntax of path.Match, that must match the entire value.
+//
+//     canaries, err := orange.Results(values).Filter("*-canary*")
+//     web, err := orange.Results(values).Filter("/^web[0-9]+\\./")
+func (r Results) Filter(pattern string) (Results, error) {
+	var match func(string) bool
+
+	if len(pattern) > 1 && pattern[0] == '/' && pattern[len(pattern)-1] == '/' {
+		re, err := regexp.Compile(pattern[1 : len(pattern)-1])
+		if err != nil {
+			return nil, fmt.Errorf("cannot filter results with invalid regular expression: %w", err)
+		}
+		match = re.MatchString
+	} else {
+		// Validate the pattern up front so an invalid glob is reported even
+		// when there are no results to match.
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("cannot filter results with invalid glob %q: %w", pattern, err)
+		}
+		match = func(value string) bool {
+			ok, _ := path.Match(pattern, value)
+			return ok
+		}
+	}
+
+	filtered := make(Results, 0, len(r))
+	for _, v := range r {
+		if match(v) {
+			filtered = append(filtered, v)
+		}
+	}
+	return filtered, nil
+}
+
+// Sample returns n values of r chosen at random, such as for picking canary
+// hosts or load testing targets from an expansion.  The same seed always
+// chooses the same values from the same results, so a selection can be
+// reproduced.  When n is not less than the number of results, all of them are
+// returned in random order.  Sample does not modify r.
+//
+//     canaries := orange.Results(values).Sample(3, time.Now().UnixNano())
+func (r Results) Sample(n int, seed int64) []string {
+	if n <= 0 {
+		return []string{}
+	}
+	if n > len(r) {
+		n = len(r)
+	}
+	pool := append([]string(nil), r...)
+	rng := rand.New(rand.NewSource(seed))
+	// Partial Fisher-Yates shuffle: only the first n positions are needed.
+	for i := 0; i < n; i++ {
+		j := i + rng.Intn(len(pool)-i)
+		pool[i], pool[j] = pool[j], pool[i]
+	}
+	return pool[:n:n]
+}
+
+// Chunks splits r into consecutive batches of at most size values, such as for
+// fanning results into SSH pools or batched API calls.  Only the final batch
+// may hold fewer than size values.  The batches share the backing array of r,
+// but are capped so appending to one does not overwrite the next.  Chunks
+// panics when size is not positive.
+//
+//     for _, batch := range orange.Results(values).Chunks(50) {
+//         if err := restart(batch); err != nil {
+//             return err
+//         }
+//     }
+func (r Results) Chunks(size int) [][]string {
+	if size <= 0 {
+		panic(fmt.Sprintf("orange: cannot chunk results with non-positive size: %d", size))
+	}
+	chunks := make([][]string, 0, (len(r)+size-1)/size)
+	for i := 0; i < len(r); i += size {
+		end := i + size
+		if end > len(r) {
+			end = len(r)
+		}
+		chunks = append(chunks, r[i:end:end])
+	}
+	return chunks
+}
+
+// Set returns the results as a set for constant time membership checks when
+// results are consulted repeatedly in hot paths.  Because Results is a slice,
+// it has nowhere to cache the set, so each call builds a new one; hold on to
+// the returned map rather than calling Set for every lookup.
+//
+//     hosts := orange.Results(values).Set()
+//     for _, event := range events {
+//         if _, ok := hosts[event.Host]; ok {
+//             // ...
+//         }
+//     }
+func (r Results) Set() map[string]struct{} {
+	return toSet(r)
+}
+
+// filterSet returns the unique values of r whose membership in set matches
+// keep.
+func (r Results) filterSet(set map[string]struct{}, keep bool) Results {
+	seen := make(map[string]struct{}, len(r))
+	filtered := make(Results, 0, len(r))
+	for _, v := range r {
+		if _, ok := seen[v]; ok {
+			continue
+		}
+		seen[v] = struct{}{}
+		if _, ok := set[v]; ok == keep {
+			filtered = append(filtered, v)
+		}
+	}
+	return filtered
+}
+
+// Unique returns the values without duplicates, preserving the order in which
+// values first appear.  Overlapping unions evaluated by some range servers
+// return the same host more than once.  Unique does not modify values.
+//
+//     values = orange.Unique(values)
+func Unique(values []string) []string {
+	seen := make(map[string]struct{}, len(values))
+	unique := make([]string, 0, len(values))
+	for _, v := range values {
+		if _, ok := seen[v]; !ok {
+			seen[v] = struct{}{}
+			unique = append(unique, v)
+		}
+	}
+	return unique
+}
+
+func toSet(values []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(values))
+	for _, v := range values {
+		set[v] = struct{}{}
+	}
+	return set
+}
@@ -0,0 +1,217 @@
+package orange
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func ensureResultsEqual(tb testing.TB, actual Results, expected []string) {
+	tb.Helper()
+	if got, want := len(actual), len(expected); got != want {
+		tb.Fatalf("GOT: %v; WANT: %v", actual, expected)
+	}
+	for i := range actual {
+		if got, want := actual[i], expected[i]; got != want {
+			tb.Errorf("GOT: %v; WANT: %v", actual, expected)
+			return
+		}
+	}
+}
+
+func TestResults(t *testing.T) {
+	a := Results{"web1", "web2", "web3", "web2"}
+	b := []string{"web3", "web4", "web2"}
+
+	t.Run("Contains", func(t *testing.T) {
+		if got, want := a.Contains("web2"), true; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := a.Contains("web4"), false; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := Results(nil).Contains(""), false; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("Union", func(t *testing.T) {
+		ensureResultsEqual(t, a.Union(b), []string{"web1", "web2", "web3", "web4"})
+		ensureResultsEqual(t, Results(nil).Union(nil), nil)
+	})
+
+	t.Run("Intersect", func(t *testing.T) {
+		ensureResultsEqual(t, a.Intersect(b), []string{"web2", "web3"})
+		ensureResultsEqual(t, a.Intersect(nil), nil)
+	})
+
+	t.Run("Difference", func(t *testing.T) {
+		ensureResultsEqual(t, a.Difference(b), []string{"web1"})
+		ensureResultsEqual(t, a.Difference(nil), []string{"web1", "web2", "web3"})
+	})
+
+	t.Run("operands not modified", func(t *testing.T) {
+		ensureResultsEqual(t, a, []string{"web1", "web2", "web3", "web2"})
+	})
+}
+
+func TestUnique(t *testing.T) {
+	values := []string{"b", "a", "b", "c", "a"}
+	ensureResultsEqual(t, Unique(values), []string{"b", "a", "c"})
+	ensureResultsEqual(t, values, []string{"b", "a", "b", "c", "a"})
+	ensureResultsEqual(t, Unique(nil), nil)
+}
+
+func TestClientUnique(t *testing.T) {
+	h := func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("web10\nweb9\nweb10\nweb1\nweb9\n"))
+	}
+	withTestServer(t, h, func(server *httptest.Server) {
+		client, err := NewClient(&Config{
+			HTTPClient: server.Client(),
+			Servers:    []string{strings.TrimLeft(server.URL, "http://")},
+			Unique:     true,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		values, err := client.Query("foo")
+		if err != nil {
+			t.Fatal(err)
+		}
+		ensureResultsEqual(t, values, []string{"web10", "web9", "web1"})
+	})
+}
+
+func TestResultsFilter(t *testing.T) {
+	values := Results{"web1-canary.example.com", "web2.example.com", "db1-canary.example.com"}
+
+	t.Run("glob", func(t *testing.T) {
+		filtered, err := values.Filter("*-canary.*")
+		if err != nil {
+			t.Fatal(err)
+		}
+		ensureResultsEqual(t, filtered, []string{"web1-canary.example.com", "db1-canary.example.com"})
+	})
+
+	t.Run("glob must match entire value", func(t *testing.T) {
+		filtered, err := values.Filter("web2")
+		if err != nil {
+			t.Fatal(err)
+		}
+		ensureResultsEqual(t, filtered, []string{})
+	})
+
+	t.Run("regexp", func(t *testing.T) {
+		filtered, err := values.Filter("/^web[0-9]+/")
+		if err != nil {
+			t.Fatal(err)
+		}
+		ensureResultsEqual(t, filtered, []string{"web1-canary.example.com", "web2.example.com"})
+	})
+
+	t.Run("invalid glob", func(t *testing.T) {
+		_, err := Results(nil).Filter("web[")
+		ensureError(t, err, "invalid glob")
+	})
+
+	t.Run("invalid regexp", func(t *testing.T) {
+		_, err := values.Filter("/web(/")
+		ensureError(t, err, "invalid regular expression")
+	})
+}
+
+func TestResultsSample(t *testing.T) {
+	values := Results{"web1", "web2", "web3", "web4", "web5", "web6"}
+
+	t.Run("size", func(t *testing.T) {
+		sample := values.Sample(3, 1)
+		if got, want := len(sample), 3; got != want {
+			t.Fatalf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := len(Unique(sample)), 3; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		for _, v := range sample {
+			if !values.Contains(v) {
+				t.Errorf("GOT: %v; WANT: one of %v", v, values)
+			}
+		}
+	})
+
+	t.Run("reproducible", func(t *testing.T) {
+		ensureResultsEqual(t, values.Sample(3, 42), values.Sample(3, 42))
+	})
+
+	t.Run("does not modify", func(t *testing.T) {
+		values.Sample(6, 7)
+		ensureResultsEqual(t, values, []string{"web1", "web2", "web3", "web4", "web5", "web6"})
+	})
+
+	t.Run("more than available", func(t *testing.T) {
+		if got, want := len(values.Sample(10, 1)), len(values); got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("none", func(t *testing.T) {
+		ensureResultsEqual(t, values.Sample(0, 1), []string{})
+		ensureResultsEqual(t, Results(nil).Sample(3, 1), []string{})
+	})
+}
+
+func TestResultsChunks(t *testing.T) {
+	values := Results{"web1", "web2", "web3", "web4", "web5"}
+
+	chunks := values.Chunks(2)
+	if got, want := len(chunks), 3; got != want {
+		t.Fatalf("GOT: %v; WANT: %v", got, want)
+	}
+	ensureResultsEqual(t, chunks[0], []string{"web1", "web2"})
+	ensureResultsEqual(t, chunks[1], []string{"web3", "web4"})
+	ensureResultsEqual(t, chunks[2], []string{"web5"})
+
+	t.Run("append does not overwrite", func(t *testing.T) {
+		_ = append(values.Chunks(2)[0], "other")
+		ensureResultsEqual(t, values, []string{"web1", "web2", "web3", "web4", "web5"})
+	})
+
+	t.Run("size larger than results", func(t *testing.T) {
+		chunks := values.Chunks(10)
+		if got, want := len(chunks), 1; got != want {
+			t.Fatalf("GOT: %v; WANT: %v", got, want)
+		}
+		ensureResultsEqual(t, chunks[0], values)
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		if got, want := len(Results(nil).Chunks(2)), 0; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("non-positive size", func(t *testing.T) {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Errorf("GOT: %v; WANT: panic", r)
+			}
+		}()
+		values.Chunks(0)
+	})
+}
+
+func TestResultsSet(t *testing.T) {
+	set := Results{"web1", "web2", "web1"}.Set()
+	if got, want := len(set), 2; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+	for _, v := range []string{"web1", "web2"} {
+		if _, ok := set[v]; !ok {
+			t.Errorf("GOT: %v; WANT: %v", set, v)
+		}
+	}
+	if _, ok := set["web3"]; ok {
+		t.Errorf("GOT: %v; WANT: %v", ok, false)
+	}
+}
@@ -0,0 +1,38 @@
+package orange
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRewriteExpression(t *testing.T) {
+	var queried []string
+	withTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		queried = append(queried, r.URL.RawQuery)
+		_, _ = w.Write([]byte("host1\n"))
+	}, func(server *httptest.Server) {
+		client, err := NewClient(&Config{
+			HTTPClient: server.Client(),
+			RewriteExpression: func(expression string) (string, error) {
+				if expression == "legacy" {
+					return "", errors.New("legacy clusters are not supported")
+				}
+				return "prod-" + expression, nil
+			},
+			Servers: []string{strings.TrimPrefix(server.URL, "http://")},
+		})
+		ensureError(t, err)
+
+		values, err := client.Query("web")
+		ensureError(t, err)
+		ensureStringSlicesMatch(t, values, []string{"host1"})
+		ensureStringSlicesMatch(t, queried, []string{"prod-web"})
+
+		_, err = client.Query("legacy")
+		ensureError(t, err, "cannot rewrite expression", "legacy clusters are not supported")
+		ensureStringSlicesMatch(t, queried, []string{"prod-web"})
+	})
+}
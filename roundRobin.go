@@ -9,8 +9,9 @@ import (
 // method, returns the next string value from the list of values when it was
 // initialized.  On rollover, it returns the first value from the list.
 type roundRobinStrings struct {
-	values []string
-	i      uint32
+	values   []string
+	distinct int // number of distinct values
+	i        uint32
 }
 
 func newRoundRobinStrings(someStrings []string) (*roundRobinStrings, error) {
@@ -26,6 +27,7 @@ func newRoundRobinStrings(someStrings []string) (*roundRobinStrings, error) {
 
 	// Populate data structure with values.
 	copy(rrs.values, someStrings)
+	rrs.distinct = len(toSet(someStrings))
 
 	return rrs, nil
 }
@@ -33,7 +35,11 @@ func newRoundRobinStrings(someStrings []string) (*roundRobinStrings, error) {
 // Len returns the number of strings in the roundRobinStrings structure.
 func (rr *roundRobinStrings) Len() int { return len(rr.values) }
 
-// Next returns the next string in the roundRobinStrings structure.
+// Next returns the next string in the roundRobinStrings structure.  A single
+// atomic increment selects the string, so concurrent callers never retry, and
+// each receives a distinct position in the rotation.  When the counter wraps
+// around after 2^32 calls, the rotation may skip ahead once, which is harmless
+// for spreading load.
 func (rr *roundRobinStrings) Next() string {
 	l := uint32(len(rr.values))
 
@@ -42,18 +48,22 @@ func (rr *roundRobinStrings) Next() string {
 		return rr.values[0]
 	}
 
-	var i, ni uint32
+	return rr.values[(atomic.AddUint32(&rr.i, 1)-1)%l]
+}
+
+// Distinct returns the number of distinct strings in the roundRobinStrings
+// structure.
+func (rr *roundRobinStrings) Distinct() int { return rr.distinct }
 
-	for j := 0; j < 4; j++ {
-		i = atomic.LoadUint32(&rr.i)
-		ni = (i + 1) % l
-		if atomic.CompareAndSwapUint32(&rr.i, i, ni) {
-			return rr.values[i]
+// NextExcept returns the next string in the roundRobinStrings structure that is
+// not in skip, or the next string when every string is in skip.
+func (rr *roundRobinStrings) NextExcept(skip map[string]struct{}) string {
+	value := rr.Next()
+	for i := 1; i < len(rr.values); i++ {
+		if _, ok := skip[value]; !ok {
+			break
 		}
+		value = rr.Next()
 	}
-
-	// During high contention, give up and send back the string corresponding to
-	// our last attempt.  This use-case does not require absolute perfect round
-	// robin order.  Do not let perfect be the enemy of good enough.
-	return rr.values[i]
+	return value
 }
@@ -1,6 +1,9 @@
 package orange
 
-import "testing"
+import (
+	"sync"
+	"testing"
+)
 
 func TestRoundRobin(t *testing.T) {
 	t.Run("empty", func(t *testing.T) {
@@ -59,3 +62,46 @@ func TestRoundRobin(t *testing.T) {
 		}
 	})
 }
+
+func TestRoundRobinConcurrentFairness(t *testing.T) {
+	rrs, err := newRoundRobinStrings([]string{"one", "two", "three"})
+	ensureError(t, err)
+
+	var lock sync.Mutex
+	counts := make(map[string]int)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			local := make(map[string]int)
+			for j := 0; j < 3000; j++ {
+				local[rrs.Next()]++
+			}
+			lock.Lock()
+			for k, v := range local {
+				counts[k] += v
+			}
+			lock.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	for _, value := range []string{"one", "two", "three"} {
+		if got, want := counts[value], 8000; got != want {
+			t.Errorf("%s: GOT: %v; WANT: %v", value, got, want)
+		}
+	}
+}
+
+func BenchmarkRoundRobinParallel(b *testing.B) {
+	rrs, err := newRoundRobinStrings([]string{"one", "two", "three"})
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			_ = rrs.Next()
+		}
+	})
+}
@@ -0,0 +1,55 @@
+package orange
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestServerURLs(t *testing.T) {
+	h := func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/range/list") {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(r.URL.Path + "\n"))
+	}
+
+	t.Run("https url", func(t *testing.T) {
+		server := httptest.NewTLSServer(http.HandlerFunc(h))
+		defer server.Close()
+
+		client, err := NewClient(&Config{HTTPClient: server.Client(), Servers: []string{server.URL + "/prefix/"}})
+		ensureError(t, err)
+		values, err := client.Query("%someCluster")
+		ensureError(t, err)
+		ensureStringSlicesMatch(t, values, []string{"/prefix/range/list"})
+	})
+
+	t.Run("https scheme", func(t *testing.T) {
+		server := httptest.NewTLSServer(http.HandlerFunc(h))
+		defer server.Close()
+
+		client, err := NewClient(&Config{
+			HTTPClient: server.Client(),
+			Scheme:     "https",
+			Servers:    []string{strings.TrimPrefix(server.URL, "https://")},
+		})
+		ensureError(t, err)
+		values, err := client.Query("%someCluster")
+		ensureError(t, err)
+		ensureStringSlicesMatch(t, values, []string{"/range/list"})
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := NewClient(&Config{Scheme: "ftp", Servers: []string{"range:8081"}})
+		ensureError(t, err, "unsupported Scheme")
+
+		_, err = NewClient(&Config{Servers: []string{"ftp://range:8081"}})
+		ensureError(t, err, "unsupported scheme")
+
+		_, err = NewClient(&Config{Servers: []string{"https://range:8081/?foo"}})
+		ensureError(t, err, "neither a query nor a fragment")
+	})
+}
@@ -0,0 +1,184 @@
+// Package server implements the HTTP side of the range protocol, so teams can
+// serve range data from their own sources while this package handles the
+// protocol.  A Backend evaluates expressions, and a Handler answers range
+// queries using it.
+//
+//     backend := server.BackendFunc(func(ctx context.Context, expression string) ([]string, error) {
+//         return inventory.Lookup(ctx, expression)
+//     })
+//     http.Handle("/range/", server.New(backend))
+//     log.Fatal(http.ListenAndServe(":8080", nil))
+//
+// Queries sent to /range/list are answered with their values, one per line.
+// Queries sent to /range/expand are answered with their values folded into
+// compact range notation by orange.Compress.  Batches of expressions sent to
+// /range/batch are answered as described by orange.BatchResult.  Queries may
+// be sent using GET, with the escaped expression as the URL query, or using PUT
+// or POST, with the expression as the query form value.  An expression the Backend cannot
+// evaluate is answered with its error in a RangeException header, with status
+// 200, which clients such as orange.Client return as *orange.ErrRangeException.
+// A Backend that cannot answer at all, such as when its own data source is
+// unavailable, returns a *StatusError instead, so clients may retry.
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/karrick/orange"
+)
+
+// Backend evaluates range expressions.
+type Backend interface {
+	// List returns the values of expression.  The returned error is sent to
+	// the client in a RangeException header.
+	List(ctx context.Context, expression string) ([]string, error)
+}
+
+// StatusError is returned by a Backend to answer a query with an HTTP error
+// status rather than a RangeException.
+//
+//     return nil, &server.StatusError{StatusCode: http.StatusBadGateway, Err: err}
+type StatusError struct {
+	// StatusCode is the HTTP status code of the response.
+	StatusCode int
+
+	// Err is the underlying error, whose text is the body of the response.
+	Err error
+}
+
+func (err *StatusError) Error() string {
+	return fmt.Sprintf("%d %s: %s", err.StatusCode, http.StatusText(err.StatusCode), err.Err)
+}
+
+// Unwrap returns the underlying error.
+func (err *StatusError) Unwrap() error { return err.Err }
+
+// BackendFunc is an adapter that allows an ordinary function to be used as a
+// Backend.
+type BackendFunc func(ctx context.Context, expression string) ([]string, error)
+
+// List returns f(ctx, expression).
+func (f BackendFunc) List(ctx context.Context, expression string) ([]string, error) {
+	return f(ctx, expression)
+}
+
+// Handler is an http.Handler that answers range queries using a Backend.
+type Handler struct {
+	backend Backend
+}
+
+// New returns a Handler that answers range queries using backend.
+func New(backend Backend) *Handler {
+	return &Handler{backend: backend}
+}
+
+// ServeHTTP answers the range query in r.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var expand bool
+	switch r.URL.Path {
+	case "/range/list":
+	case "/range/expand":
+		expand = true
+	case "/range/batch":
+		h.serveBatch(w, r)
+		return
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	expression, ok := readExpression(w, r)
+	if !ok {
+		return
+	}
+
+	values, err := h.backend.List(r.Context(), expression)
+	if err != nil {
+		var se *StatusError
+		if errors.As(err, &se) {
+			http.Error(w, se.Err.Error(), se.StatusCode)
+			return
+		}
+		// A header value cannot span lines.
+		w.Header().Set("RangeException", strings.Join(strings.Fields(err.Error()), " "))
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if expand {
+		if len(values) > 0 {
+			_, _ = w.Write([]byte(orange.Compress(values) + "\n"))
+		}
+		return
+	}
+	if len(values) > 0 {
+		_, _ = w.Write([]byte(strings.Join(values, "\n") + "\n"))
+	}
+}
+
+// serveBatch answers a batch request, whose body is a JSON array of
+// expressions, with a JSON array of their results.  When the Backend returns a
+// *StatusError for any expression, the entire batch is answered with its
+// status, so clients fall back to querying each expression.
+func (h *Handler) serveBatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	var expressions []string
+	if err := json.NewDecoder(r.Body).Decode(&expressions); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	answers := make([]orange.BatchResult, len(expressions))
+	for i, expression := range expressions {
+		values, err := h.backend.List(r.Context(), expression)
+		if err != nil {
+			var se *StatusError
+			if errors.As(err, &se) {
+				http.Error(w, se.Err.Error(), se.StatusCode)
+				return
+			}
+			answers[i].Exception = strings.Join(strings.Fields(err.Error()), " ")
+			continue
+		}
+		answers[i].Results = values
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(answers)
+}
+
+// readExpression returns the range expression of r.  When r does not use GET,
+// POST, or PUT, or its expression cannot be decoded, it writes an error
+// response and returns false.
+func readExpression(w http.ResponseWriter, r *http.Request) (string, bool) {
+	switch r.Method {
+	case http.MethodGet:
+		expression, err := url.QueryUnescape(r.URL.RawQuery)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return "", false
+		}
+		return expression, true
+	case http.MethodPost, http.MethodPut:
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return "", false
+		}
+		return r.PostForm.Get("query"), true
+	default:
+		w.Header().Set("Allow", "GET, POST, PUT")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return "", false
+	}
+}
@@ -0,0 +1,199 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/karrick/orange"
+)
+
+var testBackend = BackendFunc(func(_ context.Context, expression string) ([]string, error) {
+	switch expression {
+	case "%web":
+		return []string{"web1.example.com", "web2.example.com", "web3.example.com", "web7.example.com"}, nil
+	case "%empty":
+		return nil, nil
+	default:
+		return nil, errors.New("no such cluster:\n" + expression)
+	}
+})
+
+func newClient(tb testing.TB) *orange.Client {
+	tb.Helper()
+	server := httptest.NewServer(New(testBackend))
+	tb.Cleanup(server.Close)
+
+	client, err := orange.NewClient(&orange.Config{
+		HTTPClient: server.Client(),
+		Servers:    []string{strings.TrimPrefix(server.URL, "http://")},
+	})
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return client
+}
+
+func TestList(t *testing.T) {
+	client := newClient(t)
+
+	values, err := client.Query("%web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.Join(values, ","), "web1.example.com,web2.example.com,web3.example.com,web7.example.com"; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+
+	values, err = client.Query("%empty")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(values), 0; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+}
+
+func TestListRangeException(t *testing.T) {
+	client := newClient(t)
+
+	_, err := client.Query("%missing")
+	var rangeException *orange.ErrRangeException
+	if !errors.As(err, &rangeException) {
+		t.Fatalf("GOT: %v; WANT: %T", err, rangeException)
+	}
+	if got, want := rangeException.Message, "no such cluster: %missing"; got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+}
+
+func TestProtocol(t *testing.T) {
+	server := httptest.NewServer(New(testBackend))
+	defer server.Close()
+
+	body := func(t *testing.T, response *http.Response) string {
+		t.Helper()
+		defer response.Body.Close()
+		buf, err := io.ReadAll(response.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(buf)
+	}
+
+	t.Run("expand", func(t *testing.T) {
+		response, err := http.Get(server.URL + "/range/expand?%25web")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := body(t, response), "web1..3.example.com,web7.example.com\n"; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+	})
+
+	t.Run("put", func(t *testing.T) {
+		request, err := http.NewRequest(http.MethodPut, server.URL+"/range/list", strings.NewReader(url.Values{"query": {"%web"}}.Encode()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := body(t, response), "web1.example.com\nweb2.example.com\nweb3.example.com\nweb7.example.com\n"; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		response, err := http.Get(server.URL + "/range/other?%25web")
+		if err != nil {
+			t.Fatal(err)
+		}
+		response.Body.Close()
+		if got, want := response.StatusCode, http.StatusNotFound; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("method not allowed", func(t *testing.T) {
+		request, err := http.NewRequest(http.MethodDelete, server.URL+"/range/list?%25web", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		response.Body.Close()
+		if got, want := response.StatusCode, http.StatusMethodNotAllowed; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := response.Header.Get("Allow"), "GET, POST, PUT"; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+}
+
+func TestStatusError(t *testing.T) {
+	backend := BackendFunc(func(_ context.Context, expression string) ([]string, error) {
+		return nil, &StatusError{StatusCode: http.StatusBadGateway, Err: errors.New("upstream unavailable")}
+	})
+	server := httptest.NewServer(New(backend))
+	defer server.Close()
+
+	client, err := orange.NewClient(&orange.Config{
+		HTTPClient: server.Client(),
+		Servers:    []string{strings.TrimPrefix(server.URL, "http://")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = client.Query("%web")
+	var statusNotOK *orange.ErrStatusNotOK
+	if !errors.As(err, &statusNotOK) {
+		t.Fatalf("GOT: %v; WANT: %T", err, statusNotOK)
+	}
+	if got, want := statusNotOK.Status, "502 Bad Gateway"; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+}
+
+func TestBatch(t *testing.T) {
+	server := httptest.NewServer(New(testBackend))
+	defer server.Close()
+
+	var paths []string
+	client, err := orange.NewClient(&orange.Config{
+		BatchRequests: true,
+		HTTPClient: orange.DoerFunc(func(request *http.Request) (*http.Response, error) {
+			paths = append(paths, request.URL.Path)
+			return server.Client().Do(request)
+		}),
+		Servers: []string{strings.TrimPrefix(server.URL, "http://")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := client.Queries([]string{"%web", "%empty", "%nosuchcluster"})
+	if !orange.IsRangeException(err) {
+		t.Errorf("GOT: %v; WANT: %v", err, "RangeException")
+	}
+	if got, want := strings.Join(results[0], ","), "web1.example.com,web2.example.com,web3.example.com,web7.example.com"; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+	if got, want := len(results[1])+len(results[2]), 0; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+	if got, want := strings.Join(paths, ","), "/range/batch"; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+}
@@ -0,0 +1,106 @@
+package orange
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Snapshot holds the results of a set of expressions, so later queries can be
+// answered from it without a range server, such as in air-gapped environments
+// or disaster recovery runbooks.  It is a Doer, which answers queries of the
+// expressions it holds with their results, and queries of any other
+// expressions with a RangeException.
+//
+//     // While range is reachable:
+//     snapshot, err := client.Snapshot(ctx, []string{"%web", "%db"})
+//     if err != nil {
+//         return err
+//     }
+//     if _, err = snapshot.WriteTo(fh); err != nil {
+//         return err
+//     }
+//
+//     // Later, without range:
+//     snapshot, err := orange.ReadSnapshot(fh)
+//     if err != nil {
+//         return err
+//     }
+//     client, err := orange.NewClient(&orange.Config{Snapshot: snapshot})
+//
+// Expressions are matched exactly, so queries must use the same expressions as
+// the snapshot.  To evaluate arbitrary expressions offline, use a LocalQuerier
+// with a copy of the cluster files instead.
+type Snapshot struct {
+	// Time is when the snapshot was taken.
+	Time time.Time `json:"time"`
+
+	// Results maps each expression to its results.
+	Results map[string][]string `json:"results"`
+}
+
+// Snapshot queries each of the expressions, and returns a Snapshot of their
+// results.  When some queries fail, the Snapshot of the others is still
+// returned, along with a *BatchError that maps each failed expression to its
+// error.
+func (c *Client) Snapshot(ctx context.Context, expressions []string) (*Snapshot, error) {
+	results, err := c.QueriesCtx(ctx, expressions)
+	var be *BatchError
+	if err != nil && !errors.As(err, &be) {
+		return nil, err
+	}
+
+	snapshot := &Snapshot{Time: c.clock.Now(), Results: make(map[string][]string, len(expressions))}
+	for i, expression := range expressions {
+		if be != nil {
+			if _, ok := be.Errors[expression]; ok {
+				continue
+			}
+		}
+		snapshot.Results[expression] = results[i]
+	}
+	return snapshot, err
+}
+
+// ReadSnapshot reads a Snapshot written by Snapshot.WriteTo.
+func ReadSnapshot(r io.Reader) (*Snapshot, error) {
+	snapshot := new(Snapshot)
+	if err := json.NewDecoder(r).Decode(snapshot); err != nil {
+		return nil, fmt.Errorf("cannot read snapshot: %w", err)
+	}
+	if snapshot.Results == nil {
+		snapshot.Results = make(map[string][]string)
+	}
+	return snapshot, nil
+}
+
+// WriteTo writes the snapshot to w as JSON, and returns the number of bytes
+// written.
+func (s *Snapshot) WriteTo(w io.Writer) (int64, error) {
+	buf, err := json.Marshal(s)
+	if err != nil {
+		return 0, err
+	}
+	n, err := w.Write(append(buf, '\n'))
+	return int64(n), err
+}
+
+// Do answers the range query in request from the snapshot.
+func (s *Snapshot) Do(request *http.Request) (*http.Response, error) {
+	expression, err := requestExpression(request)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make(http.Header)
+	results, ok := s.Results[expression]
+	if !ok {
+		header.Set("RangeException", fmt.Sprintf("expression not in snapshot: %q", expression))
+		return mockResponse(request, http.StatusOK, header, ""), nil
+	}
+	return mockResponse(request, http.StatusOK, header, joinLines(results)), nil
+}
@@ -0,0 +1,62 @@
+package orange
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestSnapshot(t *testing.T) {
+	mock := &MockConfig{Queries: map[string]MockResult{
+		"%web": {Results: []string{"web1", "web2"}},
+		"%db":  {Results: []string{"db1"}},
+		"%bad": {Err: errors.New("boom")},
+	}}
+	client, err := NewMockClient(mock)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	snapshot, err := client.Snapshot(context.Background(), []string{"%web", "%db", "%bad"})
+	var be *BatchError
+	if !errors.As(err, &be) {
+		t.Fatalf("GOT: %v; WANT: %T", err, be)
+	}
+	if got, want := len(snapshot.Results), 2; got != want {
+		t.Fatalf("GOT: %v; WANT: %v", got, want)
+	}
+
+	var buf bytes.Buffer
+	if _, err = snapshot.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	snapshot, err = ReadSnapshot(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	offline, err := NewClient(&Config{Snapshot: snapshot})
+	if err != nil {
+		t.Fatal(err)
+	}
+	values, err := offline.Query("%web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ensureStringSlicesMatch(t, values, []string{"web1", "web2"})
+
+	_, err = offline.Query("%bad")
+	var rangeException *ErrRangeException
+	if !errors.As(err, &rangeException) {
+		t.Fatalf("GOT: %v; WANT: %T", err, rangeException)
+	}
+	ensureError(t, err, "not in snapshot")
+
+	mock.AssertCount(t, "%web", 1)
+}
+
+func TestReadSnapshotError(t *testing.T) {
+	_, err := ReadSnapshot(bytes.NewReader([]byte("not json")))
+	ensureError(t, err, "cannot read snapshot")
+}
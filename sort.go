@@ -0,0 +1,87 @@
+package orange
+
+import (
+	"sort"
+	"strings"
+)
+
+// CompareHosts compares two host names in natural order, returning a negative
+// number when a sorts before b, a positive number when a sorts after b, and 0
+// when they are equal.  Hosts are grouped by domain, the portion of the name
+// after the first period, and then ordered by the remainder of the name.  Runs
+// of digits are compared numerically, so host9 sorts before host10.
+func CompareHosts(a, b string) int {
+	aName, aDomain := splitDomain(a)
+	bName, bDomain := splitDomain(b)
+	if c := compareNatural(aDomain, bDomain); c != 0 {
+		return c
+	}
+	return compareNatural(aName, bName)
+}
+
+// SortHosts sorts hosts in place in the natural order defined by CompareHosts.
+func SortHosts(hosts []string) {
+	sort.SliceStable(hosts, func(i, j int) bool { return CompareHosts(hosts[i], hosts[j]) < 0 })
+}
+
+func splitDomain(host string) (string, string) {
+	if i := strings.IndexByte(host, '.'); i >= 0 {
+		return host[:i], host[i+1:]
+	}
+	return host, ""
+}
+
+// compareNatural compares two strings, treating runs of digits as numbers.
+// Numbers that are equal in value but differ in zero padding are ordered with
+// the shorter run first.
+func compareNatural(a, b string) int {
+	for a != "" && b != "" {
+		aDigits, bDigits := isDigit(a[0]), isDigit(b[0])
+		if aDigits != bDigits {
+			if a[0] < b[0] {
+				return -1
+			}
+			return 1
+		}
+
+		var aRun, bRun string
+		aRun, a = leadingRun(a, aDigits)
+		bRun, b = leadingRun(b, bDigits)
+
+		if !aDigits {
+			if c := strings.Compare(aRun, bRun); c != 0 {
+				return c
+			}
+			continue
+		}
+
+		aValue, bValue := strings.TrimLeft(aRun, "0"), strings.TrimLeft(bRun, "0")
+		if len(aValue) != len(bValue) {
+			if len(aValue) < len(bValue) {
+				return -1
+			}
+			return 1
+		}
+		if c := strings.Compare(aValue, bValue); c != 0 {
+			return c
+		}
+		if len(aRun) != len(bRun) {
+			if len(aRun) < len(bRun) {
+				return -1
+			}
+			return 1
+		}
+	}
+	return len(a) - len(b)
+}
+
+// leadingRun splits s after its leading run of digits or non-digits.
+func leadingRun(s string, digits bool) (string, string) {
+	i := 1
+	for i < len(s) && isDigit(s[i]) == digits {
+		i++
+	}
+	return s[:i], s[i:]
+}
+
+func isDigit(b byte) bool { return b >= '0' && b <= '9' }
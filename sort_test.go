@@ -0,0 +1,73 @@
+package orange
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCompareHosts(t *testing.T) {
+	cases := []struct {
+		a, b string
+		want int
+	}{
+		{"host9", "host10", -1},
+		{"host10", "host9", 1},
+		{"host10", "host10", 0},
+		{"host1", "host01", -1},
+		{"host", "host1", -1},
+		{"a9b", "a10a", -1},
+		{"web2.b.example.com", "web1.c.example.com", -1},
+		{"web9.example.com", "web10.example.com", -1},
+		{"zeta", "alpha.example.com", -1},
+	}
+	for _, c := range cases {
+		got := CompareHosts(c.a, c.b)
+		switch {
+		case c.want < 0 && got >= 0, c.want > 0 && got <= 0, c.want == 0 && got != 0:
+			t.Errorf("%q, %q: GOT: %v; WANT: %v", c.a, c.b, got, c.want)
+		}
+	}
+}
+
+func TestSortHosts(t *testing.T) {
+	hosts := []string{
+		"web10.dc1.example.com",
+		"db2.dc1.example.com",
+		"web9.dc2.example.com",
+		"web9.dc1.example.com",
+		"db10.dc1.example.com",
+		"localhost",
+	}
+	SortHosts(hosts)
+	ensureResultsEqual(t, hosts, []string{
+		"localhost",
+		"db2.dc1.example.com",
+		"db10.dc1.example.com",
+		"web9.dc1.example.com",
+		"web10.dc1.example.com",
+		"web9.dc2.example.com",
+	})
+}
+
+func TestClientSorted(t *testing.T) {
+	h := func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("web10\nweb9\nweb1\n"))
+	}
+	withTestServer(t, h, func(server *httptest.Server) {
+		client, err := NewClient(&Config{
+			HTTPClient: server.Client(),
+			Servers:    []string{strings.TrimLeft(server.URL, "http://")},
+			Sorted:     true,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		values, err := client.Query("foo")
+		if err != nil {
+			t.Fatal(err)
+		}
+		ensureResultsEqual(t, values, []string{"web1", "web9", "web10"})
+	})
+}
@@ -0,0 +1,238 @@
+package orange
+
+import (
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// rollingWindowBuckets is the number of one second buckets each rolling counter
+// maintains, and must be at least as long as the longest reported window.
+const rollingWindowBuckets = 300
+
+// Stats is a snapshot of the counters a Client accumulates while resolving
+// queries.  Teams can use it to quantify how much data they pull from range
+// servers, and to spot pathological expressions that return far more results
+// than expected.
+type Stats struct {
+	// Queries is the number of queries the Client has sent to range servers,
+	// whether or not they were successful.
+	Queries uint64
+
+	// BytesReceived is the number of response body bytes read from range
+	// servers for successful responses.
+	BytesReceived uint64
+
+	// ResultLines is the number of results the client split from successful
+	// responses, so blank lines are not counted, and each comma separated
+	// result is.  Results read by QueryCallback and QueryResponse, which
+	// do not split responses, are not counted.
+	ResultLines uint64
+
+	// AverageResultsPerQuery is ResultLines divided by Queries, or 0 when the
+	// Client has not resolved any queries.
+	AverageResultsPerQuery float64
+
+	// Errors is the number of queries that returned an error after all
+	// retries were exhausted.
+	Errors uint64
+
+	// Retries is the number of times a failed query was sent again.
+	Retries uint64
+
+	// The following fields are the average per second rates of queries,
+	// errors, and retries over the most recent one and five minutes, allowing
+	// dashboards and admission logic to react to recent behavior rather than
+	// lifetime totals.
+	QueriesRate1m, QueriesRate5m float64
+	ErrorsRate1m, ErrorsRate5m   float64
+	RetriesRate1m, RetriesRate5m float64
+
+	// The following fields are only updated when caching is enabled by
+	// Config.CacheTTL.  CacheHits and CacheMisses count the queries answered
+	// from, or not found in, the cache, and CacheHitRatio is the fraction of
+	// lookups that were hits.  CacheRefreshes counts the queries whose results
+	// were stored in the cache, and AverageCacheRefresh is the mean time those
+	// queries took.
+	CacheHits           uint64
+	CacheMisses         uint64
+	CacheHitRatio       float64
+	CacheRefreshes      uint64
+	AverageCacheRefresh time.Duration
+
+	// CoalescedQueries is the number of queries that shared the results of
+	// another in-flight query for the same expression rather than being sent
+	// to a range server.  Only updated when Config.Coalesce is enabled.
+	CoalescedQueries uint64
+
+	// InFlight is the number of queries and writes being sent to range servers
+	// when the snapshot was taken.
+	InFlight int
+}
+
+// stats holds the counters for a Client.  All fields are updated atomically so
+// a single instance may be shared by concurrent queries.
+type stats struct {
+	queries       uint64
+	bytesReceived uint64
+	resultLines   uint64
+	errors        uint64
+	retries       uint64
+	cacheHits     uint64
+	cacheMisses   uint64
+	refreshes     uint64
+	refreshNanos  uint64
+	coalesced     uint64
+
+	queriesWindow rollingCounter
+	errorsWindow  rollingCounter
+	retriesWindow rollingCounter
+
+	now func() time.Time // allows tests to control the clock
+}
+
+func newStats() *stats {
+	return &stats{now: time.Now}
+}
+
+// Stats returns a snapshot of the Client's counters.
+func (c *Client) Stats() Stats {
+	ss := c.stats.snapshot()
+	ss.InFlight, _ = c.inFlight.wait()
+	return ss
+}
+
+func (s *stats) snapshot() Stats {
+	ss := Stats{
+		Queries:       atomic.LoadUint64(&s.queries),
+		BytesReceived: atomic.LoadUint64(&s.bytesReceived),
+		ResultLines:   atomic.LoadUint64(&s.resultLines),
+		Errors:        atomic.LoadUint64(&s.errors),
+		Retries:       atomic.LoadUint64(&s.retries),
+
+		CacheHits:        atomic.LoadUint64(&s.cacheHits),
+		CacheMisses:      atomic.LoadUint64(&s.cacheMisses),
+		CacheRefreshes:   atomic.LoadUint64(&s.refreshes),
+		CoalescedQueries: atomic.LoadUint64(&s.coalesced),
+	}
+	if ss.Queries > 0 {
+		ss.AverageResultsPerQuery = float64(ss.ResultLines) / float64(ss.Queries)
+	}
+	if lookups := ss.CacheHits + ss.CacheMisses; lookups > 0 {
+		ss.CacheHitRatio = float64(ss.CacheHits) / float64(lookups)
+	}
+	if ss.CacheRefreshes > 0 {
+		ss.AverageCacheRefresh = time.Duration(atomic.LoadUint64(&s.refreshNanos) / ss.CacheRefreshes)
+	}
+
+	now := s.now()
+	ss.QueriesRate1m, ss.QueriesRate5m = s.queriesWindow.rates(now)
+	ss.ErrorsRate1m, ss.ErrorsRate5m = s.errorsWindow.rates(now)
+	ss.RetriesRate1m, ss.RetriesRate5m = s.retriesWindow.rates(now)
+
+	return ss
+}
+
+func (s *stats) addQuery() {
+	atomic.AddUint64(&s.queries, 1)
+	s.queriesWindow.add(s.now())
+}
+
+func (s *stats) addError() {
+	atomic.AddUint64(&s.errors, 1)
+	s.errorsWindow.add(s.now())
+}
+
+func (s *stats) addRetry() {
+	atomic.AddUint64(&s.retries, 1)
+	s.retriesWindow.add(s.now())
+}
+
+func (s *stats) addCacheHit() {
+	atomic.AddUint64(&s.cacheHits, 1)
+}
+
+func (s *stats) addCacheMiss() {
+	atomic.AddUint64(&s.cacheMisses, 1)
+}
+
+func (s *stats) addCacheRefresh(duration time.Duration) {
+	atomic.AddUint64(&s.refreshes, 1)
+	atomic.AddUint64(&s.refreshNanos, uint64(duration))
+}
+
+func (s *stats) addCoalesced() {
+	atomic.AddUint64(&s.coalesced, 1)
+}
+
+func (s *stats) addReceived(bytes uint64) {
+	atomic.AddUint64(&s.bytesReceived, bytes)
+}
+
+func (s *stats) addResults(results int) {
+	atomic.AddUint64(&s.resultLines, uint64(results))
+}
+
+// countingReadCloser wraps a response body, counting the bytes read from it.
+type countingReadCloser struct {
+	io.ReadCloser
+	bytes uint64
+}
+
+func (crc *countingReadCloser) Read(p []byte) (int, error) {
+	n, err := crc.ReadCloser.Read(p)
+	crc.bytes += uint64(n)
+	return n, err
+}
+
+// rollingCounter counts events in one second buckets, so it can report the
+// rate of events over the most recent minutes.
+type rollingCounter struct {
+	lock    sync.Mutex
+	buckets [rollingWindowBuckets]uint64
+	last    int64 // unix second of the most recently used bucket
+}
+
+// advance zeroes buckets for the seconds elapsed since the most recently used
+// bucket.  It must be called with the lock held.
+func (rc *rollingCounter) advance(now time.Time) int64 {
+	sec := now.Unix()
+	if elapsed := sec - rc.last; elapsed >= rollingWindowBuckets {
+		rc.buckets = [rollingWindowBuckets]uint64{}
+	} else {
+		for i := rc.last + 1; i <= sec; i++ {
+			rc.buckets[i%rollingWindowBuckets] = 0
+		}
+	}
+	if sec > rc.last {
+		rc.last = sec
+	}
+	return rc.last
+}
+
+func (rc *rollingCounter) add(now time.Time) {
+	rc.lock.Lock()
+	sec := rc.advance(now)
+	rc.buckets[sec%rollingWindowBuckets]++
+	rc.lock.Unlock()
+}
+
+// rates returns the average per second rate of events over the most recent one
+// and five minutes.
+func (rc *rollingCounter) rates(now time.Time) (float64, float64) {
+	rc.lock.Lock()
+	defer rc.lock.Unlock()
+
+	sec := rc.advance(now)
+
+	var sum1m, sum5m uint64
+	for i := int64(0); i < rollingWindowBuckets; i++ {
+		count := rc.buckets[(sec-i)%rollingWindowBuckets]
+		if i < 60 {
+			sum1m += count
+		}
+		sum5m += count
+	}
+	return float64(sum1m) / 60, float64(sum5m) / 300
+}
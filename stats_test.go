@@ -0,0 +1,176 @@
+package orange
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStats(t *testing.T) {
+	t.Run("counts bytes and result lines", func(t *testing.T) {
+		h := func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("result1\nresult2\nresult3"))
+		}
+		withClient(t, h, func(client *Client) {
+			for i := 0; i < 2; i++ {
+				if _, err := client.Query("foo"); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			stats := client.Stats()
+
+			if got, want := stats.Queries, uint64(2); got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+			if got, want := stats.BytesReceived, uint64(46); got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+			if got, want := stats.ResultLines, uint64(6); got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+			if got, want := stats.AverageResultsPerQuery, 3.0; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+		})
+	})
+
+	t.Run("counts results rather than newlines", func(t *testing.T) {
+		h := func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.RawQuery {
+			case "csv":
+				w.Header().Set("Content-Type", "text/csv")
+				w.Write([]byte("result1,result2, result3\n"))
+			default:
+				w.Write([]byte("result1\r\n\r\nresult2\n\n\n"))
+			}
+		}
+		withClient(t, h, func(client *Client) {
+			if _, err := client.Query("csv"); err != nil {
+				t.Fatal(err)
+			}
+			if got, want := client.Stats().ResultLines, uint64(3); got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+
+			if _, err := client.Query("blank"); err != nil {
+				t.Fatal(err)
+			}
+			if got, want := client.Stats().ResultLines, uint64(5); got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+
+			err := client.QueryForEach(context.Background(), "blank", func(string) error { return nil })
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := client.Stats().ResultLines, uint64(7); got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+		})
+	})
+
+	t.Run("ignores error bodies", func(t *testing.T) {
+		h := func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "body1\nbody2\n", http.StatusBadGateway)
+		}
+		withClient(t, h, func(client *Client) {
+			_, err := client.Query("foo")
+			ensureError(t, err, http.StatusText(http.StatusBadGateway))
+
+			stats := client.Stats()
+
+			if got, want := stats.Queries, uint64(1); got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+			if got, want := stats.BytesReceived, uint64(0); got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+			if got, want := stats.AverageResultsPerQuery, 0.0; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+		})
+	})
+}
+
+func TestRollingCounter(t *testing.T) {
+	start := time.Date(2019, time.June, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("empty", func(t *testing.T) {
+		var rc rollingCounter
+		r1, r5 := rc.rates(start)
+		if got, want := r1, 0.0; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := r5, 0.0; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("recent events", func(t *testing.T) {
+		var rc rollingCounter
+		for i := 0; i < 60; i++ {
+			rc.add(start.Add(time.Duration(i) * time.Second))
+		}
+		r1, r5 := rc.rates(start.Add(59 * time.Second))
+		if got, want := r1, 1.0; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := r5, 0.2; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("events age out of window", func(t *testing.T) {
+		var rc rollingCounter
+		for i := 0; i < 30; i++ {
+			rc.add(start)
+		}
+		r1, r5 := rc.rates(start.Add(2 * time.Minute))
+		if got, want := r1, 0.0; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := r5, 0.1; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		r1, r5 = rc.rates(start.Add(10 * time.Minute))
+		if got, want := r5, 0.0; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+}
+
+func TestStatsErrorsAndRetries(t *testing.T) {
+	h := func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}
+	withTestServer(t, h, func(server *httptest.Server) {
+		client, err := NewClient(&Config{
+			HTTPClient:    server.Client(),
+			RetryCallback: func(error) bool { return true },
+			RetryCount:    2,
+			Servers:       []string{strings.TrimLeft(server.URL, "http://")},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = client.Query("foo")
+		ensureError(t, err, http.StatusText(http.StatusServiceUnavailable))
+
+		stats := client.Stats()
+
+		if got, want := stats.Errors, uint64(1); got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := stats.Retries, uint64(2); got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := stats.RetriesRate1m, 2.0/60; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+}
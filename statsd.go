@@ -0,0 +1,187 @@
+package orange
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultStatsdInterval is used when no Interval is provided to control how
+// often a StatsdEmitter flushes client metrics.
+const DefaultStatsdInterval = 10 * time.Second
+
+// DefaultStatsdPrefix is used when no Prefix is provided to name the metrics a
+// StatsdEmitter sends.
+const DefaultStatsdPrefix = "orange."
+
+// StatsdConfig specifies where and how a StatsdEmitter sends client metrics.
+type StatsdConfig struct {
+	// Address is the host:port of the statsd or DogStatsD agent that receives
+	// UDP datagrams.  Required.
+	Address string
+
+	// Interval is the amount of time between flushes.  When zero,
+	// DefaultStatsdInterval is used.
+	Interval time.Duration
+
+	// Prefix is prepended to each metric name.  When empty,
+	// DefaultStatsdPrefix is used.
+	Prefix string
+
+	// Tags is an optional list of DogStatsD tags, such as "env:prod", appended
+	// to each metric.  Leave nil when sending to a plain statsd agent that does
+	// not understand tags.
+	Tags []string
+}
+
+// StatsdEmitter periodically flushes a Client's Stats to a statsd endpoint, for
+// infrastructures that have not adopted Prometheus.  Cumulative counters are
+// sent as statsd counters of the change since the previous flush, and rates and
+// averages are sent as gauges.
+//
+//     emitter, err := orange.NewStatsdEmitter(client, &orange.StatsdConfig{
+//         Address: "localhost:8125",
+//         Tags:    []string{"service:example"},
+//     })
+//     if err != nil {
+//         fmt.Fprintf(os.Stderr, "%s\n", err)
+//         os.Exit(1)
+//     }
+//     defer emitter.Close()
+type StatsdEmitter struct {
+	client *Client
+	conn   net.Conn
+	prefix string
+	tags   string
+
+	lock     sync.Mutex
+	previous Stats
+
+	done    chan struct{}
+	stopped chan struct{}
+	once    sync.Once
+}
+
+// NewStatsdEmitter returns a new instance that flushes the metrics of the
+// specified Client to a statsd endpoint until it is closed.
+func NewStatsdEmitter(client *Client, config *StatsdConfig) (*StatsdEmitter, error) {
+	if client == nil {
+		return nil, errors.New("cannot create StatsdEmitter without a Client")
+	}
+	if config.Address == "" {
+		return nil, errors.New("cannot create StatsdEmitter without an Address")
+	}
+	if config.Interval < 0 {
+		return nil, fmt.Errorf("cannot create StatsdEmitter with negative Interval: %s", config.Interval)
+	}
+
+	conn, err := net.Dial("udp", config.Address)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create StatsdEmitter: %s", err)
+	}
+
+	interval := config.Interval
+	if interval == 0 {
+		interval = DefaultStatsdInterval
+	}
+
+	prefix := config.Prefix
+	if prefix == "" {
+		prefix = DefaultStatsdPrefix
+	}
+
+	e := &StatsdEmitter{
+		client:  client,
+		conn:    conn,
+		prefix:  prefix,
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+
+	if len(config.Tags) > 0 {
+		e.tags = "|#" + strings.Join(config.Tags, ",")
+	}
+
+	go e.run(interval)
+
+	return e, nil
+}
+
+func (e *StatsdEmitter) run(interval time.Duration) {
+	defer close(e.stopped)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-e.done:
+			return
+		case <-ticker.C:
+			_ = e.Flush() // a lost datagram is not worth reporting
+		}
+	}
+}
+
+// Close sends a final flush of metrics, then stops the emitter and releases its
+// network resources.
+func (e *StatsdEmitter) Close() error {
+	var err error
+	e.once.Do(func() {
+		close(e.done)
+		<-e.stopped
+		err = e.Flush()
+		if err2 := e.conn.Close(); err == nil {
+			err = err2
+		}
+	})
+	return err
+}
+
+// Flush immediately sends the Client's current metrics to the statsd endpoint.
+func (e *StatsdEmitter) Flush() error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	current := e.client.Stats()
+	previous := e.previous
+	e.previous = current
+
+	var buf bytes.Buffer
+
+	e.counter(&buf, "queries", current.Queries-previous.Queries)
+	e.counter(&buf, "errors", current.Errors-previous.Errors)
+	e.counter(&buf, "retries", current.Retries-previous.Retries)
+	e.counter(&buf, "bytes_received", current.BytesReceived-previous.BytesReceived)
+	e.counter(&buf, "result_lines", current.ResultLines-previous.ResultLines)
+	e.counter(&buf, "cache_hits", current.CacheHits-previous.CacheHits)
+	e.counter(&buf, "cache_misses", current.CacheMisses-previous.CacheMisses)
+	e.counter(&buf, "cache_refreshes", current.CacheRefreshes-previous.CacheRefreshes)
+	e.counter(&buf, "coalesced_queries", current.CoalescedQueries-previous.CoalescedQueries)
+
+	e.gauge(&buf, "average_results_per_query", current.AverageResultsPerQuery)
+	e.gauge(&buf, "queries_rate_1m", current.QueriesRate1m)
+	e.gauge(&buf, "queries_rate_5m", current.QueriesRate5m)
+	e.gauge(&buf, "errors_rate_1m", current.ErrorsRate1m)
+	e.gauge(&buf, "errors_rate_5m", current.ErrorsRate5m)
+	e.gauge(&buf, "retries_rate_1m", current.RetriesRate1m)
+	e.gauge(&buf, "retries_rate_5m", current.RetriesRate5m)
+	e.gauge(&buf, "cache_hit_ratio", current.CacheHitRatio)
+	e.gauge(&buf, "cache_refresh_average_ms", current.AverageCacheRefresh.Seconds()*1000)
+	e.gauge(&buf, "in_flight", float64(current.InFlight))
+
+	_, err := e.conn.Write(bytes.TrimRight(buf.Bytes(), "\n"))
+	return err
+}
+
+func (e *StatsdEmitter) counter(buf *bytes.Buffer, name string, value uint64) {
+	fmt.Fprintf(buf, "%s%s:%d|c%s\n", e.prefix, name, value, e.tags)
+}
+
+func (e *StatsdEmitter) gauge(buf *bytes.Buffer, name string, value float64) {
+	fmt.Fprintf(buf, "%s%s:%g|g%s\n", e.prefix, name, value, e.tags)
+}
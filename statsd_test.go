@@ -0,0 +1,79 @@
+package orange
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStatsdEmitter(t *testing.T) {
+	listener, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	read := func() []string {
+		t.Helper()
+		buf := make([]byte, 4096)
+		if err := listener.SetReadDeadline(time.Now().Add(time.Second)); err != nil {
+			t.Fatal(err)
+		}
+		n, _, err := listener.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return strings.Split(string(buf[:n]), "\n")
+	}
+
+	h := func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("result1\nresult2\n"))
+	}
+	withClient(t, h, func(client *Client) {
+		emitter, err := NewStatsdEmitter(client, &StatsdConfig{
+			Address:  listener.LocalAddr().String(),
+			Interval: time.Hour,
+			Prefix:   "test.",
+			Tags:     []string{"env:test", "service:orange"},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err = client.Query("foo"); err != nil {
+			t.Fatal(err)
+		}
+
+		if err = emitter.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		metrics := read()
+		if got, want := metrics[0], "test.queries:1|c|#env:test,service:orange"; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := metrics[4], "test.result_lines:2|c|#env:test,service:orange"; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := metrics[9], "test.average_results_per_query:2|g|#env:test,service:orange"; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+
+		// Counters report the change since the previous flush.
+		if err = emitter.Close(); err != nil {
+			t.Fatal(err)
+		}
+		metrics = read()
+		if got, want := metrics[0], "test.queries:0|c|#env:test,service:orange"; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("requires address", func(t *testing.T) {
+		withClient(t, func(http.ResponseWriter, *http.Request) {}, func(client *Client) {
+			_, err := NewStatsdEmitter(client, &StatsdConfig{})
+			ensureError(t, err, "without an Address")
+		})
+	})
+}
@@ -0,0 +1,143 @@
+package orange
+
+import (
+	"context"
+	"io"
+)
+
+// Stream yields the results of a query one at a time as they are read from the
+// response body, so expansions of hundreds of megabytes never need to be held
+// in memory.  The response body is only read as fast as the results are
+// consumed, so a slow consumer slows the transfer rather than letting results
+// accumulate.  Use it like a bufio.Scanner:
+//
+//     stream := client.QueryStream(ctx, "%someHugeCluster")
+//     defer stream.Close()
+//     for stream.Next() {
+//         fmt.Println(stream.Value())
+//     }
+//     if err := stream.Err(); err != nil {
+//         return err
+//     }
+type Stream struct {
+	cancel  context.CancelFunc
+	values  chan string
+	done    chan struct{} // closed after err is set
+	value   string
+	err     error
+	closed  bool
+	drained bool
+}
+
+// QueryStream sends the query expression to the range servers with the
+// provided query context, and returns a Stream yielding its results.  Like
+// QueryForEach, results are stripped of carriage returns and blank results are
+// skipped, but they are neither cached, coalesced, de-duplicated, nor sorted.
+//
+// Once the first result has been yielded, a failure reading the remainder of
+// the response is not retried, so results are never yielded twice.  The Stream
+// must be closed to release its resources when not read until Next returns
+// false.
+func (c *Client) QueryStream(ctx context.Context, expression string) *Stream {
+	return newStream(ctx, func(ctx context.Context, send func(string) error) error {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		var meta Response
+		var delivered bool
+		var streamErr error
+
+		err := c.queryCallback(ctx, expression, func(ior io.Reader) error {
+			err := c.scanLines(ior, meta.Delimiter, func(line string) error {
+				if err := send(line); err != nil {
+					return err
+				}
+				delivered = true
+				return nil
+			})
+			if err != nil && delivered {
+				// Stop before another attempt could yield the same results
+				// again.
+				streamErr = err
+				cancel()
+			}
+			return err
+		}, &meta)
+
+		if streamErr != nil {
+			return streamErr
+		}
+		return err
+	})
+}
+
+// newStream returns a Stream yielding the values that produce sends.  Each send
+// waits for the consumer, and returns the context error once the stream is
+// closed.
+func newStream(ctx context.Context, produce func(ctx context.Context, send func(string) error) error) *Stream {
+	ctx, cancel := context.WithCancel(ctx)
+	s := &Stream{
+		cancel: cancel,
+		values: make(chan string), // unbuffered, so reading waits for the consumer
+		done:   make(chan struct{}),
+	}
+
+	send := func(value string) error {
+		select {
+		case s.values <- value:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	go func() {
+		s.err = produce(ctx, send)
+		close(s.done)
+		cancel()
+	}()
+
+	return s
+}
+
+// Next advances the stream to the next result, which is then available from
+// Value.  It returns false when there are no more results, either because the
+// response was completely read, or because of an error, which Err returns.
+func (s *Stream) Next() bool {
+	if s.drained {
+		return false
+	}
+	select {
+	case s.value = <-s.values:
+		return true
+	case <-s.done:
+		s.drained = true
+		s.value = ""
+		return false
+	}
+}
+
+// Value returns the most recent result yielded by Next.
+func (s *Stream) Value() string { return s.value }
+
+// Err returns the first error encountered by the stream, after Next returns
+// false.  It returns nil when the response was completely read, or when the
+// stream was closed before its results were exhausted.
+func (s *Stream) Err() error {
+	if !s.drained || s.closed {
+		return nil
+	}
+	return s.err
+}
+
+// Close stops the query when it has not completed, and waits for its resources
+// to be released.  It is safe to call Close more than once.
+func (s *Stream) Close() error {
+	if !s.drained {
+		s.closed = true
+		s.cancel()
+		<-s.done
+		s.drained = true
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package orange
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestQueryStream(t *testing.T) {
+	t.Run("all results", func(t *testing.T) {
+		h := func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("result1\r\n\nresult2\nresult3\n"))
+		}
+		withClient(t, h, func(client *Client) {
+			stream := client.QueryStream(context.Background(), "foo")
+			defer stream.Close()
+
+			var values []string
+			for stream.Next() {
+				values = append(values, stream.Value())
+			}
+			if err := stream.Err(); err != nil {
+				t.Fatal(err)
+			}
+			ensureStringSlicesMatch(t, values, []string{"result1", "result2", "result3"})
+
+			if got, want := stream.Next(), false; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+		})
+	})
+
+	t.Run("close before exhausted", func(t *testing.T) {
+		h := func(w http.ResponseWriter, r *http.Request) {
+			for i := 0; i < 100000; i++ {
+				if _, err := fmt.Fprintf(w, "host%d\n", i); err != nil {
+					return
+				}
+			}
+		}
+		withClient(t, h, func(client *Client) {
+			stream := client.QueryStream(context.Background(), "foo")
+			for i := 0; i < 3; i++ {
+				if !stream.Next() {
+					t.Fatal(stream.Err())
+				}
+				if got, want := stream.Value(), fmt.Sprintf("host%d", i); got != want {
+					t.Errorf("GOT: %v; WANT: %v", got, want)
+				}
+			}
+			if err := stream.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if got, want := stream.Next(), false; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+			if err := stream.Err(); err != nil {
+				t.Errorf("GOT: %v; WANT: %v", err, nil)
+			}
+			if err := stream.Close(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	})
+
+	t.Run("error", func(t *testing.T) {
+		h := func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("RangeException", "NO SUCH CLUSTER")
+		}
+		withClient(t, h, func(client *Client) {
+			stream := client.QueryStream(context.Background(), "foo")
+			defer stream.Close()
+			if got, want := stream.Next(), false; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+			ensureError(t, stream.Err(), "NO SUCH CLUSTER")
+		})
+	})
+
+	t.Run("line too long after results are not retried", func(t *testing.T) {
+		var requests int
+		h := func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			w.Write([]byte("result1\n" + strings.Repeat("x", 100000) + "\n"))
+		}
+		withClient(t, h, func(client *Client) {
+			stream := client.QueryStream(context.Background(), "foo")
+			defer stream.Close()
+			var values []string
+			for stream.Next() {
+				values = append(values, stream.Value())
+			}
+			ensureError(t, stream.Err(), "exceeds maximum length")
+			ensureStringSlicesMatch(t, values, []string{"result1"})
+			if got, want := requests, 1; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+		})
+	})
+}
@@ -0,0 +1,116 @@
+package orange
+
+import "context"
+
+// QueryUnion returns the union of the values of expressions, without
+// duplicates, preserving the order in which values first appear.  Rather than
+// sending a request for each expression, it merges them into braced union
+// expressions, such as "{%cluster1},{%cluster2}", each as long as possible
+// while still being sent using GET, so many small expressions need only a few
+// round trips.
+//
+//     hosts, err := client.QueryUnion(ctx, []string{"%cluster1", "%cluster2", "host42"})
+//
+// Because the values of a merged expression cannot be attributed to the
+// expressions in it, QueryUnion is only useful to callers that need the union.
+// Callers that need the values of each expression should use Queries, with
+// Config.BatchRequests set for range servers that support batch requests.
+//
+// The merged expressions are queried concurrently using QueryCtx.  When one or
+// more of them fail, QueryUnion returns a *BatchError mapping each failed
+// merged expression to its error.
+func (c *Client) QueryUnion(ctx context.Context, expressions []string) ([]string, error) {
+	merged := mergeExpressions(expressions, c.unionBudget())
+	switch len(merged) {
+	case 0:
+		return nil, nil
+	case 1:
+		values, err := c.QueryCtx(ctx, merged[0])
+		if err != nil {
+			return nil, err
+		}
+		return Unique(values), nil
+	}
+
+	results, err := queriesCtx(ctx, merged, c.QueryCtx, nil)
+	if err != nil {
+		return nil, err
+	}
+	var values []string
+	for _, result := range results {
+		values = append(values, result...)
+	}
+	return Unique(values), nil
+}
+
+// unionBudget returns the maximum escaped length of an expression that is sent
+// to any of the servers using GET.
+func (c *Client) unionBudget() int {
+	var longest int
+	for _, server := range c.servers.values {
+		if n := len(c.serverURL(server)) + len(c.path) + 1; n > longest {
+			longest = n
+		}
+	}
+	return defaultQueryURILengthThreshold - longest
+}
+
+// escapedComma and escapedBraces are the escaped lengths of the comma
+// separating the terms of a merged expression, and of the braces surrounding
+// each term.
+const (
+	escapedComma  = len("%2C")
+	escapedBraces = len("%7B%7D")
+)
+
+// mergeExpressions returns expressions merged into as few braced union
+// expressions as possible whose escaped lengths do not exceed budget, in
+// order.  An expression longer than budget by itself is returned unmerged.
+func mergeExpressions(expressions []string, budget int) []string {
+	var merged []string
+	var current []byte
+	var currentLength int
+
+	for _, expression := range expressions {
+		if expression == "" {
+			continue
+		}
+		length := newEscapedExpression(expression).length + escapedBraces
+		if len(current) > 0 && currentLength+escapedComma+length > budget {
+			merged = append(merged, string(current))
+			current, currentLength = current[:0], 0
+		}
+		if len(current) > 0 {
+			current = append(current, ',')
+			currentLength += escapedComma
+		}
+		current = append(current, '{')
+		current = append(current, expression...)
+		current = append(current, '}')
+		currentLength += length
+	}
+	if len(current) > 0 {
+		merged = append(merged, string(current))
+	}
+	return merged
+}
+
+// queryUnion returns the union of the values query returns for each of the
+// expressions, without duplicates, querying each expression separately.
+func queryUnion(ctx context.Context, expressions []string, query func(context.Context, string) ([]string, error)) ([]string, error) {
+	var values []string
+	for _, expression := range expressions {
+		if expression == "" {
+			continue
+		}
+		v, err := query(ctx, expression)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v...)
+	}
+	if values == nil {
+		return nil, nil
+	}
+	return Unique(values), nil
+}
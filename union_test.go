@@ -0,0 +1,62 @@
+package orange
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestMergeExpressions(t *testing.T) {
+	t.Run("fits", func(t *testing.T) {
+		ensureStringSlicesMatch(t, mergeExpressions([]string{"a", "", "%b"}, 100), []string{"{a},{%b}"})
+	})
+
+	t.Run("split at budget", func(t *testing.T) {
+		// Each term is 7 escaped bytes, and each comma another 3.
+		ensureStringSlicesMatch(t, mergeExpressions([]string{"a", "b", "c", "d", "e"}, 17), []string{"{a},{b}", "{c},{d}", "{e}"})
+	})
+
+	t.Run("long expression", func(t *testing.T) {
+		ensureStringSlicesMatch(t, mergeExpressions([]string{"a", "longer", "b"}, 10), []string{"{a}", "{longer}", "{b}"})
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		ensureStringSlicesMatch(t, mergeExpressions(nil, 100), nil)
+	})
+}
+
+func TestQueryUnion(t *testing.T) {
+	var requests int32
+	withClient(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if got, want := r.Method, http.MethodGet; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		expression, err := url.QueryUnescape(r.URL.RawQuery)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Answer each term with itself, and with a host common to every term.
+		for _, term := range strings.Split(expression, ",") {
+			fmt.Fprintf(w, "%s\ncommon\n", strings.Trim(term, "{}"))
+		}
+	}, func(client *Client) {
+		expressions := make([]string, 1000)
+		for i := range expressions {
+			expressions[i] = fmt.Sprintf("host%d", i)
+		}
+		values, err := client.QueryUnion(context.Background(), expressions)
+		ensureError(t, err)
+		ensureStringSlicesMatch(t, values[:3], []string{"host0", "common", "host1"})
+		if got, want := len(values), len(expressions)+1; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := atomic.LoadInt32(&requests), int32(len(expressions)); got <= 1 || got >= want/10 {
+			t.Errorf("GOT: %v; WANT: between 1 and %v", got, want/10)
+		}
+	})
+}
@@ -0,0 +1,45 @@
+package orange
+
+import (
+	"context"
+	"net/http"
+)
+
+// userAgentKey is the context key of a per-query user agent.
+type userAgentKey struct{}
+
+// WithQueryUserAgent returns a copy of ctx that causes queries sent with it to
+// use userAgent as their User-Agent header, rather than Config.UserAgent, so
+// traffic from a particular subsystem of a larger application can be
+// attributed to it.
+//
+//     ctx = orange.WithQueryUserAgent(ctx, "billing-reports/2.1")
+//     values, err := client.QueryCtx(ctx, "%billing")
+//
+// Queries answered from the cache send no request, and a query coalesced with
+// another in flight for the same expression shares its request and therefore
+// its user agent.
+func WithQueryUserAgent(ctx context.Context, userAgent string) context.Context {
+	return context.WithValue(ctx, userAgentKey{}, userAgent)
+}
+
+// requestUserAgent returns the user agent for requests sent with ctx, or the
+// empty string when the default Go user agent should be used.
+func (c *Client) requestUserAgent(ctx context.Context) string {
+	if userAgent, ok := ctx.Value(userAgentKey{}).(string); ok && userAgent != "" {
+		return userAgent
+	}
+	return c.userAgent
+}
+
+// setHeaders sets the headers of a request sent with ctx: Config.Headers, the
+// user agent, and the query priority.
+func (c *Client) setHeaders(ctx context.Context, header http.Header) {
+	for key, values := range c.headers {
+		header[key] = values[:len(values):len(values)] // appending copies
+	}
+	if userAgent := c.requestUserAgent(ctx); userAgent != "" {
+		header.Set("User-Agent", userAgent)
+	}
+	setPriorityHeader(ctx, header)
+}
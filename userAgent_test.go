@@ -0,0 +1,41 @@
+package orange
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestWithQueryUserAgent(t *testing.T) {
+	var userAgent string
+	withClient(t, func(w http.ResponseWriter, r *http.Request) {
+		userAgent = r.Header.Get("User-Agent")
+		_, _ = w.Write([]byte("host1\n"))
+	}, func(client *Client) {
+		t.Run("client default", func(t *testing.T) {
+			_, err := client.QueryCtx(context.Background(), "%someCluster")
+			ensureError(t, err)
+			if got, want := userAgent, "custom-user-agent"; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+		})
+
+		t.Run("override", func(t *testing.T) {
+			ctx := WithQueryUserAgent(context.Background(), "billing-reports/2.1")
+			_, err := client.QueryCtx(ctx, "%someCluster")
+			ensureError(t, err)
+			if got, want := userAgent, "billing-reports/2.1"; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+		})
+
+		t.Run("empty override", func(t *testing.T) {
+			ctx := WithQueryUserAgent(context.Background(), "")
+			_, err := client.QueryCtx(ctx, "%someCluster")
+			ensureError(t, err)
+			if got, want := userAgent, "custom-user-agent"; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+		})
+	})
+}
@@ -0,0 +1,139 @@
+package orange
+
+import (
+	"errors"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestVCR(t *testing.T) {
+	dir := t.TempDir()
+	var requests int
+
+	h := func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if strings.Contains(r.URL.RawQuery, "bogus") {
+			w.Header().Set("RangeException", "NO SUCH CLUSTER")
+			return
+		}
+		w.Write([]byte("host1\nhost2\n"))
+	}
+
+	withTestServer(t, h, func(server *httptest.Server) {
+		newVCRClient := func(mode VCRMode) *Client {
+			client, err := NewClient(&Config{
+				HTTPClient: &VCR{Dir: dir, Doer: server.Client(), Mode: mode},
+				Servers:    []string{strings.TrimPrefix(server.URL, "http://")},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			return client
+		}
+
+		long := strings.Repeat("a", defaultQueryURILengthThreshold)
+
+		for _, mode := range []VCRMode{VCRAuto, VCRAuto, VCRReplay} {
+			client := newVCRClient(mode)
+
+			values, err := client.Query("%cluster1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			ensureStringSlicesMatch(t, values, []string{"host1", "host2"})
+
+			values, err = client.Query(long)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ensureStringSlicesMatch(t, values, []string{"host1", "host2"})
+
+			_, err = client.Query("%bogus")
+			if got, want := IsRangeException(err), true; got != want {
+				t.Errorf("GOT: %v; WANT: %v", err, want)
+			}
+		}
+
+		// Only the first pass reached the server.
+		if got, want := requests, 3; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+
+		if _, err := os.Stat(filepath.Join(dir, "%25cluster1.http")); err != nil {
+			t.Error(err)
+		}
+
+		t.Run("replay without recording", func(t *testing.T) {
+			_, err := newVCRClient(VCRReplay).Query("%cluster2")
+			if got, want := errors.Is(err, fs.ErrNotExist), true; got != want {
+				t.Errorf("GOT: %v; WANT: %v", err, want)
+			}
+			if got, want := requests, 3; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+		})
+
+		t.Run("record", func(t *testing.T) {
+			if _, err := newVCRClient(VCRRecord).Query("%cluster1"); err != nil {
+				t.Fatal(err)
+			}
+			if got, want := requests, 4; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+		})
+	})
+}
+
+func TestVCRMethodFallback(t *testing.T) {
+	dir := t.TempDir()
+	var requests int
+
+	// Server only accepts PUT, rejecting GET as if its URI were too long, so
+	// the client falls back from GET to PUT.
+	h := func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Method != http.MethodPut {
+			http.Error(w, "uri too long", http.StatusRequestURITooLong)
+			return
+		}
+		w.Write([]byte("host1\nhost2\n"))
+	}
+
+	withTestServer(t, h, func(server *httptest.Server) {
+		for _, mode := range []VCRMode{VCRAuto, VCRAuto, VCRReplay} {
+			client, err := NewClient(&Config{
+				HTTPClient: &VCR{Dir: dir, Doer: server.Client(), Mode: mode},
+				Servers:    []string{strings.TrimPrefix(server.URL, "http://")},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			values, err := client.Query("%cluster1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			ensureStringSlicesMatch(t, values, []string{"host1", "host2"})
+		}
+
+		// Only the first pass reached the server, once with GET and once with
+		// PUT.
+		if got, want := requests, 2; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+}
+
+func TestVCRFileName(t *testing.T) {
+	if got, want := vcrFileName("%a/b"), "%25a%2Fb.http"; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+	if got, want := vcrFileName(strings.Repeat("a", 300)), "sha256-"; !strings.HasPrefix(got, want) {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+}
@@ -0,0 +1,70 @@
+package orange
+
+import (
+	"context"
+	"sync"
+)
+
+// inFlight counts the queries and writes a client is sending, and signals when
+// none are.
+type inFlight struct {
+	lock  sync.Mutex
+	count int
+	idle  chan struct{} // closed when count drops to zero
+}
+
+func newInFlight() *inFlight {
+	idle := make(chan struct{})
+	close(idle)
+	return &inFlight{idle: idle}
+}
+
+func (f *inFlight) add() {
+	f.lock.Lock()
+	if f.count == 0 {
+		f.idle = make(chan struct{})
+	}
+	f.count++
+	f.lock.Unlock()
+}
+
+func (f *inFlight) done() {
+	f.lock.Lock()
+	f.count--
+	if f.count == 0 {
+		close(f.idle)
+	}
+	f.lock.Unlock()
+}
+
+// wait returns the count of in-flight requests, and a channel closed once there
+// are none.
+func (f *inFlight) wait() (int, <-chan struct{}) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.count, f.idle
+}
+
+// Wait blocks until the client has no queries or writes in flight, or until
+// ctx is done, in which case it returns ctx.Err().  Graceful shutdown sequences
+// call it after they stop issuing new requests, so outstanding range lookups
+// and writes finish before the process exits and their connections are torn
+// down.
+//
+//     server.Shutdown(ctx) // stop accepting work that issues queries
+//     if err := client.Wait(ctx); err != nil {
+//         log.Printf("abandoning range queries: %s", err)
+//     }
+//
+// Queries and writes started while others are in flight extend the wait.
+// Only requests sent to range servers are counted; queries answered from the
+// cache never wait on a server.
+func (c *Client) Wait(ctx context.Context) error {
+	_, idle := c.inFlight.wait()
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,86 @@
+package orange
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestWait(t *testing.T) {
+	t.Run("idle", func(t *testing.T) {
+		withClient(t, func(w http.ResponseWriter, r *http.Request) {}, func(client *Client) {
+			ensureError(t, client.Wait(context.Background()))
+		})
+	})
+
+	t.Run("in flight", func(t *testing.T) {
+		started := make(chan struct{})
+		release := make(chan struct{})
+
+		withClient(t, func(w http.ResponseWriter, r *http.Request) {
+			close(started)
+			<-release
+			_, _ = w.Write([]byte("host1\n"))
+		}, func(client *Client) {
+			queried := make(chan error)
+			go func() {
+				_, err := client.Query("%someCluster")
+				queried <- err
+			}()
+			<-started
+
+			if got, want := client.Stats().InFlight, 1; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			err := client.Wait(ctx)
+			cancel()
+			if got, want := err, context.DeadlineExceeded; !errors.Is(got, want) {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+
+			close(release)
+			ensureError(t, client.Wait(context.Background()))
+			ensureError(t, <-queried)
+
+			if got, want := client.Stats().InFlight, 0; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+		})
+	})
+
+	t.Run("write in flight", func(t *testing.T) {
+		started := make(chan struct{})
+		release := make(chan struct{})
+
+		withWriteClient(t, func(w http.ResponseWriter, r *http.Request) {
+			close(started)
+			<-release
+			w.WriteHeader(http.StatusNoContent)
+		}, func(client *Client) {
+			written := make(chan error)
+			go func() {
+				written <- client.AddToCluster(context.Background(), "webservers", []string{"web42"})
+			}()
+			<-started
+
+			if got, want := client.Stats().InFlight, 1; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			err := client.Wait(ctx)
+			cancel()
+			if got, want := err, context.DeadlineExceeded; !errors.Is(got, want) {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+
+			close(release)
+			ensureError(t, client.Wait(context.Background()))
+			ensureError(t, <-written)
+		})
+	})
+}
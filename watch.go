@@ -0,0 +1,109 @@
+package orange
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// ChangeEvent describes a change in the results of a watched expression.
+type ChangeEvent struct {
+	// Expression is the watched expression.
+	Expression string
+
+	// Added are the values in the new results that were not in the previous
+	// results, and Removed are those in the previous results that are not in
+	// the new results, as returned by Diff.
+	Added, Removed []string
+
+	// Results are the new results in full.
+	Results []string
+
+	// Err is the error of a failed poll, in which case the other fields are
+	// empty, and the previous results remain those against which the next
+	// successful poll is compared.
+	Err error
+
+	// Time is when the poll finished, according to Config.Clock.
+	Time time.Time
+}
+
+// Watch polls expression every interval, and delivers a ChangeEvent on the
+// returned channel whenever its results change, sparing service discovery
+// consumers from writing their own poll and diff loop.
+//
+//     events, err := client.Watch(ctx, "%web", time.Minute)
+//     if err != nil {
+//         return err
+//     }
+//     for event := range events {
+//         if event.Err != nil {
+//             log.Printf("cannot refresh %q: %s", event.Expression, event.Err)
+//             continue
+//         }
+//         pool.Add(event.Added...)
+//         pool.Remove(event.Removed...)
+//     }
+//
+// The initial query is made before Watch returns, and its error, if any, is
+// returned rather than a channel.  Otherwise the first event reports every
+// value as added.  Polls are made with QueryCtx, so they share the cached and
+// coalesced results of other queries of the same expression.  A poll that
+// fails is delivered as an event with Err set.  The channel is closed once ctx
+// is done, and the caller must keep receiving from it until then, because a
+// poll waits for its event to be received before the next interval begins.
+func (c *Client) Watch(ctx context.Context, expression string, interval time.Duration) (<-chan ChangeEvent, error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("cannot watch with non-positive interval: %s", interval)
+	}
+
+	results, err := c.QueryCtx(ctx, expression)
+	if err != nil {
+		return nil, err
+	}
+
+	added, _ := Diff(nil, results)
+	events := make(chan ChangeEvent, 1)
+	events <- ChangeEvent{
+		Expression: expression,
+		Added:      added,
+		Results:    results,
+		Time:       c.clock.Now(),
+	}
+
+	go func() {
+		defer close(events)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-c.clock.After(interval):
+			}
+
+			event := ChangeEvent{Expression: expression}
+			values, err := c.QueryCtx(ctx, expression)
+			event.Time = c.clock.Now()
+			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				event.Err = err
+			} else {
+				event.Added, event.Removed = Diff(results, values)
+				if len(event.Added) == 0 && len(event.Removed) == 0 {
+					continue
+				}
+				event.Results = values
+				results = values
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case events <- event:
+			}
+		}
+	}()
+
+	return events, nil
+}
@@ -0,0 +1,101 @@
+package orange
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWatch(t *testing.T) {
+	clock := NewManualClock(time.Now())
+
+	var lock sync.Mutex
+	results := []string{"host1", "host2"}
+	var fail bool
+	mock := &MockConfig{
+		Callback: func(expression string) ([]string, error) {
+			lock.Lock()
+			defer lock.Unlock()
+			if fail {
+				return nil, ErrMockFault
+			}
+			return results, nil
+		},
+	}
+	client, err := NewClient(&Config{Clock: clock, HTTPClient: mock, Servers: []string{"mock"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	events, err := client.Watch(ctx, "%web", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	event := <-events
+	ensureStringSlicesMatch(t, event.Added, []string{"host1", "host2"})
+	ensureStringSlicesMatch(t, event.Removed, nil)
+
+	// poll waits for the watcher to be waiting on the clock, advances it, and
+	// waits for the poll it releases to finish.
+	poll := func() {
+		t.Helper()
+		if err := clock.BlockUntil(ctx, 1); err != nil {
+			t.Fatal(err)
+		}
+		n := mock.TotalCount()
+		clock.Advance(time.Minute)
+		if err := mock.WaitForCount(ctx, "%web", n+1); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Unchanged results deliver no event.
+	poll()
+
+	lock.Lock()
+	results = []string{"host2", "host3"}
+	lock.Unlock()
+	poll()
+
+	event = <-events
+	ensureStringSlicesMatch(t, event.Added, []string{"host3"})
+	ensureStringSlicesMatch(t, event.Removed, []string{"host1"})
+	ensureStringSlicesMatch(t, event.Results, []string{"host2", "host3"})
+
+	lock.Lock()
+	fail = true
+	lock.Unlock()
+	poll()
+
+	event = <-events
+	if got, want := event.Err, ErrMockFault; !errors.Is(got, want) {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+
+	cancel()
+	for range events {
+	}
+}
+
+func TestWatchInitialError(t *testing.T) {
+	client, err := NewMockClient(&MockConfig{
+		Callback: func(expression string) ([]string, error) { return nil, ErrMockFault },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = client.Watch(context.Background(), "%web", time.Minute)
+	if got, want := err, ErrMockFault; !errors.Is(got, want) {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+
+	_, err = client.Watch(context.Background(), "%web", 0)
+	ensureError(t, err, "non-positive interval")
+}
@@ -0,0 +1,85 @@
+package orange
+
+import (
+	"sync"
+	"time"
+)
+
+// workerIdleTimeout is how long a worker of a workerPool waits for another job
+// before exiting.
+const workerIdleTimeout = time.Minute
+
+// workerPool runs jobs on a bounded set of reusable go-routines, so services
+// issuing many batches keep a stable number of go-routines.  Workers are
+// started as jobs arrive, up to size, and exit after sitting idle for
+// workerIdleTimeout, so an unused pool holds no go-routines, and a Client
+// needs no Close method.
+type workerPool struct {
+	jobs    chan func()
+	size    int
+	idle    time.Duration
+	lock    sync.Mutex
+	workers int // number of running workers
+	waiting int // number of callers blocked until a worker is idle
+}
+
+func newWorkerPool(size int) *workerPool {
+	return &workerPool{jobs: make(chan func()), size: size, idle: workerIdleTimeout}
+}
+
+// run runs job on an idle worker, on a new worker when there are fewer than
+// size, or else on the first worker to become idle, blocking until then.
+func (p *workerPool) run(job func()) {
+	select {
+	case p.jobs <- job:
+		return
+	default:
+	}
+
+	p.lock.Lock()
+	if p.workers < p.size {
+		p.workers++
+		p.lock.Unlock()
+		go p.work(job)
+		return
+	}
+	// Workers do not exit while a caller is waiting, so one will take the job.
+	p.waiting++
+	p.lock.Unlock()
+
+	p.jobs <- job
+
+	p.lock.Lock()
+	p.waiting--
+	p.lock.Unlock()
+}
+
+func (p *workerPool) work(job func()) {
+	for ok := true; ok; job, ok = p.next() {
+		job()
+	}
+}
+
+// next returns the next job for a worker, or false when the worker should exit
+// because it sat idle with no callers waiting.
+func (p *workerPool) next() (func(), bool) {
+	timer := time.NewTimer(p.idle)
+	defer timer.Stop()
+
+	for {
+		select {
+		case job := <-p.jobs:
+			return job, true
+		case <-timer.C:
+		}
+
+		p.lock.Lock()
+		if p.waiting == 0 {
+			p.workers--
+			p.lock.Unlock()
+			return nil, false
+		}
+		p.lock.Unlock()
+		timer.Reset(p.idle)
+	}
+}
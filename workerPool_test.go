@@ -0,0 +1,77 @@
+package orange
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWorkerPool(t *testing.T) {
+	pool := newWorkerPool(2)
+	pool.idle = 10 * time.Millisecond
+
+	var running, maxRunning, ran int32
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		pool.run(func() {
+			defer wg.Done()
+			n := atomic.AddInt32(&running, 1)
+			for {
+				m := atomic.LoadInt32(&maxRunning)
+				if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			atomic.AddInt32(&running, -1)
+			atomic.AddInt32(&ran, 1)
+		})
+	}
+	wg.Wait()
+
+	if got, want := atomic.LoadInt32(&ran), int32(20); got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+	if got, want := atomic.LoadInt32(&maxRunning), int32(2); got > want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+
+	// Idle workers exit.
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		pool.lock.Lock()
+		workers := pool.workers
+		pool.lock.Unlock()
+		if workers == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("GOT: %v; WANT: %v", workers, 0)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestBatchWorkers(t *testing.T) {
+	mock := &MockConfig{Callback: func(expression string) ([]string, error) {
+		return []string{expression + "-host"}, nil
+	}}
+	client, err := NewClient(&Config{BatchWorkers: 2, HTTPClient: mock, Servers: []string{"mock"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expressions := []string{"a", "b", "c", "d", "e"}
+	results, err := client.Queries(expressions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, expression := range expressions {
+		ensureStringSlicesMatch(t, results[i], []string{expression + "-host"})
+	}
+
+	_, err = NewClient(&Config{BatchWorkers: -1, Servers: []string{"localhost"}})
+	ensureError(t, err, "negative BatchWorkers")
+}
@@ -0,0 +1,154 @@
+package orange
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ErrWritesDisabled is returned by the write methods of a Client, such as
+// AddToCluster, unless Config.AllowWrites is set.
+var ErrWritesDisabled = errors.New("range writes are not enabled")
+
+// WriteError is returned when a write to a range server fails.  Indeterminate
+// tells automation whether it must check the range data before trying again.
+//
+//     err := client.AddToCluster(ctx, "webservers", []string{"web42"})
+//     var we *orange.WriteError
+//     if errors.As(err, &we) && we.Indeterminate {
+//         // the write may or may not have been applied
+//     }
+type WriteError struct {
+	Operation string // Operation is the write operation, either "add to" or "remove from".
+	Cluster   string // Cluster is the name of the cluster being modified.
+	Server    string // Server is the address of the range server the write was sent to.
+
+	// Indeterminate is true when a request may have reached a range server,
+	// but no response was received, so the write may or may not have been
+	// applied.  When writes are retried, it is true when this was so for any
+	// attempt.
+	Indeterminate bool
+
+	// Err is the underlying error, which is an *ErrRangeException or an
+	// *ErrStatusNotOK when the range server rejected the write.
+	Err error
+}
+
+func (err *WriteError) Error() string {
+	return fmt.Sprintf("cannot %s cluster %q using %s: %s", err.Operation, err.Cluster, err.Server, err.Err)
+}
+
+// Unwrap returns the underlying error.
+func (err *WriteError) Unwrap() error { return err.Err }
+
+// AddToCluster asks a range server that accepts writes to add hosts to
+// cluster, by sending a POST request to its Config.AddPath endpoint, such as
+// /range/add, with the cluster and hosts as form values.  It returns ErrWritesDisabled unless
+// Config.AllowWrites is set.
+//
+//     err := client.AddToCluster(ctx, "webservers", []string{"web42", "web43"})
+//
+// Unless Config.RetryWrites is set, a write is sent to a single range server
+// exactly once, regardless of the Retry settings.  Failures are returned as a
+// *WriteError.  Cached results of queries are not invalidated.
+func (c *Client) AddToCluster(ctx context.Context, cluster string, hosts []string) error {
+	return c.write(ctx, "add to", c.addPath, cluster, hosts)
+}
+
+// RemoveFromCluster asks a range server that accepts writes to remove hosts
+// from cluster, by sending a POST request to its Config.RemovePath endpoint,
+// such as /range/remove, with the cluster and hosts as form values.  See AddToCluster for
+// how writes are sent and how they fail.
+func (c *Client) RemoveFromCluster(ctx context.Context, cluster string, hosts []string) error {
+	return c.write(ctx, "remove from", c.removePath, cluster, hosts)
+}
+
+// write sends a write request for operation to the path of a range server,
+// and, when Config.RetryWrites is set, retries it as queries are retried.
+func (c *Client) write(ctx context.Context, operation, path, cluster string, hosts []string) error {
+	if !c.allowWrites {
+		return ErrWritesDisabled
+	}
+
+	c.inFlight.add()
+	defer c.inFlight.done()
+
+	var retryCount int
+	if c.retryWrites {
+		retryCount = c.retryCount
+	}
+
+	var attempts int
+	var indeterminate bool
+
+	for {
+		if attempts > 0 && c.retryPause > 0 {
+			select {
+			case <-c.clock.After(c.retryPause):
+			case <-ctx.Done():
+				return &WriteError{Operation: operation, Cluster: cluster, Indeterminate: indeterminate, Err: contextError(ctx)}
+			}
+		}
+
+		err := c.writeOnce(ctx, operation, path, c.servers.Next(), cluster, hosts)
+		if err == nil {
+			return nil
+		}
+		// Once any attempt may have reached a range server, the write may
+		// have been applied, even when a later attempt is rejected.
+		indeterminate = indeterminate || err.Indeterminate
+		err.Indeterminate = indeterminate
+
+		if attempts == retryCount || ctx.Err() != nil || c.retryCallback(err.Err) == false {
+			return err
+		}
+		attempts++
+	}
+}
+
+// writeOnce sends a single write request for operation to the path of server.
+func (c *Client) writeOnce(ctx context.Context, operation, path, server, cluster string, hosts []string) *WriteError {
+	fail := func(err error, indeterminate bool) *WriteError {
+		return &WriteError{Operation: operation, Cluster: cluster, Server: server, Indeterminate: indeterminate, Err: err}
+	}
+
+	form := url.Values{"cluster": []string{cluster}, "host": hosts}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, c.serverURL(server)+path, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fail(err, false)
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	c.setHeaders(ctx, request.Header)
+
+	if err := c.authenticate(request); err != nil {
+		return fail(err, false)
+	}
+	response, err := c.httpClient.Do(request)
+	if err != nil {
+		return fail(err, !isUnreachable(err))
+	}
+
+	if message := response.Header.Get("RangeException"); message != "" {
+		e := newErrRangeException(message)
+		if buf, err := c.readErrorBody(response.Body); err == nil && len(buf) > 0 {
+			e.Body = buf
+		}
+		return fail(e, false)
+	}
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		e := &ErrStatusNotOK{
+			Header:     response.Header,
+			Status:     response.Status,
+			StatusCode: response.StatusCode,
+		}
+		if buf, err := c.readErrorBody(response.Body); err == nil && len(buf) > 0 {
+			e.Body = buf
+		}
+		return fail(e, false)
+	}
+	_ = discard(response.Body) // the write succeeded, whether or not the body is read
+	return nil
+}
@@ -0,0 +1,174 @@
+package orange
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func withWriteClient(t *testing.T, h func(w http.ResponseWriter, r *http.Request), callback func(*Client)) {
+	withTestServer(t, h, func(server *httptest.Server) {
+		client, err := NewClient(&Config{
+			AllowWrites:   true,
+			HTTPClient:    server.Client(),
+			RetryCallback: func(error) bool { return true },
+			RetryCount:    2,
+			Servers:       []string{strings.TrimPrefix(server.URL, "http://")},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		callback(client)
+	})
+}
+
+func TestAddToCluster(t *testing.T) {
+	t.Run("disabled", func(t *testing.T) {
+		withClient(t, func(w http.ResponseWriter, r *http.Request) {
+			t.Error("write should not be sent")
+		}, func(client *Client) {
+			err := client.AddToCluster(context.Background(), "webservers", []string{"web42"})
+			if got, want := err, ErrWritesDisabled; !errors.Is(got, want) {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+		})
+	})
+
+	t.Run("request", func(t *testing.T) {
+		withWriteClient(t, func(w http.ResponseWriter, r *http.Request) {
+			if got, want := r.Method, http.MethodPost; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+			if got, want := r.URL.Path, "/range/add"; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+			if err := r.ParseForm(); err != nil {
+				t.Fatal(err)
+			}
+			if got, want := r.PostForm.Get("cluster"), "webservers"; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+			ensureStringSlicesMatch(t, r.PostForm["host"], []string{"web42", "web43"})
+			w.WriteHeader(http.StatusNoContent)
+		}, func(client *Client) {
+			ensureError(t, client.AddToCluster(context.Background(), "webservers", []string{"web42", "web43"}))
+		})
+	})
+
+	t.Run("range exception", func(t *testing.T) {
+		withWriteClient(t, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("RangeException", "NOCLUSTER: no such cluster: 'webservers'")
+		}, func(client *Client) {
+			err := client.AddToCluster(context.Background(), "webservers", []string{"web42"})
+			ensureError(t, err, "cannot add to cluster \"webservers\"", "NOCLUSTER")
+			if got, want := IsRangeException(err), true; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+		})
+	})
+
+	t.Run("not retried", func(t *testing.T) {
+		var requests int
+		withWriteClient(t, func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}, func(client *Client) {
+			err := client.RemoveFromCluster(context.Background(), "webservers", []string{"web42"})
+			var we *WriteError
+			if !errors.As(err, &we) {
+				t.Fatalf("GOT: %v; WANT: %v", err, "*WriteError")
+			}
+			if got, want := we.Operation, "remove from"; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+			if got, want := we.Indeterminate, false; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+			if got, want := errors.Is(err, &ErrStatusNotOK{StatusCode: http.StatusServiceUnavailable}), true; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+			if got, want := requests, 1; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+		})
+	})
+}
+
+func TestRetryWrites(t *testing.T) {
+	var hosts []string
+	client, err := NewClient(&Config{
+		AllowWrites: true,
+		HTTPClient: DoerFunc(func(request *http.Request) (*http.Response, error) {
+			hosts = append(hosts, request.URL.Host)
+			switch len(hosts) {
+			case 1:
+				return nil, errors.New("connection reset by peer")
+			case 2:
+				return &http.Response{StatusCode: http.StatusServiceUnavailable, Status: "503 Service Unavailable", Body: http.NoBody}, nil
+			default:
+				return &http.Response{StatusCode: http.StatusNoContent, Body: http.NoBody}, nil
+			}
+		}),
+		RetryCallback: func(error) bool { return true },
+		RetryCount:    1,
+		RetryWrites:   true,
+		Servers:       []string{"range1.example.com", "range2.example.com"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = client.AddToCluster(context.Background(), "webservers", []string{"web42"})
+	var we *WriteError
+	if !errors.As(err, &we) {
+		t.Fatalf("GOT: %v; WANT: %v", err, "*WriteError")
+	}
+	if got, want := we.Indeterminate, true; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+	if got, want := errors.Is(err, &ErrStatusNotOK{StatusCode: http.StatusServiceUnavailable}), true; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+	if got, want := len(hosts), 2; got != want {
+		t.Fatalf("GOT: %v; WANT: %v", got, want)
+	}
+	if hosts[0] == hosts[1] {
+		t.Errorf("GOT: %v; WANT: %v", hosts[1], "another server")
+	}
+
+	ensureError(t, client.RemoveFromCluster(context.Background(), "webservers", []string{"web42"}))
+	if got, want := len(hosts), 3; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+}
+
+func TestWritePaths(t *testing.T) {
+	var paths []string
+	doer := DoerFunc(func(request *http.Request) (*http.Response, error) {
+		paths = append(paths, request.URL.Path)
+		return &http.Response{StatusCode: http.StatusNoContent, Body: http.NoBody}, nil
+	})
+	ctx := context.Background()
+
+	for _, config := range []*Config{
+		{Path: "/v1/range/list"},
+		{AddPath: "/admin/add", RemovePath: "/admin/remove"},
+	} {
+		config.AllowWrites = true
+		config.HTTPClient = doer
+		config.Servers = []string{"range.example.com"}
+		client, err := NewClient(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ensureError(t, client.AddToCluster(ctx, "webservers", []string{"web42"}))
+		ensureError(t, client.RemoveFromCluster(ctx, "webservers", []string{"web42"}))
+	}
+	ensureStringSlicesMatch(t, paths, []string{"/v1/range/add", "/v1/range/remove", "/admin/add", "/admin/remove"})
+
+	_, err := NewClient(&Config{AddPath: "admin/add", Servers: []string{"range.example.com"}})
+	ensureError(t, err, "AddPath not starting with a slash")
+}